@@ -2,35 +2,230 @@ package ask
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"os"
 	"strings"
 
 	"llm/internal/loading"
+	"llm/internal/markdown"
 	"llm/internal/providers"
+	"llm/internal/session"
+	"llm/internal/term"
 )
 
+const usage = "usage: llm ask [-c | --session <name>] [-f <file>]... [--raw] <question>"
+
+// Options configures Run beyond the provider.
+type Options struct {
+	// Sessions stores the conversation so that it can be continued. When
+	// nil, questions are answered without history.
+	Sessions *session.Store
+	// Stdin is sent as context with the question when it is a pipe or a
+	// file, or when -f - is given.
+	Stdin io.Reader
+	// Provider and Model name the configured provider and model, which
+	// are saved with the session. When empty they are taken from the
+	// provider, if it can describe itself.
+	Provider string
+	Model    string
+}
+
+// Config holds the flags given to ask before the question.
+type Config struct {
+	// Continue asks a follow-up in the last used session.
+	Continue bool
+	// Session names the session to continue or start.
+	Session string
+	// Files are attached as context, in order.
+	Files []string
+	// Raw writes the answer as it is, without rendering Markdown.
+	Raw bool
+}
+
+// ParseConfig reads the flags at the start of args and returns the words
+// of the question that follow them.
+func ParseConfig(args []string) (*Config, []string, error) {
+	cfg := &Config{}
+
+	for i := 0; i < len(args); i++ {
+		switch arg := args[i]; {
+		case arg == "-c" || arg == "--continue":
+			cfg.Continue = true
+		case arg == "-s" || arg == "--session":
+			if i+1 == len(args) {
+				return nil, nil, fmt.Errorf("%s requires a session name", arg)
+			}
+			i++
+			cfg.Session = args[i]
+		case strings.HasPrefix(arg, "--session="):
+			cfg.Session = strings.TrimPrefix(arg, "--session=")
+		case arg == "-f" || arg == "--file":
+			if i+1 == len(args) {
+				return nil, nil, fmt.Errorf("%s requires a file name", arg)
+			}
+			i++
+			cfg.Files = append(cfg.Files, args[i])
+		case strings.HasPrefix(arg, "--file="):
+			cfg.Files = append(cfg.Files, strings.TrimPrefix(arg, "--file="))
+		case arg == "--raw":
+			cfg.Raw = true
+		case arg == "--":
+			return cfg, args[i+1:], nil
+		default:
+			return cfg, args[i:], nil
+		}
+	}
+
+	return cfg, nil, nil
+}
+
 // Run executes the ask command with the given arguments.
+// The answer is streamed to output as it arrives when the provider supports it.
+// Files given with -f and content piped to stdin are sent in delimited
+// blocks ahead of the question; -f - reads stdin whatever it is. When output is a terminal the answer is
+// rendered as Markdown, unless --raw is given or NO_COLOR is set.
+// With a session store the conversation is saved: -c continues the last
+// one and --session continues or starts a named one. Otherwise a new
+// unnamed conversation replaces the previous one.
 // Returns an error if no arguments are provided or if the provider fails.
-func Run(ctx context.Context, provider providers.Provider, output io.Writer, stderr io.Writer, args []string) error {
+func Run(ctx context.Context, provider providers.Provider, opts Options, output io.Writer, stderr io.Writer, args []string) error {
 	if output == nil {
 		output = io.Discard
 	}
+	if stderr == nil {
+		stderr = io.Discard
+	}
 
+	cfg, args, err := ParseConfig(args)
+	if err != nil {
+		return err
+	}
 	if len(args) == 0 {
-		return fmt.Errorf("usage: llm ask <question>")
+		return fmt.Errorf("%s", usage)
 	}
 
-	question := strings.Join(args, " ")
+	attachments, err := readContext(opts.Stdin, cfg.Files)
+	if err != nil {
+		return err
+	}
+	question := withContext(strings.Join(args, " "), attachments)
+
+	sess, err := openSession(opts, cfg)
+	if err != nil {
+		return err
+	}
+	msgs := append(sess.Messages, providers.Message{Role: "user", Content: question})
+
+	answer := output
+	var md *markdown.Renderer
+	if !cfg.Raw && term.IsTerminal(output) && os.Getenv("NO_COLOR") == "" {
+		md = markdown.NewRenderer(output)
+		answer = md
+	}
 
 	ind := loading.Start(stderr)
-	response, err := provider.Complete(ctx, "", question)
+	resp, err := providers.StreamChat(ctx, provider, "", msgs, func(delta string) error {
+		ind.Stop()
+		_, err := io.WriteString(answer, delta)
+		return err
+	})
 	ind.Stop()
 
+	if md != nil {
+		if flushErr := md.Flush(); err == nil {
+			err = flushErr
+		}
+	}
+
+	if err != nil && !errors.Is(err, providers.ErrTruncated) {
+		return err
+	}
+
+	// A truncated answer is saved too, so that a follow-up can ask for the
+	// rest.
+	if opts.Sessions != nil {
+		saveSession(opts, sess, provider, msgs, resp, stderr)
+	}
+
 	if err != nil {
 		return err
 	}
 
-	_, err = fmt.Fprintln(output, response)
+	_, err = fmt.Fprintln(output)
 	return err
 }
+
+// openSession returns the session the question belongs to, which is new
+// unless cfg continues one.
+func openSession(opts Options, cfg *Config) (*session.Session, error) {
+	if opts.Sessions == nil {
+		if cfg.Continue || cfg.Session != "" {
+			return nil, fmt.Errorf("sessions unavailable: could not determine the state directory")
+		}
+		return &session.Session{}, nil
+	}
+
+	name := cfg.Session
+	switch {
+	case name != "":
+		if err := session.CheckName(name); err != nil {
+			return nil, err
+		}
+	case cfg.Continue:
+		last, err := opts.Sessions.Last()
+		if err != nil {
+			return nil, err
+		}
+		name = last
+	default:
+		return &session.Session{Name: session.Default}, nil
+	}
+
+	sess, err := opts.Sessions.Load(name)
+	if errors.Is(err, session.ErrNotFound) && !cfg.Continue {
+		return &session.Session{Name: name}, nil
+	}
+	return sess, err
+}
+
+// SessionModel returns the provider and model of the session that args
+// continue, so that a follow-up is answered by the same model. It returns
+// empty strings when args start a new conversation or the session cannot
+// be read; Run reports the latter.
+func SessionModel(sessions *session.Store, args []string) (provider, model string) {
+	cfg, _, err := ParseConfig(args)
+	if err != nil || sessions == nil || !cfg.Continue && cfg.Session == "" {
+		return "", ""
+	}
+
+	sess, err := openSession(Options{Sessions: sessions}, cfg)
+	if err != nil {
+		return "", ""
+	}
+	return sess.Provider, sess.Model
+}
+
+// saveSession stores the question and answer in sess. Failures are
+// reported to stderr, since the answer has already been shown.
+func saveSession(opts Options, sess *session.Session, provider providers.Provider, msgs []providers.Message, resp providers.Response, stderr io.Writer) {
+	sess.Messages = append(msgs, providers.Message{Role: "assistant", Content: resp.Text})
+
+	// The configured model is preferred to the one reported by the API,
+	// which may carry a version suffix or name a snapshot.
+	u := providers.Usage{Provider: opts.Provider, Model: opts.Model}
+	if u.Model == "" {
+		u = providers.Describe(provider)
+	}
+	if u.Model == "" {
+		u = resp.Usage
+	}
+	if u.Model != "" {
+		sess.Provider, sess.Model = u.Provider, u.Model
+	}
+
+	if err := opts.Sessions.Save(sess); err != nil {
+		_, _ = fmt.Fprintf(stderr, "llm: saving session: %v\n", err)
+	}
+}
@@ -4,7 +4,14 @@ import (
 	"bytes"
 	"context"
 	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
+
+	"llm/internal/providers"
+	"llm/internal/session"
 )
 
 type stubProvider struct {
@@ -12,8 +19,37 @@ type stubProvider struct {
 	err  error
 }
 
-func (s *stubProvider) Complete(ctx context.Context, system, userMsg string) (string, error) {
-	return s.resp, s.err
+func (s *stubProvider) Complete(ctx context.Context, system, userMsg string) (providers.Response, error) {
+	return providers.Response{Text: s.resp}, s.err
+}
+
+type stubStreamProvider struct {
+	deltas []string
+	err    error
+}
+
+func (s *stubStreamProvider) Complete(ctx context.Context, system, userMsg string) (providers.Response, error) {
+	return providers.Response{}, errors.New("Complete should not be called on a streaming provider")
+}
+
+func (s *stubStreamProvider) Stream(ctx context.Context, system, userMsg string, onDelta func(string) error) (providers.Response, error) {
+	var text string
+	for _, delta := range s.deltas {
+		if err := onDelta(delta); err != nil {
+			return providers.Response{}, err
+		}
+		text += delta
+	}
+	return providers.Response{Text: text}, s.err
+}
+
+type recordingWriter struct {
+	writes []string
+}
+
+func (w *recordingWriter) Write(p []byte) (int, error) {
+	w.writes = append(w.writes, string(p))
+	return len(p), nil
 }
 
 func TestRun(t *testing.T) {
@@ -71,7 +107,7 @@ func TestRun(t *testing.T) {
 			var output bytes.Buffer
 			provider := &stubProvider{resp: tt.resp, err: tt.err}
 
-			err := Run(context.Background(), provider, &output, nil, tt.args)
+			err := Run(context.Background(), provider, Options{}, &output, nil, tt.args)
 
 			if (err != nil) != tt.wantErr {
 				t.Errorf("Run() error = %v, wantErr %v", err, tt.wantErr)
@@ -88,8 +124,228 @@ func TestRun(t *testing.T) {
 
 func TestRun_NilOutputDefaultsToDiscard(t *testing.T) {
 	provider := &stubProvider{resp: "test response"}
-	err := Run(context.Background(), provider, nil, nil, []string{"test"})
+	err := Run(context.Background(), provider, Options{}, nil, nil, []string{"test"})
 	if err != nil {
 		t.Errorf("Run() with nil output error = %v, want nil", err)
 	}
 }
+
+func TestRun_StreamsDeltas(t *testing.T) {
+	var output recordingWriter
+	provider := &stubStreamProvider{deltas: []string{"Go is ", "a programming ", "language."}}
+
+	err := Run(context.Background(), provider, Options{}, &output, nil, []string{"what", "is", "Go?"})
+	if err != nil {
+		t.Fatalf("Run() error = %v, want nil", err)
+	}
+
+	want := []string{"Go is ", "a programming ", "language.", "\n"}
+	if !reflect.DeepEqual(output.writes, want) {
+		t.Errorf("writes = %q, want %q", output.writes, want)
+	}
+}
+
+func TestRun_StreamError(t *testing.T) {
+	var output bytes.Buffer
+	provider := &stubStreamProvider{deltas: []string{"partial"}, err: errors.New("connection reset")}
+
+	err := Run(context.Background(), provider, Options{}, &output, nil, []string{"hello"})
+	if err == nil {
+		t.Fatal("Run() error = nil, want error")
+	}
+
+	if output.String() != "partial" {
+		t.Errorf("output = %q, want %q", output.String(), "partial")
+	}
+}
+
+type stubChatProvider struct {
+	resp  string
+	err   error
+	calls [][]providers.Message
+}
+
+func (s *stubChatProvider) Complete(ctx context.Context, system, userMsg string) (providers.Response, error) {
+	return providers.Response{}, errors.New("Complete should not be called on a chat provider")
+}
+
+func (s *stubChatProvider) Chat(ctx context.Context, system string, msgs []providers.Message) (providers.Response, error) {
+	s.calls = append(s.calls, msgs)
+	return providers.Response{Text: s.resp, Usage: providers.Usage{Provider: "openai", Model: "gpt-4o"}}, s.err
+}
+
+func TestRun_ContinuesSessions(t *testing.T) {
+	store := session.NewStore(t.TempDir())
+	provider := &stubChatProvider{resp: "Paris."}
+	opts := Options{Sessions: store}
+
+	steps := [][]string{
+		{"capital", "of", "France?"},
+		{"-c", "and", "Italy?"},
+		{"--session", "trip", "plan a trip"},
+		{"-c", "make it shorter"},
+		{"first", "question", "again"},
+	}
+	for _, args := range steps {
+		if err := Run(context.Background(), provider, opts, nil, nil, args); err != nil {
+			t.Fatalf("Run(%q) error = %v, want nil", args, err)
+		}
+	}
+
+	wantTurns := []int{1, 2, 1, 2, 1}
+	for i, msgs := range provider.calls {
+		if len(msgs) != 2*wantTurns[i]-1 {
+			t.Errorf("call %d sent %d messages, want %d", i, len(msgs), 2*wantTurns[i]-1)
+		}
+	}
+	if got := provider.calls[1][0].Content; got != "capital of France?" {
+		t.Errorf("follow-up history starts with %q, want the first question", got)
+	}
+	if got := provider.calls[3][0].Content; got != "plan a trip" {
+		t.Errorf("-c after --session continued %q, want the trip session", got)
+	}
+
+	trip, err := store.Load("trip")
+	if err != nil {
+		t.Fatalf("Load(trip) error = %v", err)
+	}
+	if trip.Turns() != 2 || trip.Provider != "openai" || trip.Model != "gpt-4o" {
+		t.Errorf("trip session = %+v, want two turns with gpt-4o via openai", trip)
+	}
+
+	if last, _ := store.Last(); last != session.Default {
+		t.Errorf("last session = %q, want a new question to start %q", last, session.Default)
+	}
+	if provider, model := SessionModel(store, []string{"-s", "trip", "hi"}); provider != "openai" || model != "gpt-4o" {
+		t.Errorf("SessionModel() = %q, %q, want openai, gpt-4o", provider, model)
+	}
+	if _, model := SessionModel(store, []string{"new", "question"}); model != "" {
+		t.Errorf("SessionModel() for a new question = %q, want empty", model)
+	}
+}
+
+func TestRun_SessionErrors(t *testing.T) {
+	provider := &stubChatProvider{resp: "ok"}
+
+	tests := []struct {
+		name string
+		opts Options
+		args []string
+		want string
+	}{
+		{"nothing to continue", Options{Sessions: session.NewStore(t.TempDir())}, []string{"-c", "more"}, "no conversation to continue"},
+		{"invalid name", Options{Sessions: session.NewStore(t.TempDir())}, []string{"--session=../x", "hi"}, "invalid session name"},
+		{"missing name", Options{}, []string{"--session"}, "requires a session name"},
+		{"no store", Options{}, []string{"-c", "more"}, "sessions unavailable"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Run(context.Background(), provider, tt.opts, nil, nil, tt.args)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Run() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestRun_SendsContext(t *testing.T) {
+	dir := t.TempDir()
+	mainGo := filepath.Join(dir, "main.go")
+	if err := os.WriteFile(mainGo, []byte("package main\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	provider := &stubChatProvider{resp: "ok"}
+	opts := Options{Stdin: strings.NewReader("panic: boom")}
+	if err := Run(context.Background(), provider, opts, nil, nil, []string{"-f", mainGo, "why", "does", "this", "fail?"}); err != nil {
+		t.Fatalf("Run() error = %v, want nil", err)
+	}
+
+	want := "<file name=\"" + mainGo + "\">\npackage main\n</file>\n\n<stdin>\npanic: boom\n</stdin>\n\nwhy does this fail?"
+	if got := provider.calls[0][0].Content; got != want {
+		t.Errorf("question =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestReadContextStdin(t *testing.T) {
+	devNull, err := os.Open(os.DevNull)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = devNull.Close() })
+
+	// Reading an open stdin that is neither a pipe nor a file could wait
+	// forever, so it is left alone.
+	if attachments, err := readContext(devNull, nil); err != nil || len(attachments) != 0 {
+		t.Errorf("readContext(%s) = %v, %v, want nothing read", os.DevNull, attachments, err)
+	}
+
+	empty, err := os.Create(filepath.Join(t.TempDir(), "empty"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = empty.Close() })
+	if attachments, err := readContext(empty, nil); err != nil || len(attachments) != 0 {
+		t.Errorf("readContext(empty file) = %v, %v, want no attachment", attachments, err)
+	}
+
+	dir := t.TempDir()
+	goMod := filepath.Join(dir, "go.mod")
+	if err := os.WriteFile(goMod, []byte("module app\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	attachments, err := readContext(strings.NewReader("piped\n"), []string{"-", goMod})
+	want := []attachment{{content: "piped\n"}, {file: goMod, content: "module app\n"}}
+	if err != nil || !reflect.DeepEqual(attachments, want) {
+		t.Errorf("readContext(-f -) = %+v, %v, want stdin first and read once", attachments, err)
+	}
+}
+
+func TestRun_ContextErrors(t *testing.T) {
+	dir := t.TempDir()
+	binary := filepath.Join(dir, "app")
+	if err := os.WriteFile(binary, []byte("\x7fELF\x00\x01"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		stdin string
+		args  []string
+		want  string
+	}{
+		{"binary file", "", []string{"--file=" + binary, "what is this?"}, "looks like a binary file"},
+		{"missing file", "", []string{"-f", filepath.Join(dir, "missing"), "hi"}, "no such file"},
+		{"missing file name", "", []string{"-f"}, "requires a file name"},
+		{"too large", strings.Repeat("log line\n", maxContextSize/8), []string{"summarize"}, "larger than 256 KiB"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := &stubChatProvider{resp: "ok"}
+			err := Run(context.Background(), provider, Options{Stdin: strings.NewReader(tt.stdin)}, nil, nil, tt.args)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Run() error = %v, want %q", err, tt.want)
+			}
+			if len(provider.calls) > 0 {
+				t.Errorf("provider was called %d times, want none", len(provider.calls))
+			}
+		})
+	}
+}
+
+func TestParseConfig(t *testing.T) {
+	cfg, args, err := ParseConfig([]string{"-c", "--session=work", "-f", "a.go", "--file", "b.go", "--raw", "--", "-f", "is", "a", "flag?"})
+	if err != nil {
+		t.Fatalf("ParseConfig() error = %v, want nil", err)
+	}
+
+	want := &Config{Continue: true, Session: "work", Files: []string{"a.go", "b.go"}, Raw: true}
+	if !reflect.DeepEqual(cfg, want) {
+		t.Errorf("ParseConfig() config = %+v, want %+v", cfg, want)
+	}
+	if !reflect.DeepEqual(args, []string{"-f", "is", "a", "flag?"}) {
+		t.Errorf("ParseConfig() args = %q, want the words after --", args)
+	}
+}
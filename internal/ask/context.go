@@ -0,0 +1,127 @@
+package ask
+
+import (
+	"bytes"
+	"cmp"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// maxContextSize caps the piped and attached content sent with a question,
+// which would otherwise be easy to make expensive by accident.
+const maxContextSize = 256 << 10
+
+// binarySniffLen is how much of each input is searched for a NUL byte, as
+// git does to tell binary files from text.
+const binarySniffLen = 8000
+
+// attachment is a piece of content sent along with the question. file is
+// empty for content piped to stdin.
+type attachment struct {
+	file    string
+	content string
+}
+
+// readContext reads files and stdin and returns them in the order they
+// were given. A file named "-" stands for stdin; otherwise stdin comes last
+// and is only read when something was piped or redirected to it. It fails
+// on binary content and when the total exceeds maxContextSize.
+func readContext(stdin io.Reader, files []string) ([]attachment, error) {
+	var attachments []attachment
+	size := 0
+
+	add := func(file string, r io.Reader) error {
+		name := cmp.Or(file, "stdin")
+		data, err := io.ReadAll(io.LimitReader(r, int64(maxContextSize-size)+1))
+		if err != nil {
+			return fmt.Errorf("reading %s: %w", name, err)
+		}
+		if bytes.IndexByte(data[:min(len(data), binarySniffLen)], 0) >= 0 {
+			return fmt.Errorf("%s looks like a binary file; only text can be attached", name)
+		}
+
+		size += len(data)
+		if size > maxContextSize {
+			return fmt.Errorf("attached content is larger than %d KiB; trim it first, for example with tail", maxContextSize>>10)
+		}
+		if len(bytes.TrimSpace(data)) > 0 {
+			attachments = append(attachments, attachment{file: file, content: string(data)})
+		}
+		return nil
+	}
+
+	readStdin := hasInput(stdin)
+	for _, path := range files {
+		if path == "-" {
+			if stdin == nil {
+				continue
+			}
+			if err := add("", stdin); err != nil {
+				return nil, err
+			}
+			readStdin = false
+			continue
+		}
+
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		err = add(path, f)
+		_ = f.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if readStdin {
+		if err := add("", stdin); err != nil {
+			return nil, err
+		}
+	}
+
+	return attachments, nil
+}
+
+// hasInput reports whether stdin is a pipe or a regular file. Terminals,
+// /dev/null and sockets left open by whatever started llm are skipped, as
+// reading them may wait for input that never comes.
+func hasInput(stdin io.Reader) bool {
+	if stdin == nil {
+		return false
+	}
+	f, ok := stdin.(*os.File)
+	if !ok {
+		return true
+	}
+	info, err := f.Stat()
+	return err == nil && (info.Mode()&os.ModeNamedPipe != 0 || info.Mode().IsRegular())
+}
+
+// withContext puts each attachment in a delimited block ahead of the
+// question, so that the model can tell the content from what is asked.
+func withContext(question string, attachments []attachment) string {
+	if len(attachments) == 0 {
+		return question
+	}
+
+	var b strings.Builder
+	for _, a := range attachments {
+		tag := "stdin"
+		if a.file != "" {
+			tag = "file"
+			fmt.Fprintf(&b, "<file name=%q>\n", a.file)
+		} else {
+			b.WriteString("<stdin>\n")
+		}
+		b.WriteString(a.content)
+		if !strings.HasSuffix(a.content, "\n") {
+			b.WriteString("\n")
+		}
+		fmt.Fprintf(&b, "</%s>\n\n", tag)
+	}
+	b.WriteString(question)
+	return b.String()
+}
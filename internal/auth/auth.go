@@ -0,0 +1,152 @@
+// Package auth implements the llm auth commands, which store provider API
+// keys in the system keyring and report where each key comes from.
+package auth
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"llm/internal/credentials"
+	"llm/internal/providers"
+	"llm/internal/term"
+)
+
+// Login reads an API key for the provider named in args from stdin and
+// stores it in keyring. When stdin is a terminal the key is not echoed.
+// Waiting for the key ends when ctx is done.
+func Login(ctx context.Context, keyring credentials.Keyring, stdin io.Reader, stdout, stderr io.Writer, args []string) error {
+	provider, err := providerArg("login", args)
+	if err != nil {
+		return err
+	}
+
+	if err := checkKeyring(keyring); err != nil {
+		return err
+	}
+
+	_, _ = fmt.Fprintf(stderr, "API key for %s: ", provider)
+	key, err := readKey(ctx, stdin)
+	_, _ = fmt.Fprintln(stderr)
+	if err != nil {
+		return err
+	}
+	if key == "" {
+		return fmt.Errorf("no API key given")
+	}
+
+	if err := keyring.Set(provider, key); err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(stdout, "Stored the %s API key in the keyring\n", provider)
+	return err
+}
+
+// Logout removes the key of the provider named in args from keyring.
+func Logout(keyring credentials.Keyring, stdout io.Writer, args []string) error {
+	provider, err := providerArg("logout", args)
+	if err != nil {
+		return err
+	}
+	if err := checkKeyring(keyring); err != nil {
+		return err
+	}
+
+	if err := keyring.Delete(provider); err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(stdout, "Removed the %s API key from the keyring\n", provider)
+	return err
+}
+
+// Status writes where the key of every provider comes from. Keys
+// themselves are never printed.
+func Status(statuses []providers.KeyStatus, stdout io.Writer, args []string) error {
+	if len(args) > 0 {
+		return fmt.Errorf("usage: llm auth status")
+	}
+
+	w := tabwriter.NewWriter(stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "PROVIDER\tKEY")
+	for _, s := range statuses {
+		_, _ = fmt.Fprintf(w, "%s\t%s\n", s.Provider, s)
+	}
+	return w.Flush()
+}
+
+// checkKeyring fails before the user is asked for a key that could not be
+// stored.
+func checkKeyring(keyring credentials.Keyring) error {
+	if k, ok := keyring.(interface{ Available() bool }); keyring == nil || ok && !k.Available() {
+		return fmt.Errorf("no keyring available; install secret-tool from libsecret, or set credential_helper in the config instead")
+	}
+	return nil
+}
+
+func providerArg(command string, args []string) (string, error) {
+	if len(args) != 1 {
+		return "", fmt.Errorf("usage: llm auth %s <provider>", command)
+	}
+
+	names := providers.Names()
+	if !slices.Contains(names, args[0]) {
+		return "", fmt.Errorf("unknown provider %q (available: %s)", args[0], strings.Join(names, ", "))
+	}
+	return args[0], nil
+}
+
+// readKey reads one line from stdin, turning off echo while it is typed
+// when stdin is a terminal. The line is read in the background so that
+// waiting for it ends when ctx is done, with echo turned back on.
+func readKey(ctx context.Context, stdin io.Reader) (string, error) {
+	if f, ok := stdin.(*os.File); ok && term.IsTerminal(f) {
+		if restore, err := disableEcho(f); err == nil {
+			defer restore()
+		}
+	}
+
+	type result struct {
+		line string
+		err  error
+	}
+	read := make(chan result, 1)
+	go func() {
+		line, err := bufio.NewReader(stdin).ReadString('\n')
+		read <- result{line, err}
+	}()
+
+	var r result
+	select {
+	case <-ctx.Done():
+		return "", ctx.Err()
+	case r = <-read:
+	}
+
+	line, err := r.line, r.err
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", fmt.Errorf("reading API key: %w", err)
+	}
+	return strings.TrimSpace(line), nil
+}
+
+func disableEcho(f *os.File) (func(), error) {
+	stty := func(arg string) error {
+		cmd := exec.Command("stty", arg)
+		cmd.Stdin = f
+		return cmd.Run()
+	}
+
+	if err := stty("-echo"); err != nil {
+		return nil, err
+	}
+	return func() { _ = stty("echo") }, nil
+}
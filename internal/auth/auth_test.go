@@ -0,0 +1,124 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"llm/internal/credentials"
+	"llm/internal/providers"
+)
+
+type mapKeyring map[string]string
+
+func (m mapKeyring) Get(provider string) (string, error) {
+	if key, ok := m[provider]; ok {
+		return key, nil
+	}
+	return "", credentials.ErrNotFound
+}
+
+func (m mapKeyring) Set(provider, key string) error {
+	m[provider] = key
+	return nil
+}
+
+func (m mapKeyring) Delete(provider string) error {
+	delete(m, provider)
+	return nil
+}
+
+type unavailableKeyring struct{ mapKeyring }
+
+func (unavailableKeyring) Available() bool { return false }
+
+func TestLoginAndLogout(t *testing.T) {
+	keyring := mapKeyring{}
+	var stdout, stderr bytes.Buffer
+
+	if err := Login(context.Background(), keyring, strings.NewReader("  sk-pasted \n"), &stdout, &stderr, []string{"openai"}); err != nil {
+		t.Fatalf("Login() error = %v, want nil", err)
+	}
+	if keyring["openai"] != "sk-pasted" {
+		t.Errorf("stored key = %q, want %q", keyring["openai"], "sk-pasted")
+	}
+	if !strings.Contains(stderr.String(), "API key for openai: ") || strings.Contains(stdout.String()+stderr.String(), "sk-pasted") {
+		t.Errorf("output = %q / %q, want a prompt and no key", stdout.String(), stderr.String())
+	}
+
+	stdout.Reset()
+	if err := Logout(keyring, &stdout, []string{"openai"}); err != nil {
+		t.Fatalf("Logout() error = %v, want nil", err)
+	}
+	if _, ok := keyring["openai"]; ok || stdout.String() != "Removed the openai API key from the keyring\n" {
+		t.Errorf("after Logout() keyring = %v, output = %q", keyring, stdout.String())
+	}
+}
+
+func TestLoginErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		keyring credentials.Keyring
+		stdin   string
+		args    []string
+		want    string
+	}{
+		{"no provider", mapKeyring{}, "sk", nil, "usage: llm auth login <provider>"},
+		{"unknown provider", mapKeyring{}, "sk", []string{"acme"}, `unknown provider "acme"`},
+		{"empty key", mapKeyring{}, "\n", []string{"openai"}, "no API key given"},
+		{"no keyring", unavailableKeyring{}, "sk", []string{"openai"}, "no keyring available"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			err := Login(context.Background(), tt.keyring, strings.NewReader(tt.stdin), &out, &out, tt.args)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Login() error = %v, want error containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoginStopsWaitingWhenCancelled(t *testing.T) {
+	// Nothing is ever written, as when the user gives up at the prompt.
+	stdin, w := io.Pipe()
+	t.Cleanup(func() { _ = w.Close() })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+
+	keyring := mapKeyring{}
+	err := Login(ctx, keyring, stdin, io.Discard, io.Discard, []string{"openai"})
+	if !errors.Is(err, context.Canceled) || len(keyring) != 0 {
+		t.Errorf("Login() error = %v, keyring = %v, want context.Canceled and nothing stored", err, keyring)
+	}
+}
+
+func TestStatus(t *testing.T) {
+	statuses := []providers.KeyStatus{
+		{Provider: "openrouter", EnvKey: "OPENROUTER_API_KEY"},
+		{Provider: "anthropic", EnvKey: "ANTHROPIC_API_KEY", Source: "ANTHROPIC_API_KEY"},
+		{Provider: "openai", EnvKey: "OPENAI_API_KEY", Source: credentials.SourceKeyring},
+		{Provider: "gemini", EnvKey: "GEMINI_API_KEY", Err: errors.New("credential helper for gemini: exit status 1")},
+	}
+
+	var out bytes.Buffer
+	if err := Status(statuses, &out, nil); err != nil {
+		t.Fatalf("Status() error = %v, want nil", err)
+	}
+
+	want := `PROVIDER    KEY
+openrouter  not set
+anthropic   environment (ANTHROPIC_API_KEY)
+openai      keyring
+gemini      error: credential helper for gemini: exit status 1
+`
+	if out.String() != want {
+		t.Errorf("Status() output =\n%s\nwant\n%s", out.String(), want)
+	}
+}
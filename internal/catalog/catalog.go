@@ -0,0 +1,201 @@
+// Package catalog implements the llm providers and llm models commands,
+// which show the configured providers, check that their keys work and list
+// the models they serve.
+package catalog
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"llm/internal/config"
+	"llm/internal/providers"
+)
+
+// testTimeout bounds each ping sent by Test unless a shorter timeout is
+// configured.
+const testTimeout = 30 * time.Second
+
+// testPrompt asks for the shortest possible reply.
+const testPrompt = "Reply with OK."
+
+// List writes every provider with the source of its API key, marks the one
+// used with the settings s with a star and explains why it was picked.
+func List(s config.Settings, stdout io.Writer, args []string) error {
+	if len(args) > 0 {
+		return fmt.Errorf("usage: llm providers list")
+	}
+
+	sel, selErr := providers.Select(s)
+
+	w := tabwriter.NewWriter(stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "  PROVIDER\tKEY")
+	for _, status := range providers.KeyStatuses(s) {
+		mark := " "
+		if selErr == nil && status.Provider == sel.Provider {
+			mark = "*"
+		}
+		_, _ = fmt.Fprintf(w, "%s %s\t%s\n", mark, status.Provider, status)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if selErr != nil {
+		_, err := fmt.Fprintf(stdout, "\nNo provider is active: %v\n", selErr)
+		return err
+	}
+
+	_, _ = fmt.Fprintf(stdout, "\nActive: %s (%s), %s\n", sel.Provider, sel.Model, sel.Reason)
+	if len(sel.Fallback) > 0 {
+		_, _ = fmt.Fprintf(stdout, "Fallback: %s\n", strings.Join(sel.Fallback, ", "))
+	}
+	return nil
+}
+
+// testResult is the outcome of pinging one provider.
+type testResult struct {
+	provider string
+	model    string
+	latency  time.Duration
+	err      error
+	// skipped is set for providers that could not be pinged.
+	skipped string
+}
+
+// Test sends a tiny request to each provider named in args, or to every
+// provider that is configured, and writes the latency or error of each.
+// The configured model and endpoint are only used for the active
+// provider; others use their defaults, and those without a default model
+// are skipped. Each provider is passed through wrap, when it is not nil,
+// before it is pinged. It fails when any provider does.
+func Test(ctx context.Context, s config.Settings, opts []providers.Option, wrap func(providers.Provider) providers.Provider, stdout io.Writer, args []string) error {
+	names := args
+	if len(names) == 0 {
+		names = providers.Configured(s)
+		if len(names) == 0 {
+			return fmt.Errorf("no provider is configured; set an API key or run llm auth login <provider>")
+		}
+	}
+
+	active := s.Provider
+	if active == "" {
+		if sel, err := providers.Select(s); err == nil {
+			active = sel.Provider
+		}
+	}
+
+	results := make([]testResult, len(names))
+	var wg sync.WaitGroup
+	for i, name := range names {
+		if name != active && providers.DefaultModel(name) == "" {
+			results[i] = testResult{provider: name, model: "-", skipped: "no default model; make it the active provider to test it"}
+			continue
+		}
+
+		ps := testSettings(s, name, name == active)
+		wg.Go(func() {
+			results[i] = ping(ctx, ps, opts, wrap)
+		})
+	}
+	wg.Wait()
+
+	failed := 0
+	w := tabwriter.NewWriter(stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "PROVIDER\tMODEL\tRESULT")
+	for _, r := range results {
+		result := fmt.Sprintf("ok (%s)", r.latency.Round(time.Millisecond))
+		switch {
+		case r.skipped != "":
+			result = "skipped: " + r.skipped
+		case r.err != nil:
+			result = "error: " + r.err.Error()
+			failed++
+		}
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\n", r.provider, r.model, result)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d providers failed", failed, len(results))
+	}
+	return nil
+}
+
+// testSettings returns the settings used to ping the named provider: no
+// retries, continuations or fallbacks, a short reply and a short timeout.
+func testSettings(s config.Settings, name string, active bool) config.Settings {
+	ps := s
+	ps.Provider = name
+	ps.Fallback = nil
+	if !active {
+		ps.Model, ps.Endpoint = "", ""
+	}
+
+	retries := 0
+	ps.Retries = &retries
+	ps.MaxTokens = 16
+	ps.MaxContinuations = 0
+	if ps.Timeout <= 0 || ps.Timeout > testTimeout {
+		ps.Timeout = testTimeout
+	}
+	return ps
+}
+
+func ping(ctx context.Context, s config.Settings, opts []providers.Option, wrap func(providers.Provider) providers.Provider) testResult {
+	r := testResult{provider: s.Provider, model: "-"}
+
+	p, err := providers.Resolve(s, io.Discard, opts...)
+	if err != nil {
+		r.err = err
+		return r
+	}
+	r.model = providers.Describe(p).Model
+	if wrap != nil {
+		p = wrap(p)
+	}
+
+	start := time.Now()
+	_, err = p.Complete(ctx, "", testPrompt)
+	r.latency = time.Since(start)
+
+	// A reply cut short by the token limit still proves the key works.
+	if err != nil && !errors.Is(err, providers.ErrTruncated) {
+		r.err = err
+	}
+	return r
+}
+
+// Models writes the models served by the provider named in args, or by
+// the active provider, one per line. The provider is passed through wrap,
+// when it is not nil, before it is asked.
+func Models(ctx context.Context, s config.Settings, opts []providers.Option, wrap func(providers.Provider) providers.Provider, stdout io.Writer, args []string) error {
+	switch len(args) {
+	case 0:
+	case 1:
+		if args[0] != s.Provider {
+			s.Provider, s.Model, s.Endpoint = args[0], "", ""
+		}
+	default:
+		return fmt.Errorf("usage: llm models [provider]")
+	}
+
+	models, err := providers.ResolveModels(ctx, s, wrap, opts...)
+	if err != nil {
+		return err
+	}
+
+	for _, model := range models {
+		if _, err := fmt.Fprintln(stdout, model); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,138 @@
+package catalog
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"llm/internal/config"
+	"llm/internal/credentials"
+)
+
+func clearAPIKeys(t *testing.T) {
+	t.Helper()
+
+	for _, key := range []string{"OPENROUTER_API_KEY", "OPENCODE_ZEN_API_KEY", "ANTHROPIC_API_KEY", "OPENAI_API_KEY", "AZURE_OPENAI_API_KEY", "GEMINI_API_KEY", "LLM_API_KEY", "LLM_BASE_URL", "LLM_RECORD", "LLM_REPLAY"} {
+		t.Setenv(key, "")
+	}
+
+	keyring := credentials.DefaultKeyring
+	credentials.DefaultKeyring = nil
+	t.Cleanup(func() { credentials.DefaultKeyring = keyring })
+}
+
+func TestList(t *testing.T) {
+	clearAPIKeys(t)
+	t.Setenv("ANTHROPIC_API_KEY", "sk-ant")
+	t.Setenv("GEMINI_API_KEY", "gemini")
+
+	var stdout bytes.Buffer
+	if err := List(config.Settings{Fallback: []string{"gemini"}}, &stdout, nil); err != nil {
+		t.Fatalf("List() error = %v, want nil", err)
+	}
+
+	out := stdout.String()
+	for _, want := range []string{
+		"* anthropic     environment (ANTHROPIC_API_KEY)\n",
+		"  gemini        environment (GEMINI_API_KEY)\n",
+		"  openai        not set\n",
+		"Active: anthropic (claude-haiku-4-5), first provider with a key in the environment (ANTHROPIC_API_KEY)\n",
+		"Fallback: gemini\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("List() output missing %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "sk-ant") {
+		t.Errorf("List() output contains the API key:\n%s", out)
+	}
+}
+
+func TestListWithoutProvider(t *testing.T) {
+	clearAPIKeys(t)
+
+	var stdout bytes.Buffer
+	if err := List(config.Settings{}, &stdout, nil); err != nil {
+		t.Fatalf("List() error = %v, want nil", err)
+	}
+	if !strings.Contains(stdout.String(), "No provider is active: no API key found.") {
+		t.Errorf("List() output =\n%s", stdout.String())
+	}
+}
+
+func TestTest(t *testing.T) {
+	clearAPIKeys(t)
+	t.Setenv("OPENAI_API_KEY", "sk-openai")
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// A reply cut short by the token limit counts as a success.
+		_, _ = w.Write([]byte(`{"choices":[{"message":{"content":"O"},"finish_reason":"length"}]}`))
+	}))
+	t.Cleanup(server.Close)
+
+	s := config.Settings{Provider: "openai", Model: "gpt-4o", Endpoint: server.URL}
+
+	var stdout bytes.Buffer
+	if err := Test(context.Background(), s, nil, nil, &stdout, nil); err != nil {
+		t.Fatalf("Test() error = %v, want nil\n%s", err, stdout.String())
+	}
+	if !strings.Contains(stdout.String(), "openai    gpt-4o  ok (") {
+		t.Errorf("Test() output =\n%s", stdout.String())
+	}
+
+	stdout.Reset()
+	err := Test(context.Background(), s, nil, nil, &stdout, []string{"openai", "gemini"})
+	if err == nil || err.Error() != "1 of 2 providers failed" {
+		t.Errorf("Test() error = %v, want 1 of 2 providers failed", err)
+	}
+	if !strings.Contains(stdout.String(), "gemini    -       error: provider \"gemini\" requires GEMINI_API_KEY") {
+		t.Errorf("Test() output =\n%s", stdout.String())
+	}
+
+	// A local server has no default model to test with unless it is the
+	// active provider.
+	t.Setenv("LLM_BASE_URL", server.URL+"/v1")
+	stdout.Reset()
+	if err := Test(context.Background(), s, nil, nil, &stdout, []string{"openai", "local"}); err != nil {
+		t.Fatalf("Test() error = %v, want nil\n%s", err, stdout.String())
+	}
+	if !strings.Contains(stdout.String(), "local     -       skipped: no default model") {
+		t.Errorf("Test() output =\n%s", stdout.String())
+	}
+}
+
+func TestTestWithoutProvider(t *testing.T) {
+	clearAPIKeys(t)
+
+	if err := Test(context.Background(), config.Settings{}, nil, nil, &bytes.Buffer{}, nil); err == nil {
+		t.Error("Test() error = nil, want an error when no provider is configured")
+	}
+}
+
+func TestModels(t *testing.T) {
+	clearAPIKeys(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/models" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte(`{"data":[{"id":"qwen2.5-coder"},{"id":"llama3.2"}]}`))
+	}))
+	t.Cleanup(server.Close)
+	t.Setenv("LLM_BASE_URL", server.URL+"/v1")
+
+	var stdout bytes.Buffer
+	if err := Models(context.Background(), config.Settings{}, nil, nil, &stdout, nil); err != nil {
+		t.Fatalf("Models() error = %v, want nil", err)
+	}
+	if want := "llama3.2\nqwen2.5-coder\n"; stdout.String() != want {
+		t.Errorf("Models() output = %q, want %q", stdout.String(), want)
+	}
+
+	if err := Models(context.Background(), config.Settings{}, nil, nil, &stdout, []string{"a", "b"}); err == nil {
+		t.Error("Models() with two arguments error = nil, want a usage error")
+	}
+}
@@ -0,0 +1,267 @@
+// Package chat implements llm chat, an interactive conversation with a
+// provider that keeps the history of every turn.
+package chat
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"llm/internal/interrupt"
+	"llm/internal/loading"
+	"llm/internal/providers"
+	"llm/internal/session"
+)
+
+// blockDelimiter starts and ends a block of input spanning several lines.
+const blockDelimiter = `"""`
+
+const help = `Commands:
+  /system [prompt]  show or set the system prompt
+  /model [name]     show or change the model
+  /save <file>      write the conversation to a Markdown file
+  /reset            forget the conversation
+  /help             show this help
+  /exit             leave the chat (or press Ctrl+D)
+
+End a line with \ to continue it, or wrap several lines in """.
+Ctrl+C stops the answer being written.
+`
+
+// Run reads questions from stdin until it ends or ctx is cancelled and
+// streams each answer to stdout. Every question is sent with the
+// conversation so far. Prompts and notices go to stderr. models may be
+// nil, in which case the model cannot be changed.
+func Run(ctx context.Context, provider providers.Provider, models providers.ModelSwitcher, stdin io.Reader, stdout, stderr io.Writer, args []string) error {
+	if len(args) > 0 {
+		return fmt.Errorf("usage: llm chat")
+	}
+
+	c := &chat{provider: provider, models: models, stdout: stdout, stderr: stderr}
+	_, _ = fmt.Fprintf(stderr, "Chatting with %s. Type /help for commands, Ctrl+D to quit.\n", c.current())
+
+	lines := readLines(stdin)
+	for {
+		input, ok, err := c.read(ctx, lines)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return nil
+		}
+
+		switch {
+		case strings.TrimSpace(input) == "":
+			continue
+		case strings.HasPrefix(input, "/"):
+			quit, err := c.command(input)
+			if err != nil {
+				_, _ = fmt.Fprintf(stderr, "Error: %v\n", err)
+			}
+			if quit {
+				return nil
+			}
+		default:
+			if err := c.ask(ctx, input); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+type chat struct {
+	provider providers.Provider
+	models   providers.ModelSwitcher
+	system   string
+	msgs     []providers.Message
+	stdout   io.Writer
+	stderr   io.Writer
+}
+
+// line is a line read from stdin, or the error that ended the input.
+type line struct {
+	text string
+	err  error
+}
+
+// readLines delivers the lines of r on a channel, which is closed at the
+// end of the input, so that reading can be abandoned when ctx is done.
+func readLines(r io.Reader) <-chan line {
+	lines := make(chan line)
+	go func() {
+		defer close(lines)
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(make([]byte, 64*1024), 1<<20)
+		for scanner.Scan() {
+			lines <- line{text: scanner.Text()}
+		}
+		if err := scanner.Err(); err != nil {
+			lines <- line{err: err}
+		}
+	}()
+	return lines
+}
+
+// read returns the next input, joining lines that end with a backslash and
+// blocks wrapped in """. It reports false at the end of the input or when
+// ctx is cancelled.
+func (c *chat) read(ctx context.Context, lines <-chan line) (string, bool, error) {
+	var input []string
+	block := false
+	prompt := "> "
+	for {
+		_, _ = io.WriteString(c.stderr, prompt)
+
+		var l line
+		var ok bool
+		select {
+		case <-ctx.Done():
+			_, _ = fmt.Fprintln(c.stderr)
+			return "", false, nil
+		case l, ok = <-lines:
+		}
+		if !ok {
+			_, _ = fmt.Fprintln(c.stderr)
+			return "", false, nil
+		}
+		if l.err != nil {
+			return "", false, fmt.Errorf("reading input: %w", l.err)
+		}
+
+		prompt = "... "
+		switch {
+		case strings.TrimSpace(l.text) == blockDelimiter:
+			if block || len(input) > 0 {
+				return strings.Join(input, "\n"), true, nil
+			}
+			block = true
+		case block:
+			input = append(input, l.text)
+		case strings.HasSuffix(l.text, `\`):
+			input = append(input, strings.TrimSuffix(l.text, `\`))
+		default:
+			return strings.Join(append(input, l.text), "\n"), true, nil
+		}
+	}
+}
+
+// ask sends question with the conversation so far and streams the answer.
+// Ctrl+C stops the answer and leaves the conversation as it was before the
+// question; other errors are reported without ending the chat.
+func (c *chat) ask(ctx context.Context, question string) error {
+	msgs := append(c.msgs, providers.Message{Role: "user", Content: question})
+
+	turnCtx, closeScope := interrupt.Scope(ctx)
+	defer closeScope()
+
+	ind := loading.Start(c.stderr)
+	wrote := false
+	resp, err := providers.StreamChat(turnCtx, c.provider, c.system, msgs, func(delta string) error {
+		ind.Stop()
+		wrote = true
+		_, err := io.WriteString(c.stdout, delta)
+		return err
+	})
+	ind.Stop()
+
+	if wrote {
+		_, _ = fmt.Fprintln(c.stdout)
+	}
+
+	switch {
+	case ctx.Err() != nil:
+		return nil
+	case turnCtx.Err() != nil:
+		_, _ = fmt.Fprintln(c.stderr, "Cancelled.")
+		return nil
+	case err != nil && !errors.Is(err, providers.ErrTruncated):
+		_, _ = fmt.Fprintf(c.stderr, "Error: %v\n", err)
+		return nil
+	case err != nil:
+		// The partial answer is kept so that the next question can ask
+		// for the rest.
+		_, _ = fmt.Fprintf(c.stderr, "Warning: %v\n", err)
+	}
+
+	c.msgs = append(msgs, providers.Message{Role: "assistant", Content: resp.Text})
+	return nil
+}
+
+// command runs a slash command and reports whether the chat should end.
+func (c *chat) command(input string) (bool, error) {
+	name, arg, _ := strings.Cut(strings.TrimSpace(input), " ")
+	arg = strings.TrimSpace(arg)
+
+	switch name {
+	case "/exit", "/quit":
+		return true, nil
+	case "/help":
+		_, _ = io.WriteString(c.stderr, help)
+	case "/reset":
+		c.msgs = nil
+		_, _ = fmt.Fprintln(c.stderr, "Started a new conversation.")
+	case "/system":
+		if arg == "" {
+			if c.system == "" {
+				_, _ = fmt.Fprintln(c.stderr, "No system prompt is set.")
+			} else {
+				_, _ = fmt.Fprintf(c.stderr, "System prompt: %s\n", c.system)
+			}
+			return false, nil
+		}
+		c.system = arg
+		_, _ = fmt.Fprintln(c.stderr, "System prompt set.")
+	case "/model":
+		if arg == "" {
+			_, _ = fmt.Fprintf(c.stderr, "Using %s.\n", c.current())
+			return false, nil
+		}
+		if c.models == nil {
+			return false, fmt.Errorf("the model cannot be changed in this chat")
+		}
+		if err := c.models.SetModel(arg); err != nil {
+			return false, err
+		}
+		_, _ = fmt.Fprintf(c.stderr, "Using %s.\n", c.current())
+	case "/save":
+		if arg == "" {
+			return false, fmt.Errorf("usage: /save <file>")
+		}
+		if err := c.save(arg); err != nil {
+			return false, err
+		}
+		_, _ = fmt.Fprintf(c.stderr, "Saved the conversation to %s.\n", arg)
+	default:
+		return false, fmt.Errorf("unknown command %s; type /help for the list", name)
+	}
+	return false, nil
+}
+
+// current describes the provider and model in use.
+func (c *chat) current() string {
+	u := providers.Describe(c.provider)
+	if c.models != nil {
+		u = c.models.Current()
+	}
+
+	switch {
+	case u.Model == "":
+		return "the configured provider"
+	case u.Provider == "":
+		return u.Model
+	}
+	return u.Model + " via " + u.Provider
+}
+
+// save writes the conversation to path as Markdown.
+func (c *chat) save(path string) error {
+	var b strings.Builder
+	if err := session.WriteTranscript(&b, c.system, c.msgs); err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(b.String()), 0o644)
+}
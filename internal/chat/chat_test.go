@@ -0,0 +1,203 @@
+package chat
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"llm/internal/interrupt"
+	"llm/internal/providers"
+)
+
+// stubProvider answers with the number of the call and records every
+// conversation it is sent. Answers to questions starting with "cancel"
+// are interrupted, and to questions starting with "fail" fail.
+type stubProvider struct {
+	model   string
+	systems []string
+	calls   [][]providers.Message
+}
+
+func (s *stubProvider) Complete(ctx context.Context, system, userMsg string) (providers.Response, error) {
+	return providers.Response{}, errors.New("Complete should not be called")
+}
+
+func (s *stubProvider) StreamChat(ctx context.Context, system string, msgs []providers.Message, onDelta func(string) error) (providers.Response, error) {
+	s.systems = append(s.systems, system)
+	s.calls = append(s.calls, msgs)
+
+	question := msgs[len(msgs)-1].Content
+	switch {
+	case strings.HasPrefix(question, "cancel"):
+		if err := onDelta("partial"); err != nil {
+			return providers.Response{}, err
+		}
+		interrupt.Interrupt()
+		<-ctx.Done()
+		return providers.Response{}, ctx.Err()
+	case strings.HasPrefix(question, "fail"):
+		return providers.Response{}, errors.New("overloaded")
+	}
+
+	answer := "answer " + string(rune('0'+len(s.calls)))
+	if err := onDelta(answer); err != nil {
+		return providers.Response{}, err
+	}
+	return providers.Response{Text: answer}, nil
+}
+
+type stubSwitcher struct {
+	model string
+}
+
+func (s *stubSwitcher) Current() providers.Usage {
+	return providers.Usage{Provider: "openai", Model: s.model}
+}
+
+func (s *stubSwitcher) SetModel(model string) error {
+	if model == "missing" {
+		return errors.New("unknown model")
+	}
+	s.model = model
+	return nil
+}
+
+func run(t *testing.T, p providers.Provider, models providers.ModelSwitcher, input string) (string, string) {
+	t.Helper()
+
+	var stdout, stderr bytes.Buffer
+	if err := Run(context.Background(), p, models, strings.NewReader(input), &stdout, &stderr, nil); err != nil {
+		t.Fatalf("Run() error = %v, want nil", err)
+	}
+	return stdout.String(), stderr.String()
+}
+
+func TestRunKeepsConversation(t *testing.T) {
+	p := &stubProvider{}
+	stdout, _ := run(t, p, nil, "What is Go?\n\nAnd Rust?\n")
+
+	if stdout != "answer 1\nanswer 2\n" {
+		t.Errorf("stdout = %q, want both answers", stdout)
+	}
+
+	want := []providers.Message{
+		{Role: "user", Content: "What is Go?"},
+		{Role: "assistant", Content: "answer 1"},
+		{Role: "user", Content: "And Rust?"},
+	}
+	if len(p.calls) != 2 || !reflect.DeepEqual(p.calls[1], want) {
+		t.Errorf("second call = %+v, want %+v", p.calls[len(p.calls)-1], want)
+	}
+}
+
+func TestRunReadsMultiLineInput(t *testing.T) {
+	p := &stubProvider{}
+	run(t, p, nil, "first \\\nsecond\n\"\"\"\nfunc main() {\n}\n\"\"\"\n")
+
+	var got []string
+	for _, call := range p.calls {
+		got = append(got, call[len(call)-1].Content)
+	}
+	if want := []string{"first \nsecond", "func main() {\n}"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("questions = %q, want %q", got, want)
+	}
+}
+
+func TestRunCancelsAnswerWithoutExiting(t *testing.T) {
+	p := &stubProvider{}
+	stdout, stderr := run(t, p, nil, "cancel this\nnext\n")
+
+	if !strings.Contains(stderr, "Cancelled.") || stdout != "partial\nanswer 2\n" {
+		t.Errorf("stdout = %q, stderr = %q, want the partial answer cancelled and the next one shown", stdout, stderr)
+	}
+
+	want := []providers.Message{{Role: "user", Content: "next"}}
+	if len(p.calls) != 2 || !reflect.DeepEqual(p.calls[1], want) {
+		t.Errorf("second call = %+v, want the cancelled turn dropped", p.calls[len(p.calls)-1])
+	}
+}
+
+func TestRunReportsErrorsAndContinues(t *testing.T) {
+	p := &stubProvider{}
+	stdout, stderr := run(t, p, nil, "fail please\n/nope\nhello\n")
+
+	if !strings.Contains(stderr, "Error: overloaded\n") || !strings.Contains(stderr, "Error: unknown command /nope") {
+		t.Errorf("stderr = %q, want both errors reported", stderr)
+	}
+	if stdout != "answer 2\n" || len(p.calls[1]) != 1 {
+		t.Errorf("stdout = %q, last call = %+v, want the failed turn dropped", stdout, p.calls[len(p.calls)-1])
+	}
+}
+
+func TestRunCommands(t *testing.T) {
+	p := &stubProvider{}
+	models := &stubSwitcher{model: "gpt-4o-mini"}
+	path := filepath.Join(t.TempDir(), "chat.md")
+
+	input := strings.Join([]string{
+		"/system Answer in one word.",
+		"/system",
+		"first",
+		"/reset",
+		"second",
+		"/model gpt-4o",
+		"/model missing",
+		"/save " + path,
+		"/exit",
+		"never sent",
+	}, "\n")
+	_, stderr := run(t, p, models, input)
+
+	for _, want := range []string{
+		"Chatting with gpt-4o-mini via openai.",
+		"System prompt: Answer in one word.\n",
+		"Started a new conversation.\n",
+		"Using gpt-4o via openai.\n",
+		"Error: unknown model\n",
+	} {
+		if !strings.Contains(stderr, want) {
+			t.Errorf("stderr missing %q:\n%s", want, stderr)
+		}
+	}
+
+	if len(p.calls) != 2 || len(p.calls[1]) != 1 || p.systems[1] != "Answer in one word." {
+		t.Errorf("calls = %+v with systems %q, want two single-turn calls with the system prompt", p.calls, p.systems)
+	}
+
+	saved, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("reading saved chat: %v", err)
+	}
+	if want := "## System\n\nAnswer in one word.\n\n## You\n\nsecond\n\n## Assistant\n\nanswer 2\n\n"; string(saved) != want {
+		t.Errorf("saved chat = %q, want %q", saved, want)
+	}
+}
+
+func TestRunCannotSwitchModelWithoutSwitcher(t *testing.T) {
+	_, stderr := run(t, &stubProvider{}, nil, "/model gpt-4o\n")
+
+	if !strings.Contains(stderr, "Error: the model cannot be changed in this chat") {
+		t.Errorf("stderr = %q", stderr)
+	}
+}
+
+func TestRunStopsWhenContextIsCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// Nothing is ever written to the pipe, so only the context ends the chat.
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = r.Close(); _ = w.Close() })
+
+	if err := Run(ctx, &stubProvider{}, nil, r, &bytes.Buffer{}, &bytes.Buffer{}, nil); err != nil {
+		t.Errorf("Run() error = %v, want nil", err)
+	}
+}
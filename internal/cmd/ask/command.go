@@ -10,12 +10,12 @@ import (
 
 const (
 	Name        = "ask"
-	Usage       = "ask <question>"
-	Description = "Ask a question"
+	Usage       = "ask [-c] <question>"
+	Description = "Ask a question, with files (-f) or piped input as context"
 )
 
 var RunFunc = ask.Run
 
-func Run(ctx context.Context, provider providers.Provider, stdout, stderr io.Writer, args []string) error {
-	return RunFunc(ctx, provider, stdout, stderr, args)
+func Run(ctx context.Context, provider providers.Provider, opts ask.Options, stdout, stderr io.Writer, args []string) error {
+	return RunFunc(ctx, provider, opts, stdout, stderr, args)
 }
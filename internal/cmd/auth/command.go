@@ -0,0 +1,7 @@
+package authcmd
+
+const (
+	Name        = "auth"
+	Usage       = "auth <subcommand>"
+	Description = "Manage provider API keys"
+)
@@ -0,0 +1,21 @@
+package logincmd
+
+import (
+	"context"
+	"io"
+
+	"llm/internal/auth"
+	"llm/internal/credentials"
+)
+
+const (
+	Name        = "login"
+	Usage       = "login <provider>"
+	Description = "Store a provider API key in the keyring"
+)
+
+var RunFunc = auth.Login
+
+func Run(ctx context.Context, stdin io.Reader, stdout, stderr io.Writer, args []string) error {
+	return RunFunc(ctx, credentials.DefaultKeyring, stdin, stdout, stderr, args)
+}
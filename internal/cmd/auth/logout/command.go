@@ -0,0 +1,21 @@
+package logoutcmd
+
+import (
+	"context"
+	"io"
+
+	"llm/internal/auth"
+	"llm/internal/credentials"
+)
+
+const (
+	Name        = "logout"
+	Usage       = "logout <provider>"
+	Description = "Remove a provider API key from the keyring"
+)
+
+var RunFunc = auth.Logout
+
+func Run(ctx context.Context, stdout io.Writer, args []string) error {
+	return RunFunc(credentials.DefaultKeyring, stdout, args)
+}
@@ -0,0 +1,22 @@
+package statuscmd
+
+import (
+	"context"
+	"io"
+
+	"llm/internal/auth"
+	"llm/internal/config"
+	"llm/internal/providers"
+)
+
+const (
+	Name        = "status"
+	Usage       = "status"
+	Description = "Show where each provider API key comes from"
+)
+
+var RunFunc = auth.Status
+
+func Run(ctx context.Context, settings config.Settings, stdout io.Writer, args []string) error {
+	return RunFunc(providers.KeyStatuses(settings), stdout, args)
+}
@@ -0,0 +1,33 @@
+package clearcmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"llm/internal/providers"
+)
+
+const (
+	Name        = "clear"
+	Usage       = "clear"
+	Description = "Delete all cached responses"
+)
+
+func Run(ctx context.Context, cacheDir string, stdout io.Writer, args []string) error {
+	if len(args) > 0 {
+		return fmt.Errorf("usage: llm cache clear")
+	}
+
+	if cacheDir == "" {
+		return fmt.Errorf("response cache unavailable: could not determine the cache directory")
+	}
+
+	n, err := providers.NewResponseCache(cacheDir, 0, 0).Clear()
+	if err != nil {
+		return fmt.Errorf("clearing cache: %w", err)
+	}
+
+	_, err = fmt.Fprintf(stdout, "Removed %d cached responses\n", n)
+	return err
+}
@@ -0,0 +1,7 @@
+package cachecmd
+
+const (
+	Name        = "cache"
+	Usage       = "cache <subcommand>"
+	Description = "Manage cached responses"
+)
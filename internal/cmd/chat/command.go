@@ -0,0 +1,21 @@
+package chatcmd
+
+import (
+	"context"
+	"io"
+
+	"llm/internal/chat"
+	"llm/internal/providers"
+)
+
+const (
+	Name        = "chat"
+	Usage       = "chat"
+	Description = "Start an interactive conversation"
+)
+
+var RunFunc = chat.Run
+
+func Run(ctx context.Context, provider providers.Provider, models providers.ModelSwitcher, stdin io.Reader, stdout, stderr io.Writer, args []string) error {
+	return RunFunc(ctx, provider, models, stdin, stdout, stderr, args)
+}
@@ -5,24 +5,95 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"os"
+	"strings"
 
+	"llm/internal/ask"
 	askcmd "llm/internal/cmd/ask"
+	authcmd "llm/internal/cmd/auth"
+	logincmd "llm/internal/cmd/auth/login"
+	logoutcmd "llm/internal/cmd/auth/logout"
+	statuscmd "llm/internal/cmd/auth/status"
+	cachecmd "llm/internal/cmd/cache"
+	clearcmd "llm/internal/cmd/cache/clear"
+	chatcmd "llm/internal/cmd/chat"
 	commitcmd "llm/internal/cmd/commit"
+	explaincmd "llm/internal/cmd/explain"
 	ghcmd "llm/internal/cmd/gh"
 	prcmd "llm/internal/cmd/gh/pr"
+	hookcmd "llm/internal/cmd/hook"
+	modelscmd "llm/internal/cmd/models"
+	providerscmd "llm/internal/cmd/providers"
+	listcmd "llm/internal/cmd/providers/list"
+	testcmd "llm/internal/cmd/providers/test"
+	sessionscmd "llm/internal/cmd/sessions"
+	sessionslistcmd "llm/internal/cmd/sessions/list"
+	rmcmd "llm/internal/cmd/sessions/rm"
+	showcmd "llm/internal/cmd/sessions/show"
+	shcmd "llm/internal/cmd/sh"
+	usagecmd "llm/internal/cmd/usage"
+	"llm/internal/config"
 	"llm/internal/gh"
 	"llm/internal/git"
 	"llm/internal/providers"
+	"llm/internal/session"
+	"llm/internal/usage"
 )
 
 var ErrUnknownCommand = errors.New("unknown command")
 
+// Flags holds the global flags given before the command name. They take
+// precedence over the environment and config files.
+type Flags struct {
+	Provider string
+	Model    string
+	Usage    bool
+	// OverBudget allows calls after a configured budget is exhausted.
+	OverBudget bool
+	// NoCache bypasses the response cache.
+	NoCache bool
+	// Debug logs provider requests and responses.
+	Debug bool
+}
+
+func (f Flags) settings() config.Settings {
+	s := config.Settings{
+		Provider: f.Provider,
+		Model:    f.Model,
+	}
+	if f.Usage {
+		s.Usage = &f.Usage
+	}
+	if f.Debug {
+		s.Debug = &f.Debug
+	}
+	return s
+}
+
+// Dependencies are passed to every command handler. When Provider is nil it
+// is resolved from Config and Flags for the command being run. Every call
+// made through it is recorded in Ledger when one is set, and responses are
+// cached in CacheDir when it is not empty. Conversations are saved in
+// Sessions when it is set.
 type Dependencies struct {
 	Provider providers.Provider
+	Config   *config.Config
+	Flags    Flags
+	Ledger   *usage.Ledger
+	CacheDir string
+	Sessions *session.Store
+	Stdin    io.Reader
 	Stdout   io.Writer
 	Stderr   io.Writer
 	Git      git.Client
 	GH       gh.Client
+
+	// models changes the model of Provider when it was resolved from the
+	// settings rather than given.
+	models *modelSwitch
+	// configured is the provider and model of Provider before it is
+	// wrapped by the ledger and budget, which cannot describe it.
+	configured providers.Usage
 }
 
 type Handler func(ctx context.Context, deps Dependencies, args []string) error
@@ -33,6 +104,23 @@ type Command struct {
 	Description string
 	Run         Handler
 	Subcommands []*Command
+	// NoProvider marks commands that never call a provider, so none is
+	// resolved for them.
+	NoProvider bool
+	// Cached marks commands whose answer should only change with their
+	// input, such as a commit message for a diff. Their responses are
+	// cached unless the cache setting turns it off; other commands are
+	// only cached when it turns it on.
+	Cached bool
+	// SwitchesModel marks commands that may change the model while they
+	// run. The provider resolved for them can be changed through
+	// Dependencies.
+	SwitchesModel bool
+	// Model returns the provider and model that the arguments call for,
+	// such as those a continued conversation was held with. The model
+	// replaces the configured one when the provider is the one resolved
+	// and no model was given on the command line.
+	Model func(deps Dependencies, args []string) (provider, model string)
 }
 
 type Registry struct {
@@ -48,14 +136,62 @@ func NewRegistry() *Registry {
 				Name:        askcmd.Name,
 				Usage:       askcmd.Usage,
 				Description: askcmd.Description,
+				Model: func(deps Dependencies, args []string) (string, string) {
+					return ask.SessionModel(deps.Sessions, args)
+				},
+				Run: func(ctx context.Context, deps Dependencies, args []string) error {
+					opts := ask.Options{
+						Sessions: deps.Sessions,
+						Stdin:    deps.Stdin,
+						Provider: deps.configured.Provider,
+						Model:    deps.configured.Model,
+					}
+					return askcmd.Run(ctx, deps.Provider, opts, deps.Stdout, deps.Stderr, args)
+				},
+			},
+			{
+				Name:        shcmd.Name,
+				Usage:       shcmd.Usage,
+				Description: shcmd.Description,
+				Run: func(ctx context.Context, deps Dependencies, args []string) error {
+					return shcmd.Run(ctx, deps.Provider, deps.Stdin, deps.Stdout, deps.Stderr, args)
+				},
+			},
+			{
+				Name:        explaincmd.Name,
+				Usage:       explaincmd.Usage,
+				Description: explaincmd.Description,
+				Run: func(ctx context.Context, deps Dependencies, args []string) error {
+					return explaincmd.Run(ctx, deps.Provider, deps.Stdin, deps.Stdout, deps.Stderr, args)
+				},
+			},
+			{
+				Name:        hookcmd.Name,
+				Usage:       hookcmd.Usage,
+				Description: hookcmd.Description,
+				NoProvider:  true,
 				Run: func(ctx context.Context, deps Dependencies, args []string) error {
-					return askcmd.Run(ctx, deps.Provider, deps.Stdout, deps.Stderr, args)
+					return hookcmd.Run(ctx, deps.Stdout, args)
+				},
+			},
+			{
+				Name:          chatcmd.Name,
+				Usage:         chatcmd.Usage,
+				Description:   chatcmd.Description,
+				SwitchesModel: true,
+				Run: func(ctx context.Context, deps Dependencies, args []string) error {
+					var models providers.ModelSwitcher
+					if deps.models != nil {
+						models = deps.models
+					}
+					return chatcmd.Run(ctx, deps.Provider, models, deps.Stdin, deps.Stdout, deps.Stderr, args)
 				},
 			},
 			{
 				Name:        commitcmd.Name,
 				Usage:       commitcmd.Usage,
 				Description: commitcmd.Description,
+				Cached:      true,
 				Run: func(ctx context.Context, deps Dependencies, args []string) error {
 					return commitcmd.Run(ctx, deps.Provider, deps.Git, deps.Stderr, args)
 				},
@@ -69,12 +205,162 @@ func NewRegistry() *Registry {
 						Name:        prcmd.Name,
 						Usage:       prcmd.Usage,
 						Description: prcmd.Description,
+						Cached:      true,
 						Run: func(ctx context.Context, deps Dependencies, args []string) error {
 							return prcmd.Run(ctx, deps.Provider, deps.GH, deps.Stdout, deps.Stderr, args)
 						},
 					},
 				},
 			},
+			{
+				Name:        cachecmd.Name,
+				Usage:       cachecmd.Usage,
+				Description: cachecmd.Description,
+				Subcommands: []*Command{
+					{
+						Name:        clearcmd.Name,
+						Usage:       clearcmd.Usage,
+						Description: clearcmd.Description,
+						NoProvider:  true,
+						Run: func(ctx context.Context, deps Dependencies, args []string) error {
+							return clearcmd.Run(ctx, deps.CacheDir, deps.Stdout, args)
+						},
+					},
+				},
+			},
+			{
+				Name:        authcmd.Name,
+				Usage:       authcmd.Usage,
+				Description: authcmd.Description,
+				Subcommands: []*Command{
+					{
+						Name:        logincmd.Name,
+						Usage:       logincmd.Usage,
+						Description: logincmd.Description,
+						NoProvider:  true,
+						Run: func(ctx context.Context, deps Dependencies, args []string) error {
+							return logincmd.Run(ctx, deps.Stdin, deps.Stdout, deps.Stderr, args)
+						},
+					},
+					{
+						Name:        logoutcmd.Name,
+						Usage:       logoutcmd.Usage,
+						Description: logoutcmd.Description,
+						NoProvider:  true,
+						Run: func(ctx context.Context, deps Dependencies, args []string) error {
+							return logoutcmd.Run(ctx, deps.Stdout, args)
+						},
+					},
+					{
+						Name:        statuscmd.Name,
+						Usage:       statuscmd.Usage,
+						Description: statuscmd.Description,
+						NoProvider:  true,
+						Run: func(ctx context.Context, deps Dependencies, args []string) error {
+							settings := deps.Config.Resolve("auth status").Merge(deps.Flags.settings())
+							return statuscmd.Run(ctx, settings, deps.Stdout, args)
+						},
+					},
+				},
+			},
+			{
+				Name:        providerscmd.Name,
+				Usage:       providerscmd.Usage,
+				Description: providerscmd.Description,
+				Subcommands: []*Command{
+					{
+						Name:        listcmd.Name,
+						Usage:       listcmd.Usage,
+						Description: listcmd.Description,
+						NoProvider:  true,
+						Run: func(ctx context.Context, deps Dependencies, args []string) error {
+							settings := deps.Config.Resolve("providers list").Merge(deps.Flags.settings())
+							return listcmd.Run(ctx, settings, deps.Stdout, args)
+						},
+					},
+					{
+						Name:        testcmd.Name,
+						Usage:       testcmd.Usage,
+						Description: testcmd.Description,
+						NoProvider:  true,
+						Run: func(ctx context.Context, deps Dependencies, args []string) error {
+							settings := deps.Config.Resolve("providers test").Merge(deps.Flags.settings())
+							track, err := tracker(deps, settings, "providers test")
+							if err != nil {
+								return err
+							}
+							opts, closeOpts, err := providerOptions(settings, deps.Stderr)
+							if err != nil {
+								return err
+							}
+							defer closeOpts()
+							return testcmd.Run(ctx, settings, opts, track, deps.Stdout, args)
+						},
+					},
+				},
+			},
+			{
+				Name:        modelscmd.Name,
+				Usage:       modelscmd.Usage,
+				Description: modelscmd.Description,
+				NoProvider:  true,
+				Run: func(ctx context.Context, deps Dependencies, args []string) error {
+					settings := deps.Config.Resolve("models").Merge(deps.Flags.settings())
+					track, err := tracker(deps, settings, "models")
+					if err != nil {
+						return err
+					}
+					opts, closeOpts, err := providerOptions(settings, deps.Stderr)
+					if err != nil {
+						return err
+					}
+					defer closeOpts()
+					return modelscmd.Run(ctx, settings, opts, track, deps.Stdout, args)
+				},
+			},
+			{
+				Name:        sessionscmd.Name,
+				Usage:       sessionscmd.Usage,
+				Description: sessionscmd.Description,
+				Subcommands: []*Command{
+					{
+						Name:        sessionslistcmd.Name,
+						Usage:       sessionslistcmd.Usage,
+						Description: sessionslistcmd.Description,
+						NoProvider:  true,
+						Run: func(ctx context.Context, deps Dependencies, args []string) error {
+							return sessionslistcmd.Run(ctx, deps.Sessions, deps.Stdout, args)
+						},
+					},
+					{
+						Name:        showcmd.Name,
+						Usage:       showcmd.Usage,
+						Description: showcmd.Description,
+						NoProvider:  true,
+						Run: func(ctx context.Context, deps Dependencies, args []string) error {
+							return showcmd.Run(ctx, deps.Sessions, deps.Stdout, args)
+						},
+					},
+					{
+						Name:        rmcmd.Name,
+						Usage:       rmcmd.Usage,
+						Description: rmcmd.Description,
+						NoProvider:  true,
+						Run: func(ctx context.Context, deps Dependencies, args []string) error {
+							return rmcmd.Run(ctx, deps.Sessions, deps.Stdout, args)
+						},
+					},
+				},
+			},
+			{
+				Name:        usagecmd.Name,
+				Usage:       usagecmd.Usage,
+				Description: usagecmd.Description,
+				NoProvider:  true,
+				Run: func(ctx context.Context, deps Dependencies, args []string) error {
+					return usagecmd.Run(ctx, deps.Ledger, deps.Stdout, args)
+				},
+			},
 		},
 	}
 }
@@ -83,20 +369,34 @@ func UsageTo(w io.Writer) {
 	defaultRegistry.UsageTo(w)
 }
 
-func Run(ctx context.Context, provider providers.Provider, stdout, stderr io.Writer, args []string) error {
+func Run(ctx context.Context, cfg *config.Config, flags Flags, stdout, stderr io.Writer, args []string) error {
 	deps := Dependencies{
-		Provider: provider,
-		Stdout:   stdout,
-		Stderr:   stderr,
-		Git:      &git.RealClient{},
-		GH:       &gh.RealClient{},
+		Config: cfg,
+		Flags:  flags,
+		Stdin:  os.Stdin,
+		Stdout: stdout,
+		Stderr: stderr,
+		Git:    &git.RealClient{},
+		GH:     &gh.RealClient{},
+	}
+
+	if path, err := usage.DefaultPath(); err == nil {
+		deps.Ledger = usage.NewLedger(path)
+	}
+
+	if dir, err := providers.DefaultCacheDir(); err == nil {
+		deps.CacheDir = dir
+	}
+
+	if dir, err := session.DefaultDir(); err == nil {
+		deps.Sessions = session.NewStore(dir)
 	}
 
 	return defaultRegistry.Run(ctx, deps, args)
 }
 
 func (r *Registry) UsageTo(w io.Writer) {
-	_, _ = fmt.Fprintf(w, "Usage: llm <command> [options]\n\n")
+	_, _ = fmt.Fprintf(w, "Usage: llm [options] <command> [args]\n\n")
 	_, _ = fmt.Fprintf(w, "Commands:\n")
 	r.writeCommands(w, r.commands, 2)
 }
@@ -134,7 +434,155 @@ func runCommand(ctx context.Context, deps Dependencies, cmd *Command, path strin
 		return nil
 	}
 
-	return cmd.Run(ctx, deps, args)
+	if cmd.NoProvider {
+		return cmd.Run(ctx, deps, args)
+	}
+
+	settings := deps.Config.Resolve(path).Merge(deps.Flags.settings())
+
+	if deps.Provider == nil {
+		opts, closeOpts, err := providerOptions(settings, deps.Stderr)
+		if err != nil {
+			return err
+		}
+		defer closeOpts()
+
+		provider, err := providers.Resolve(settings, deps.Stderr, opts...)
+		if err != nil {
+			return err
+		}
+
+		if cmd.Model != nil && deps.Flags.Model == "" {
+			name, model := cmd.Model(deps, args)
+			if d := providers.Describe(provider); model != "" && d.Provider == name && d.Model != model {
+				s := settings
+				s.Model = model
+				if provider, err = providers.Resolve(s, deps.Stderr, opts...); err != nil {
+					return err
+				}
+			}
+		}
+		deps.Provider = provider
+
+		// The switch sits under the other wrappers so that changing the
+		// model keeps the cache, ledger and budget in place.
+		if cmd.SwitchesModel {
+			deps.models = &modelSwitch{
+				Switch: providers.NewSwitch(provider),
+				resolve: func(model string) (providers.Provider, error) {
+					s := settings
+					s.Model = model
+					return providers.Resolve(s, deps.Stderr, opts...)
+				},
+			}
+			deps.Provider = deps.models.Switch
+		}
+	}
+
+	cached := cmd.Cached
+	if settings.Cache != nil {
+		cached = *settings.Cache
+	}
+	if deps.CacheDir != "" && !deps.Flags.NoCache && cached {
+		cache := providers.NewResponseCache(deps.CacheDir, settings.CacheTTL, settings.CacheMaxSize)
+		deps.Provider = cache.Wrap(deps.Provider)
+	}
+
+	// The recorder hides the provider's name and model, so look them up
+	// first.
+	deps.configured = providers.Describe(deps.Provider)
+
+	track, err := tracker(deps, settings, path)
+	if err != nil {
+		return err
+	}
+	if !settings.Budget.IsZero() && !deps.Flags.OverBudget {
+		if warning := usage.CostWarning(settings.Budget, deps.configured.Provider); warning != "" {
+			_, _ = fmt.Fprintf(deps.Stderr, "llm: %s\n", warning)
+		}
+	}
+	deps.Provider = track(deps.Provider)
+
+	if settings.Usage == nil || !*settings.Usage {
+		return cmd.Run(ctx, deps, args)
+	}
+
+	meter := providers.NewMeter(deps.Provider)
+	deps.Provider = meter
+	err = cmd.Run(ctx, deps, args)
+	if meter.Calls() > 0 {
+		_, _ = fmt.Fprintf(deps.Stderr, "usage: %s\n", meter.Usage())
+	}
+
+	return err
+}
+
+// tracker returns a function that wraps a provider so that its calls are
+// recorded in the ledger under path and refused once the budget is
+// reached. It fails when a budget is set but there is no ledger to check
+// it against, unless --over-budget is given.
+func tracker(deps Dependencies, settings config.Settings, path string) (func(providers.Provider) providers.Provider, error) {
+	guard := !settings.Budget.IsZero() && !deps.Flags.OverBudget
+	if guard && deps.Ledger == nil {
+		return nil, fmt.Errorf("a budget is configured but the usage ledger is unavailable; pass --over-budget to run anyway")
+	}
+
+	return func(p providers.Provider) providers.Provider {
+		if deps.Ledger != nil {
+			p = usage.NewRecorder(p, deps.Ledger, path, deps.Stderr)
+		}
+		if guard {
+			p = usage.NewGuard(p, deps.Ledger, settings.Budget)
+		}
+		return p
+	}, nil
+}
+
+// modelSwitch changes the model of a resolved provider for the rest of a
+// command.
+type modelSwitch struct {
+	*providers.Switch
+	resolve func(model string) (providers.Provider, error)
+}
+
+func (m *modelSwitch) Current() providers.Usage {
+	return providers.Describe(m.Switch)
+}
+
+func (m *modelSwitch) SetModel(model string) error {
+	p, err := m.resolve(model)
+	if err != nil {
+		return err
+	}
+	m.Set(p)
+	return nil
+}
+
+// providerOptions returns the options that the flags add to providers
+// resolved from settings, and a function that releases them.
+func providerOptions(settings config.Settings, stderr io.Writer) ([]providers.Option, func(), error) {
+	if settings.Debug == nil || !*settings.Debug {
+		return nil, func() {}, nil
+	}
+
+	debug, closeDebug, err := openDebugLog(settings.DebugFile, stderr)
+	if err != nil {
+		return nil, nil, err
+	}
+	return []providers.Option{providers.WithDebugLog(debug)}, closeDebug, nil
+}
+
+// openDebugLog returns stderr, or path opened for appending when it is set.
+func openDebugLog(path string, stderr io.Writer) (io.Writer, func(), error) {
+	if path == "" {
+		return stderr, func() {}, nil
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, nil, fmt.Errorf("opening debug log: %w", err)
+	}
+	return f, func() { _ = f.Close() }, nil
 }
 
 func findCommand(commands []*Command, name string) *Command {
@@ -170,6 +618,10 @@ func spaces(n int) string {
 }
 
 func normalizeDependencies(deps Dependencies) Dependencies {
+	if deps.Stdin == nil {
+		deps.Stdin = strings.NewReader("")
+	}
+
 	if deps.Stdout == nil {
 		deps.Stdout = io.Discard
 	}
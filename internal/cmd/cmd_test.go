@@ -3,24 +3,41 @@ package cmd
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
+	"llm/internal/ask"
+	"llm/internal/config"
+	"llm/internal/credentials"
 	"llm/internal/gh"
 	"llm/internal/git"
 	"llm/internal/providers"
+	"llm/internal/session"
+	"llm/internal/usage"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"reflect"
 	"strings"
 	"testing"
+	"time"
 
 	askcmd "llm/internal/cmd/ask"
+	chatcmd "llm/internal/cmd/chat"
 	commitcmd "llm/internal/cmd/commit"
+	explaincmd "llm/internal/cmd/explain"
 	prcmd "llm/internal/cmd/gh/pr"
+	modelscmd "llm/internal/cmd/models"
+	testcmd "llm/internal/cmd/providers/test"
+	shcmd "llm/internal/cmd/sh"
 )
 
 type stubProvider struct{}
 
-func (s *stubProvider) Complete(ctx context.Context, system, userMsg string) (string, error) {
-	return "", nil
+func (s *stubProvider) Complete(ctx context.Context, system, userMsg string) (providers.Response, error) {
+	return providers.Response{}, nil
 }
 
 type stubGitClient struct{}
@@ -103,7 +120,7 @@ func TestRunRoutesAskAndCommit(t *testing.T) {
 
 	t.Run("routes ask", func(t *testing.T) {
 		var gotArgs []string
-		askcmd.RunFunc = func(ctx context.Context, gotProvider providers.Provider, output io.Writer, stderr io.Writer, args []string) error {
+		askcmd.RunFunc = func(ctx context.Context, gotProvider providers.Provider, opts ask.Options, output io.Writer, stderr io.Writer, args []string) error {
 			if gotProvider != provider {
 				t.Fatalf("ask provider = %#v, want %#v", gotProvider, provider)
 			}
@@ -142,6 +159,68 @@ func TestRunRoutesAskAndCommit(t *testing.T) {
 	})
 }
 
+func TestRunRoutesSh(t *testing.T) {
+	originalShRun := shcmd.RunFunc
+	t.Cleanup(func() { shcmd.RunFunc = originalShRun })
+
+	provider := &stubProvider{}
+	stdin := strings.NewReader("q\n")
+	deps := Dependencies{Provider: provider, Stdin: stdin}
+
+	var gotArgs []string
+	shcmd.RunFunc = func(ctx context.Context, gotProvider providers.Provider, gotStdin io.Reader, stdout, stderr io.Writer, args []string) error {
+		if gotProvider != provider || gotStdin != stdin {
+			t.Fatalf("sh got provider %#v and stdin %#v, want the dependencies", gotProvider, gotStdin)
+		}
+		gotArgs = args
+		return nil
+	}
+
+	if err := defaultRegistry.Run(context.Background(), deps, []string{"sh", "list", "big", "files"}); err != nil {
+		t.Fatalf("Run() error = %v, want nil", err)
+	}
+	if !reflect.DeepEqual(gotArgs, []string{"list", "big", "files"}) {
+		t.Errorf("sh args = %v, want %v", gotArgs, []string{"list", "big", "files"})
+	}
+}
+
+func TestRunRoutesExplainAndHook(t *testing.T) {
+	originalExplainRun := explaincmd.RunFunc
+	t.Cleanup(func() { explaincmd.RunFunc = originalExplainRun })
+
+	provider := &stubProvider{}
+	var gotArgs []string
+	explaincmd.RunFunc = func(ctx context.Context, gotProvider providers.Provider, stdin io.Reader, stdout, stderr io.Writer, args []string) error {
+		if gotProvider != provider {
+			t.Fatalf("explain provider = %#v, want %#v", gotProvider, provider)
+		}
+		gotArgs = args
+		return nil
+	}
+
+	deps := Dependencies{Provider: provider}
+	if err := defaultRegistry.Run(context.Background(), deps, []string{"explain", "--", "go", "test"}); err != nil {
+		t.Fatalf("Run(explain) error = %v, want nil", err)
+	}
+	if !reflect.DeepEqual(gotArgs, []string{"--", "go", "test"}) {
+		t.Errorf("explain args = %v, want %v", gotArgs, []string{"--", "go", "test"})
+	}
+
+	// The hook is evaluated on every shell start, so it must not need a
+	// provider.
+	var stdout bytes.Buffer
+	deps = Dependencies{
+		Config: &config.Config{Env: config.Settings{Provider: "acme"}},
+		Stdout: &stdout,
+	}
+	if err := defaultRegistry.Run(context.Background(), deps, []string{"hook", "bash"}); err != nil {
+		t.Fatalf("Run(hook) error = %v, want nil", err)
+	}
+	if !strings.Contains(stdout.String(), "PROMPT_COMMAND=") {
+		t.Errorf("hook output =\n%s", stdout.String())
+	}
+}
+
 func TestRunGHSubcommands(t *testing.T) {
 	originalGHRun := prcmd.RunFunc
 	originalGHCreatePullRequest := prcmd.CreatePullRequestFunc
@@ -268,3 +347,743 @@ func TestRunReturnsUnknownCommandForUnknownTopLevel(t *testing.T) {
 		t.Fatalf("Run() error = %v, want ErrUnknownCommand", err)
 	}
 }
+
+func TestRunResolvesProviderFromConfig(t *testing.T) {
+	originalAskRun := askcmd.RunFunc
+	t.Cleanup(func() {
+		askcmd.RunFunc = originalAskRun
+	})
+
+	t.Setenv("OPENROUTER_API_KEY", "r")
+	t.Setenv("OPENAI_API_KEY", "o")
+
+	var gotProvider providers.Provider
+	askcmd.RunFunc = func(ctx context.Context, provider providers.Provider, opts ask.Options, output io.Writer, stderr io.Writer, args []string) error {
+		gotProvider = provider
+		return nil
+	}
+
+	deps := Dependencies{
+		Config: &config.Config{
+			User: &config.File{
+				Commands: map[string]config.Settings{"ask": {Provider: "openai"}},
+			},
+		},
+	}
+
+	if err := defaultRegistry.Run(context.Background(), deps, []string{"ask", "hi"}); err != nil {
+		t.Fatalf("Run() error = %v, want nil", err)
+	}
+
+	if _, ok := gotProvider.(*providers.OpenAIProvider); !ok {
+		t.Errorf("ask provider = %T, want *providers.OpenAIProvider", gotProvider)
+	}
+
+	deps.Config = &config.Config{Env: config.Settings{Provider: "acme"}}
+	err := defaultRegistry.Run(context.Background(), deps, []string{"ask", "hi"})
+	if err == nil || !strings.Contains(err.Error(), `unknown provider "acme"`) {
+		t.Errorf("Run() error = %v, want unknown provider error", err)
+	}
+}
+
+func TestRunFlagsOverrideConfig(t *testing.T) {
+	originalAskRun := askcmd.RunFunc
+	t.Cleanup(func() {
+		askcmd.RunFunc = originalAskRun
+	})
+
+	t.Setenv("OPENAI_API_KEY", "o")
+	t.Setenv("ANTHROPIC_API_KEY", "a")
+
+	var gotProvider providers.Provider
+	askcmd.RunFunc = func(ctx context.Context, provider providers.Provider, opts ask.Options, output io.Writer, stderr io.Writer, args []string) error {
+		gotProvider = provider
+		return nil
+	}
+
+	deps := Dependencies{
+		Config: &config.Config{Env: config.Settings{Provider: "openai"}},
+		Flags:  Flags{Provider: "anthropic", Model: "claude-sonnet-4-5"},
+	}
+
+	if err := defaultRegistry.Run(context.Background(), deps, []string{"ask", "hi"}); err != nil {
+		t.Fatalf("Run() error = %v, want nil", err)
+	}
+
+	if _, ok := gotProvider.(*providers.AnthropicProvider); !ok {
+		t.Errorf("ask provider = %T, want *providers.AnthropicProvider", gotProvider)
+	}
+}
+
+type usageProvider struct{}
+
+func (p *usageProvider) Complete(ctx context.Context, system, userMsg string) (providers.Response, error) {
+	return providers.Response{
+		Text:  "ok",
+		Usage: providers.Usage{Provider: "openrouter", Model: "gpt-4o-mini", InputTokens: 120, OutputTokens: 30},
+	}, nil
+}
+
+func TestRunPrintsUsage(t *testing.T) {
+	originalAskRun := askcmd.RunFunc
+	t.Cleanup(func() {
+		askcmd.RunFunc = originalAskRun
+	})
+
+	askcmd.RunFunc = func(ctx context.Context, provider providers.Provider, opts ask.Options, output io.Writer, stderr io.Writer, args []string) error {
+		for range 2 {
+			if _, err := provider.Complete(ctx, "", "hi"); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	tests := []struct {
+		name  string
+		cfg   *config.Config
+		flags Flags
+		want  string
+	}{
+		{
+			name: "off by default",
+		},
+		{
+			name:  "flag",
+			flags: Flags{Usage: true},
+			want:  "usage: 240 input + 60 output tokens, gpt-4o-mini via openrouter\n",
+		},
+		{
+			name: "config",
+			cfg: &config.Config{Repo: &config.File{Commands: map[string]config.Settings{
+				"ask": {Usage: new(true)},
+			}}},
+			want: "usage: 240 input + 60 output tokens, gpt-4o-mini via openrouter\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stderr bytes.Buffer
+			deps := Dependencies{
+				Provider: &usageProvider{},
+				Config:   tt.cfg,
+				Flags:    tt.flags,
+				Stderr:   &stderr,
+			}
+
+			if err := defaultRegistry.Run(context.Background(), deps, []string{"ask", "hi"}); err != nil {
+				t.Fatalf("Run() error = %v, want nil", err)
+			}
+
+			if got := stderr.String(); got != tt.want {
+				t.Errorf("stderr = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunRecordsCallsInLedger(t *testing.T) {
+	originalAskRun := askcmd.RunFunc
+	t.Cleanup(func() {
+		askcmd.RunFunc = originalAskRun
+	})
+
+	askcmd.RunFunc = func(ctx context.Context, provider providers.Provider, opts ask.Options, output io.Writer, stderr io.Writer, args []string) error {
+		_, err := provider.Complete(ctx, "", "hi")
+		return err
+	}
+
+	ledger := usage.NewLedger(filepath.Join(t.TempDir(), "usage.jsonl"))
+	deps := Dependencies{
+		Provider: &usageProvider{},
+		Ledger:   ledger,
+	}
+
+	if err := defaultRegistry.Run(context.Background(), deps, []string{"ask", "hi"}); err != nil {
+		t.Fatalf("Run() error = %v, want nil", err)
+	}
+
+	records, err := ledger.Read()
+	if err != nil {
+		t.Fatalf("Read() error = %v, want nil", err)
+	}
+	if len(records) != 1 || records[0].Command != "ask" || records[0].Model != "gpt-4o-mini" || !records[0].OK {
+		t.Errorf("ledger = %+v, want one successful ask call", records)
+	}
+}
+
+func TestRunUsageDoesNotResolveProvider(t *testing.T) {
+	var stdout bytes.Buffer
+	deps := Dependencies{
+		Config: &config.Config{Env: config.Settings{Provider: "acme"}},
+		Ledger: usage.NewLedger(filepath.Join(t.TempDir(), "usage.jsonl")),
+		Stdout: &stdout,
+	}
+
+	if err := defaultRegistry.Run(context.Background(), deps, []string{"usage"}); err != nil {
+		t.Fatalf("Run() error = %v, want nil", err)
+	}
+
+	if !strings.Contains(stdout.String(), "No usage recorded") {
+		t.Errorf("stdout = %q, want empty report", stdout.String())
+	}
+}
+
+func TestRunRefusesCallsOverBudget(t *testing.T) {
+	originalAskRun := askcmd.RunFunc
+	t.Cleanup(func() {
+		askcmd.RunFunc = originalAskRun
+	})
+
+	askcmd.RunFunc = func(ctx context.Context, provider providers.Provider, opts ask.Options, output io.Writer, stderr io.Writer, args []string) error {
+		_, err := provider.Complete(ctx, "", "hi")
+		return err
+	}
+
+	ledger := usage.NewLedger(filepath.Join(t.TempDir(), "usage.jsonl"))
+	if err := ledger.Append(usage.Record{Time: time.Now(), Command: "commit", InputTokens: 1000, OK: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	deps := Dependencies{
+		Provider: &usageProvider{},
+		Config:   &config.Config{Env: config.Settings{Budget: config.Budget{DailyTokens: 1000}}},
+		Ledger:   ledger,
+	}
+
+	err := defaultRegistry.Run(context.Background(), deps, []string{"ask", "hi"})
+	var budgetErr *usage.BudgetExceededError
+	if !errors.As(err, &budgetErr) || budgetErr.Period != "daily" {
+		t.Fatalf("Run() error = %v, want daily *usage.BudgetExceededError", err)
+	}
+
+	deps.Flags.OverBudget = true
+	if err := defaultRegistry.Run(context.Background(), deps, []string{"ask", "hi"}); err != nil {
+		t.Fatalf("Run() with --over-budget error = %v, want nil", err)
+	}
+
+	records, err := ledger.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 {
+		t.Errorf("ledger has %d records, want the refused call left out", len(records))
+	}
+}
+
+func TestRunWarnsOfUnenforcedUSDBudget(t *testing.T) {
+	originalAskRun := askcmd.RunFunc
+	t.Cleanup(func() {
+		askcmd.RunFunc = originalAskRun
+	})
+
+	askcmd.RunFunc = func(ctx context.Context, provider providers.Provider, opts ask.Options, output io.Writer, stderr io.Writer, args []string) error {
+		return nil
+	}
+
+	t.Setenv("OPENAI_API_KEY", "key")
+	var stderr bytes.Buffer
+	deps := Dependencies{
+		Config: &config.Config{Env: config.Settings{Provider: "openai", Budget: config.Budget{MonthlyUSD: 20}}},
+		Ledger: usage.NewLedger(filepath.Join(t.TempDir(), "usage.jsonl")),
+		Stderr: &stderr,
+	}
+
+	if err := defaultRegistry.Run(context.Background(), deps, []string{"ask", "hi"}); err != nil {
+		t.Fatalf("Run() error = %v, want nil", err)
+	}
+	if !strings.Contains(stderr.String(), "openai does not report costs") {
+		t.Errorf("stderr = %q, want a warning that the USD budget is not enforced", stderr.String())
+	}
+}
+
+func TestRunCachesResponses(t *testing.T) {
+	originalAskRun := askcmd.RunFunc
+	t.Cleanup(func() {
+		askcmd.RunFunc = originalAskRun
+	})
+
+	var answers []string
+	askcmd.RunFunc = func(ctx context.Context, provider providers.Provider, opts ask.Options, output io.Writer, stderr io.Writer, args []string) error {
+		resp, err := provider.Complete(ctx, "", strings.Join(args, " "))
+		answers = append(answers, resp.Text)
+		return err
+	}
+	cache := true
+
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		_, _ = fmt.Fprintf(w, `{"choices":[{"message":{"content":"answer %d"}}]}`, requests)
+	}))
+	t.Cleanup(server.Close)
+
+	t.Setenv("OPENAI_API_KEY", "key")
+	var stdout bytes.Buffer
+	deps := Dependencies{
+		Config:   &config.Config{Env: config.Settings{Provider: "openai", Endpoint: server.URL, Cache: &cache}},
+		CacheDir: t.TempDir(),
+		Stdout:   &stdout,
+	}
+
+	for _, flags := range []Flags{{}, {}, {NoCache: true}} {
+		deps.Flags = flags
+		if err := defaultRegistry.Run(context.Background(), deps, []string{"ask", "hi"}); err != nil {
+			t.Fatalf("Run() error = %v, want nil", err)
+		}
+	}
+
+	if want := []string{"answer 1", "answer 1", "answer 2"}; !reflect.DeepEqual(answers, want) {
+		t.Errorf("answers = %q, want %q", answers, want)
+	}
+
+	if err := defaultRegistry.Run(context.Background(), deps, []string{"cache", "clear"}); err != nil {
+		t.Fatalf("Run(cache clear) error = %v, want nil", err)
+	}
+	if got := stdout.String(); got != "Removed 1 cached responses\n" {
+		t.Errorf("cache clear output = %q", got)
+	}
+}
+
+func TestRunCachesOnlyDeterministicCommandsByDefault(t *testing.T) {
+	originalAskRun, originalCommitRun := askcmd.RunFunc, commitcmd.RunFunc
+	t.Cleanup(func() {
+		askcmd.RunFunc, commitcmd.RunFunc = originalAskRun, originalCommitRun
+	})
+
+	var answers []string
+	askcmd.RunFunc = func(ctx context.Context, provider providers.Provider, opts ask.Options, output io.Writer, stderr io.Writer, args []string) error {
+		resp, err := provider.Complete(ctx, "", "hi")
+		answers = append(answers, resp.Text)
+		return err
+	}
+	commitcmd.RunFunc = func(ctx context.Context, provider providers.Provider, gitClient git.Client, stderr io.Writer, args []string) error {
+		resp, err := provider.Complete(ctx, "", "diff")
+		answers = append(answers, resp.Text)
+		return err
+	}
+
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		_, _ = fmt.Fprintf(w, `{"choices":[{"message":{"content":"answer %d"}}]}`, requests)
+	}))
+	t.Cleanup(server.Close)
+
+	t.Setenv("OPENAI_API_KEY", "key")
+	deps := Dependencies{
+		Config:   &config.Config{Env: config.Settings{Provider: "openai", Endpoint: server.URL}},
+		CacheDir: t.TempDir(),
+	}
+
+	for _, args := range [][]string{{"ask", "hi"}, {"ask", "hi"}, {"commit"}, {"commit"}} {
+		if err := defaultRegistry.Run(context.Background(), deps, args); err != nil {
+			t.Fatalf("Run(%v) error = %v, want nil", args, err)
+		}
+	}
+
+	if want := []string{"answer 1", "answer 2", "answer 3", "answer 3"}; !reflect.DeepEqual(answers, want) {
+		t.Errorf("answers = %q, want ask asked afresh and commit cached", answers)
+	}
+}
+
+func TestRunRecordsAndReplaysProviderCalls(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = fmt.Fprint(w, "data: {\"choices\":[{\"delta\":{\"content\":\"recorded answer\"}}]}\n\ndata: [DONE]\n\n")
+	}))
+	t.Cleanup(server.Close)
+
+	dir := t.TempDir()
+	run := func() string {
+		t.Helper()
+
+		var stdout bytes.Buffer
+		deps := Dependencies{
+			Config: &config.Config{Env: config.Settings{Provider: "openai", Endpoint: server.URL}},
+			Stdout: &stdout,
+		}
+		if err := defaultRegistry.Run(context.Background(), deps, []string{"ask", "hi"}); err != nil {
+			t.Fatalf("Run() error = %v, want nil", err)
+		}
+		return stdout.String()
+	}
+
+	t.Setenv("OPENAI_API_KEY", "key")
+	t.Setenv("LLM_RECORD", dir)
+	recorded := run()
+
+	server.Close()
+	t.Setenv("OPENAI_API_KEY", "")
+	t.Setenv("LLM_RECORD", "")
+	t.Setenv("LLM_REPLAY", dir)
+	replayed := run()
+
+	if !strings.Contains(recorded, "recorded answer") || replayed != recorded {
+		t.Errorf("replayed output = %q, want recorded output %q", replayed, recorded)
+	}
+}
+
+func TestRunDebugLogsProviderRequests(t *testing.T) {
+	originalAskRun := askcmd.RunFunc
+	t.Cleanup(func() {
+		askcmd.RunFunc = originalAskRun
+	})
+
+	askcmd.RunFunc = func(ctx context.Context, provider providers.Provider, opts ask.Options, output io.Writer, stderr io.Writer, args []string) error {
+		_, err := provider.Complete(ctx, "", "hi")
+		return err
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"choices":[{"message":{"content":"answer"}}]}`))
+	}))
+	t.Cleanup(server.Close)
+	t.Setenv("OPENAI_API_KEY", "sk-secret")
+
+	var stderr bytes.Buffer
+	deps := Dependencies{
+		Config: &config.Config{Env: config.Settings{Provider: "openai", Endpoint: server.URL}},
+		Flags:  Flags{Debug: true},
+		Stderr: &stderr,
+	}
+	if err := defaultRegistry.Run(context.Background(), deps, []string{"ask", "hi"}); err != nil {
+		t.Fatalf("Run() error = %v, want nil", err)
+	}
+
+	if got := stderr.String(); !strings.Contains(got, "debug: > POST "+server.URL) || strings.Contains(got, "sk-secret") {
+		t.Errorf("stderr = %q, want a redacted debug log", got)
+	}
+
+	logFile := filepath.Join(t.TempDir(), "debug.log")
+	stderr.Reset()
+	deps.Flags = Flags{}
+	deps.Config.Env.Debug = new(true)
+	deps.Config.Env.DebugFile = logFile
+	if err := defaultRegistry.Run(context.Background(), deps, []string{"ask", "hi"}); err != nil {
+		t.Fatalf("Run() error = %v, want nil", err)
+	}
+
+	data, err := os.ReadFile(logFile)
+	if err != nil || !strings.Contains(string(data), "debug: < 200 OK") || stderr.Len() != 0 {
+		t.Errorf("debug file = %q, %v with stderr %q, want the log in the file only", data, err, stderr.String())
+	}
+}
+
+type mapKeyring map[string]string
+
+func (m mapKeyring) Get(provider string) (string, error) {
+	if key, ok := m[provider]; ok {
+		return key, nil
+	}
+	return "", credentials.ErrNotFound
+}
+
+func (m mapKeyring) Set(provider, key string) error {
+	m[provider] = key
+	return nil
+}
+
+func (m mapKeyring) Delete(provider string) error {
+	delete(m, provider)
+	return nil
+}
+
+func TestRunAuthCommands(t *testing.T) {
+	keyring := mapKeyring{}
+	original := credentials.DefaultKeyring
+	credentials.DefaultKeyring = keyring
+	t.Cleanup(func() { credentials.DefaultKeyring = original })
+
+	for _, key := range []string{"OPENROUTER_API_KEY", "OPENCODE_ZEN_API_KEY", "ANTHROPIC_API_KEY", "OPENAI_API_KEY", "AZURE_OPENAI_API_KEY", "GEMINI_API_KEY", "LLM_API_KEY"} {
+		t.Setenv(key, "")
+	}
+
+	var stdout bytes.Buffer
+	deps := Dependencies{
+		Config: &config.Config{},
+		Stdin:  strings.NewReader("sk-stored\n"),
+		Stdout: &stdout,
+	}
+
+	if err := defaultRegistry.Run(context.Background(), deps, []string{"auth", "login", "anthropic"}); err != nil {
+		t.Fatalf("Run(auth login) error = %v, want nil", err)
+	}
+	if keyring["anthropic"] != "sk-stored" {
+		t.Errorf("keyring = %v, want the anthropic key stored", keyring)
+	}
+
+	stdout.Reset()
+	if err := defaultRegistry.Run(context.Background(), deps, []string{"auth", "status"}); err != nil {
+		t.Fatalf("Run(auth status) error = %v, want nil", err)
+	}
+	if !strings.Contains(stdout.String(), "anthropic     keyring\n") || !strings.Contains(stdout.String(), "openai        not set\n") {
+		t.Errorf("auth status output =\n%s", stdout.String())
+	}
+
+	var gotProvider providers.Provider
+	originalAskRun := askcmd.RunFunc
+	t.Cleanup(func() { askcmd.RunFunc = originalAskRun })
+	askcmd.RunFunc = func(ctx context.Context, provider providers.Provider, opts ask.Options, output io.Writer, stderr io.Writer, args []string) error {
+		gotProvider = provider
+		return nil
+	}
+	if err := defaultRegistry.Run(context.Background(), deps, []string{"ask", "hi"}); err != nil {
+		t.Fatalf("Run(ask) error = %v, want the stored key to be used", err)
+	}
+	if d := providers.Describe(gotProvider); d.Provider != "anthropic" {
+		t.Errorf("ask provider = %+v, want anthropic", d)
+	}
+
+	if err := defaultRegistry.Run(context.Background(), deps, []string{"auth", "logout", "anthropic"}); err != nil {
+		t.Fatalf("Run(auth logout) error = %v, want nil", err)
+	}
+	if len(keyring) != 0 {
+		t.Errorf("keyring after logout = %v, want empty", keyring)
+	}
+}
+
+func TestRunProvidersCommands(t *testing.T) {
+	originalTestRun, originalModelsRun := testcmd.RunFunc, modelscmd.RunFunc
+	t.Cleanup(func() {
+		testcmd.RunFunc, modelscmd.RunFunc = originalTestRun, originalModelsRun
+	})
+
+	var gotSettings []config.Settings
+	var gotOpts [][]providers.Option
+	testcmd.RunFunc = func(ctx context.Context, s config.Settings, opts []providers.Option, wrap func(providers.Provider) providers.Provider, stdout io.Writer, args []string) error {
+		gotSettings, gotOpts = append(gotSettings, s), append(gotOpts, opts)
+		return nil
+	}
+	modelscmd.RunFunc = testcmd.RunFunc
+
+	// The named provider is unknown, so resolving it up front would fail.
+	deps := Dependencies{
+		Config: &config.Config{Env: config.Settings{Provider: "acme"}},
+		Flags:  Flags{Model: "gpt-4o", Debug: true},
+	}
+
+	for _, args := range [][]string{{"providers", "test"}, {"models"}} {
+		if err := defaultRegistry.Run(context.Background(), deps, args); err != nil {
+			t.Fatalf("Run(%v) error = %v, want nil", args, err)
+		}
+	}
+
+	if len(gotSettings) != 2 {
+		t.Fatalf("got %d calls, want providers test and models", len(gotSettings))
+	}
+	for i, s := range gotSettings {
+		if s.Provider != "acme" || s.Model != "gpt-4o" || len(gotOpts[i]) != 1 {
+			t.Errorf("call %d got settings %+v and %d options, want the merged settings and the debug log", i, s, len(gotOpts[i]))
+		}
+	}
+
+	var stdout bytes.Buffer
+	deps.Stdout = &stdout
+	if err := defaultRegistry.Run(context.Background(), deps, []string{"providers", "list"}); err != nil {
+		t.Fatalf("Run(providers list) error = %v, want nil", err)
+	}
+	if !strings.Contains(stdout.String(), `No provider is active: unknown provider "acme"`) {
+		t.Errorf("providers list output =\n%s", stdout.String())
+	}
+}
+
+func TestRunProvidersCommandsRecordAndCheckBudget(t *testing.T) {
+	originalTestRun, originalModelsRun := testcmd.RunFunc, modelscmd.RunFunc
+	t.Cleanup(func() {
+		testcmd.RunFunc, modelscmd.RunFunc = originalTestRun, originalModelsRun
+	})
+
+	testcmd.RunFunc = func(ctx context.Context, s config.Settings, opts []providers.Option, wrap func(providers.Provider) providers.Provider, stdout io.Writer, args []string) error {
+		_, err := wrap(&usageProvider{}).Complete(ctx, "", "ping")
+		return err
+	}
+	modelscmd.RunFunc = testcmd.RunFunc
+
+	ledger := usage.NewLedger(filepath.Join(t.TempDir(), "usage.jsonl"))
+	deps := Dependencies{
+		Config: &config.Config{Env: config.Settings{Provider: "acme", Budget: config.Budget{DailyTokens: 200}}},
+		Ledger: ledger,
+	}
+
+	for _, args := range [][]string{{"providers", "test"}, {"models"}} {
+		if err := defaultRegistry.Run(context.Background(), deps, args); err != nil {
+			t.Fatalf("Run(%v) error = %v, want nil", args, err)
+		}
+	}
+
+	records, err := ledger.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 || records[0].Command != "providers test" || records[1].Command != "models" {
+		t.Fatalf("ledger = %+v, want the providers test and models calls", records)
+	}
+
+	err = defaultRegistry.Run(context.Background(), deps, []string{"providers", "test"})
+	var budgetErr *usage.BudgetExceededError
+	if !errors.As(err, &budgetErr) {
+		t.Errorf("Run(providers test) over budget error = %v, want *usage.BudgetExceededError", err)
+	}
+}
+
+func TestRunChatCanSwitchModel(t *testing.T) {
+	for _, key := range []string{"OPENROUTER_API_KEY", "OPENCODE_ZEN_API_KEY", "ANTHROPIC_API_KEY", "AZURE_OPENAI_API_KEY", "GEMINI_API_KEY", "LLM_BASE_URL", "LLM_RECORD", "LLM_REPLAY"} {
+		t.Setenv(key, "")
+	}
+	t.Setenv("OPENAI_API_KEY", "sk-openai")
+
+	originalChatRun := chatcmd.RunFunc
+	t.Cleanup(func() { chatcmd.RunFunc = originalChatRun })
+
+	var before, after providers.Usage
+	chatcmd.RunFunc = func(ctx context.Context, provider providers.Provider, models providers.ModelSwitcher, stdin io.Reader, stdout, stderr io.Writer, args []string) error {
+		before = models.Current()
+		if err := models.SetModel("gpt-4o"); err != nil {
+			return err
+		}
+		after = providers.Describe(provider)
+		return nil
+	}
+
+	deps := Dependencies{Config: &config.Config{Env: config.Settings{Provider: "openai"}}}
+	if err := defaultRegistry.Run(context.Background(), deps, []string{"chat"}); err != nil {
+		t.Fatalf("Run(chat) error = %v, want nil", err)
+	}
+
+	if before.Model != "gpt-4o-mini" || after.Model != "gpt-4o" || after.Provider != "openai" {
+		t.Errorf("model before = %+v, after = %+v, want gpt-4o-mini then gpt-4o", before, after)
+	}
+}
+
+func TestRunAskContinuesWithSessionModel(t *testing.T) {
+	for _, key := range []string{"OPENROUTER_API_KEY", "OPENCODE_ZEN_API_KEY", "ANTHROPIC_API_KEY", "AZURE_OPENAI_API_KEY", "GEMINI_API_KEY", "LLM_BASE_URL", "LLM_RECORD", "LLM_REPLAY"} {
+		t.Setenv(key, "")
+	}
+	t.Setenv("OPENAI_API_KEY", "sk-openai")
+
+	originalAskRun := askcmd.RunFunc
+	t.Cleanup(func() { askcmd.RunFunc = originalAskRun })
+
+	var got providers.Usage
+	askcmd.RunFunc = func(ctx context.Context, provider providers.Provider, opts ask.Options, output io.Writer, stderr io.Writer, args []string) error {
+		got = providers.Describe(provider)
+		return nil
+	}
+
+	store := session.NewStore(t.TempDir())
+	for _, sess := range []*session.Session{
+		{Name: "claude", Provider: "anthropic", Model: "claude-sonnet-4-5"},
+		{Name: "trip", Provider: "openai", Model: "gpt-4o"},
+	} {
+		if err := store.Save(sess); err != nil {
+			t.Fatalf("Save(%s) error = %v", sess.Name, err)
+		}
+	}
+
+	tests := []struct {
+		name  string
+		flags Flags
+		args  []string
+		want  string
+	}{
+		{"new question", Flags{}, []string{"ask", "hi"}, "gpt-4o-mini"},
+		{"continued session", Flags{}, []string{"ask", "-c", "more"}, "gpt-4o"},
+		{"model flag wins", Flags{Model: "gpt-4.1"}, []string{"ask", "-s", "trip", "more"}, "gpt-4.1"},
+		{"other provider", Flags{}, []string{"ask", "-s", "claude", "more"}, "gpt-4o-mini"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deps := Dependencies{
+				Config:   &config.Config{Env: config.Settings{Provider: "openai"}},
+				Flags:    tt.flags,
+				Sessions: store,
+			}
+			if err := defaultRegistry.Run(context.Background(), deps, tt.args); err != nil {
+				t.Fatalf("Run(%v) error = %v, want nil", tt.args, err)
+			}
+			if got.Model != tt.want {
+				t.Errorf("ask model = %q, want %q", got.Model, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunAskSavesConfiguredModel(t *testing.T) {
+	for _, key := range []string{"OPENROUTER_API_KEY", "OPENCODE_ZEN_API_KEY", "ANTHROPIC_API_KEY", "AZURE_OPENAI_API_KEY", "GEMINI_API_KEY", "LLM_BASE_URL", "LLM_RECORD", "LLM_REPLAY"} {
+		t.Setenv(key, "")
+	}
+	t.Setenv("OPENAI_API_KEY", "sk-openai")
+
+	var models []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Model string `json:"model"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		models = append(models, req.Model)
+
+		// The API names a dated snapshot rather than the model asked for.
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = fmt.Fprint(w, "data: {\"model\":\"gpt-4o-2024-08-06\",\"choices\":[{\"delta\":{\"content\":\"ok\"}}]}\n\ndata: [DONE]\n\n")
+	}))
+	t.Cleanup(server.Close)
+
+	store := session.NewStore(t.TempDir())
+	deps := Dependencies{
+		Config:   &config.Config{Env: config.Settings{Provider: "openai", Model: "gpt-4o", Endpoint: server.URL}},
+		Ledger:   usage.NewLedger(filepath.Join(t.TempDir(), "usage.jsonl")),
+		Sessions: store,
+	}
+
+	for _, args := range [][]string{{"ask", "hi"}, {"ask", "-c", "more"}} {
+		if err := defaultRegistry.Run(context.Background(), deps, args); err != nil {
+			t.Fatalf("Run(%v) error = %v, want nil", args, err)
+		}
+	}
+
+	if want := []string{"gpt-4o", "gpt-4o"}; !reflect.DeepEqual(models, want) {
+		t.Errorf("requested models = %q, want %q", models, want)
+	}
+	sess, err := store.Load(session.Default)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sess.Provider != "openai" || sess.Model != "gpt-4o" {
+		t.Errorf("session model = %s/%s, want the configured openai/gpt-4o", sess.Provider, sess.Model)
+	}
+}
+
+func TestRunSessionsCommands(t *testing.T) {
+	store := session.NewStore(t.TempDir())
+	if err := store.Save(&session.Session{Name: "trip"}); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	// Session commands do not need a provider.
+	deps := Dependencies{
+		Config:   &config.Config{Env: config.Settings{Provider: "acme"}},
+		Sessions: store,
+	}
+
+	var stdout bytes.Buffer
+	deps.Stdout = &stdout
+	for _, args := range [][]string{{"sessions", "list"}, {"sessions", "show", "trip"}, {"sessions", "rm", "trip"}} {
+		if err := defaultRegistry.Run(context.Background(), deps, args); err != nil {
+			t.Fatalf("Run(%v) error = %v, want nil", args, err)
+		}
+	}
+	for _, want := range []string{"* trip", "# trip\n", "Removed session trip\n"} {
+		if !strings.Contains(stdout.String(), want) {
+			t.Errorf("sessions output missing %q:\n%s", want, stdout.String())
+		}
+	}
+
+	deps.Sessions = nil
+	err := defaultRegistry.Run(context.Background(), deps, []string{"sessions", "list"})
+	if err == nil || !strings.Contains(err.Error(), "sessions unavailable") {
+		t.Errorf("Run(sessions list) without a store error = %v, want sessions unavailable", err)
+	}
+}
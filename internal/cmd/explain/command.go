@@ -0,0 +1,21 @@
+package explaincmd
+
+import (
+	"context"
+	"io"
+
+	"llm/internal/explain"
+	"llm/internal/providers"
+)
+
+const (
+	Name        = "explain"
+	Usage       = "explain -- <command>"
+	Description = "Run a command and explain why it failed, or --last to rerun the previous one"
+)
+
+var RunFunc = explain.Run
+
+func Run(ctx context.Context, provider providers.Provider, stdin io.Reader, stdout, stderr io.Writer, args []string) error {
+	return RunFunc(ctx, provider, stdin, stdout, stderr, args)
+}
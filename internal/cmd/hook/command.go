@@ -0,0 +1,20 @@
+package hookcmd
+
+import (
+	"context"
+	"io"
+
+	"llm/internal/explain"
+)
+
+const (
+	Name        = "hook"
+	Usage       = "hook <shell>"
+	Description = "Print the shell hook that llm explain --last needs"
+)
+
+var RunFunc = explain.Hook
+
+func Run(ctx context.Context, stdout io.Writer, args []string) error {
+	return RunFunc(stdout, args)
+}
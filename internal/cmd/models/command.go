@@ -0,0 +1,22 @@
+package modelscmd
+
+import (
+	"context"
+	"io"
+
+	"llm/internal/catalog"
+	"llm/internal/config"
+	"llm/internal/providers"
+)
+
+const (
+	Name        = "models"
+	Usage       = "models [provider]"
+	Description = "List the models a provider serves"
+)
+
+var RunFunc = catalog.Models
+
+func Run(ctx context.Context, settings config.Settings, opts []providers.Option, wrap func(providers.Provider) providers.Provider, stdout io.Writer, args []string) error {
+	return RunFunc(ctx, settings, opts, wrap, stdout, args)
+}
@@ -0,0 +1,7 @@
+package providerscmd
+
+const (
+	Name        = "providers"
+	Usage       = "providers <subcommand>"
+	Description = "Inspect and check the configured providers"
+)
@@ -0,0 +1,21 @@
+package listcmd
+
+import (
+	"context"
+	"io"
+
+	"llm/internal/catalog"
+	"llm/internal/config"
+)
+
+const (
+	Name        = "list"
+	Usage       = "list"
+	Description = "Show the providers and which one is active"
+)
+
+var RunFunc = catalog.List
+
+func Run(ctx context.Context, settings config.Settings, stdout io.Writer, args []string) error {
+	return RunFunc(settings, stdout, args)
+}
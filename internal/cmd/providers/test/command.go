@@ -0,0 +1,22 @@
+package testcmd
+
+import (
+	"context"
+	"io"
+
+	"llm/internal/catalog"
+	"llm/internal/config"
+	"llm/internal/providers"
+)
+
+const (
+	Name        = "test"
+	Usage       = "test [provider...]"
+	Description = "Send a ping to each provider and report latency"
+)
+
+var RunFunc = catalog.Test
+
+func Run(ctx context.Context, settings config.Settings, opts []providers.Option, wrap func(providers.Provider) providers.Provider, stdout io.Writer, args []string) error {
+	return RunFunc(ctx, settings, opts, wrap, stdout, args)
+}
@@ -0,0 +1,7 @@
+package sessionscmd
+
+const (
+	Name        = "sessions"
+	Usage       = "sessions <subcommand>"
+	Description = "Manage saved conversations"
+)
@@ -0,0 +1,25 @@
+package listcmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"llm/internal/session"
+)
+
+const (
+	Name        = "list"
+	Usage       = "list"
+	Description = "List saved conversations"
+)
+
+var RunFunc = session.List
+
+func Run(ctx context.Context, sessions *session.Store, stdout io.Writer, args []string) error {
+	if sessions == nil {
+		return fmt.Errorf("sessions unavailable: could not determine the state directory")
+	}
+
+	return RunFunc(sessions, stdout, args)
+}
@@ -0,0 +1,25 @@
+package rmcmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"llm/internal/session"
+)
+
+const (
+	Name        = "rm"
+	Usage       = "rm <name>..."
+	Description = "Delete conversations"
+)
+
+var RunFunc = session.Remove
+
+func Run(ctx context.Context, sessions *session.Store, stdout io.Writer, args []string) error {
+	if sessions == nil {
+		return fmt.Errorf("sessions unavailable: could not determine the state directory")
+	}
+
+	return RunFunc(sessions, stdout, args)
+}
@@ -0,0 +1,25 @@
+package showcmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"llm/internal/session"
+)
+
+const (
+	Name        = "show"
+	Usage       = "show [name]"
+	Description = "Print a conversation"
+)
+
+var RunFunc = session.Show
+
+func Run(ctx context.Context, sessions *session.Store, stdout io.Writer, args []string) error {
+	if sessions == nil {
+		return fmt.Errorf("sessions unavailable: could not determine the state directory")
+	}
+
+	return RunFunc(sessions, stdout, args)
+}
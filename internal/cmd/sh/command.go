@@ -0,0 +1,21 @@
+package shcmd
+
+import (
+	"context"
+	"io"
+
+	"llm/internal/providers"
+	"llm/internal/sh"
+)
+
+const (
+	Name        = "sh"
+	Usage       = "sh <request>"
+	Description = "Turn a request into a shell command and run it"
+)
+
+var RunFunc = sh.Run
+
+func Run(ctx context.Context, provider providers.Provider, stdin io.Reader, stdout, stderr io.Writer, args []string) error {
+	return RunFunc(ctx, provider, stdin, stdout, stderr, args)
+}
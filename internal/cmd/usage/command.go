@@ -0,0 +1,25 @@
+package usagecmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"llm/internal/usage"
+)
+
+const (
+	Name        = "usage"
+	Usage       = "usage [--days N]"
+	Description = "Show token usage by day, command and model"
+)
+
+var RunFunc = usage.Run
+
+func Run(ctx context.Context, ledger *usage.Ledger, stdout io.Writer, args []string) error {
+	if ledger == nil {
+		return fmt.Errorf("usage ledger unavailable: could not determine the state directory")
+	}
+
+	return RunFunc(ledger, stdout, args)
+}
@@ -49,14 +49,14 @@ func BuildPrompt(diff, branch string) string {
 
 func GenerateCommitMessage(ctx context.Context, provider providers.Provider, prompt string, stderr io.Writer) (string, error) {
 	ind := loading.Start(stderr)
-	msg, err := provider.Complete(ctx, systemPrompt, prompt)
+	resp, err := provider.Complete(ctx, systemPrompt, prompt)
 	ind.Stop()
 
 	if err != nil {
 		return "", err
 	}
 
-	return msg, nil
+	return resp.Text, nil
 }
 
 func Run(ctx context.Context, provider providers.Provider, git git.Client, stderr io.Writer, args []string) error {
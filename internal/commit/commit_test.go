@@ -5,6 +5,8 @@ import (
 	"context"
 	"errors"
 	"testing"
+
+	"llm/internal/providers"
 )
 
 type stubProvider struct {
@@ -12,8 +14,8 @@ type stubProvider struct {
 	err  error
 }
 
-func (s *stubProvider) Complete(ctx context.Context, system, userMsg string) (string, error) {
-	return s.resp, s.err
+func (s *stubProvider) Complete(ctx context.Context, system, userMsg string) (providers.Response, error) {
+	return providers.Response{Text: s.resp}, s.err
 }
 
 type stubGitClient struct {
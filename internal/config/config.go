@@ -0,0 +1,634 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"llm/internal/xdg"
+)
+
+// RepoFileName is the name of the per-repository config file. It is looked
+// up from the working directory upwards to the root of the git repository.
+const RepoFileName = ".llm.toml"
+
+// Settings holds the provider options that can be set from flags, the
+// environment or config files. Zero values mean "not set".
+type Settings struct {
+	Provider      string
+	Model         string
+	Endpoint      string
+	MaxTokens     int
+	Temperature   *float64
+	Timeout       time.Duration
+	Retries       *int
+	MaxRetryDelay time.Duration
+	Fallback      []string
+	// MaxContinuations is how many times to ask for the rest of an answer
+	// cut off at max_tokens.
+	MaxContinuations int
+	// Usage prints token usage and cost to stderr after each command.
+	Usage *bool
+	// Cache reuses earlier responses to the same prompt. When unset, it is
+	// on for llm commit and llm gh pr only.
+	Cache        *bool
+	CacheTTL     time.Duration
+	CacheMaxSize int64
+	Budget       Budget
+	// Proxy, NoProxy and CABundle configure the connection to the
+	// provider. An unset Proxy uses HTTPS_PROXY from the environment.
+	Proxy    string
+	NoProxy  string
+	CABundle string
+	// Debug logs every provider request and response to stderr, or to
+	// DebugFile when it is set.
+	Debug     *bool
+	DebugFile string
+	// CredentialHelper is a command that prints the API key of the
+	// provider named by its {provider} placeholder.
+	CredentialHelper string
+	// Keyring reads API keys from the system keyring. It is on unless set
+	// to false.
+	Keyring *bool
+}
+
+// Budget limits the tokens used and the cost reported by providers per
+// calendar day and month. Zero values mean no limit.
+type Budget struct {
+	DailyTokens   int
+	MonthlyTokens int
+	DailyUSD      float64
+	MonthlyUSD    float64
+}
+
+// IsZero reports whether no limit is set.
+func (b Budget) IsZero() bool {
+	return b == Budget{}
+}
+
+func (b Budget) merge(override Budget) Budget {
+	if override.DailyTokens != 0 {
+		b.DailyTokens = override.DailyTokens
+	}
+	if override.MonthlyTokens != 0 {
+		b.MonthlyTokens = override.MonthlyTokens
+	}
+	if override.DailyUSD != 0 {
+		b.DailyUSD = override.DailyUSD
+	}
+	if override.MonthlyUSD != 0 {
+		b.MonthlyUSD = override.MonthlyUSD
+	}
+	return b
+}
+
+// Merge returns s with every field that is set in override replaced.
+func (s Settings) Merge(override Settings) Settings {
+	if override.Provider != "" {
+		s.Provider = override.Provider
+	}
+	if override.Model != "" {
+		s.Model = override.Model
+	}
+	if override.Endpoint != "" {
+		s.Endpoint = override.Endpoint
+	}
+	if override.MaxTokens != 0 {
+		s.MaxTokens = override.MaxTokens
+	}
+	if override.Temperature != nil {
+		s.Temperature = override.Temperature
+	}
+	if override.Timeout != 0 {
+		s.Timeout = override.Timeout
+	}
+	if override.Retries != nil {
+		s.Retries = override.Retries
+	}
+	if override.MaxRetryDelay != 0 {
+		s.MaxRetryDelay = override.MaxRetryDelay
+	}
+	if override.Fallback != nil {
+		s.Fallback = override.Fallback
+	}
+	if override.MaxContinuations != 0 {
+		s.MaxContinuations = override.MaxContinuations
+	}
+	if override.Usage != nil {
+		s.Usage = override.Usage
+	}
+	if override.Cache != nil {
+		s.Cache = override.Cache
+	}
+	if override.CacheTTL != 0 {
+		s.CacheTTL = override.CacheTTL
+	}
+	if override.CacheMaxSize != 0 {
+		s.CacheMaxSize = override.CacheMaxSize
+	}
+	s.Budget = s.Budget.merge(override.Budget)
+	if override.Proxy != "" {
+		s.Proxy = override.Proxy
+	}
+	if override.NoProxy != "" {
+		s.NoProxy = override.NoProxy
+	}
+	if override.CABundle != "" {
+		s.CABundle = override.CABundle
+	}
+	if override.Debug != nil {
+		s.Debug = override.Debug
+	}
+	if override.DebugFile != "" {
+		s.DebugFile = override.DebugFile
+	}
+	if override.CredentialHelper != "" {
+		s.CredentialHelper = override.CredentialHelper
+	}
+	if override.Keyring != nil {
+		s.Keyring = override.Keyring
+	}
+	return s
+}
+
+// File is a parsed config file. Top-level keys apply to every command and
+// [commands.<name>] tables override them for a single command, with nested
+// commands written as dotted names such as [commands.gh.pr].
+type File struct {
+	Settings
+	Commands map[string]Settings
+}
+
+// Config holds the config layers in the order they are applied.
+type Config struct {
+	User *File
+	Repo *File
+	Env  Settings
+}
+
+// Load reads the user config from $XDG_CONFIG_HOME/llm/config.toml, the
+// repository config from the nearest .llm.toml and the LLM_* environment
+// variables. Missing files are not an error.
+func Load() (*Config, error) {
+	cfg := &Config{}
+
+	configHome, err := xdg.ConfigHome()
+	if err != nil {
+		return nil, err
+	}
+
+	cfg.User, err = ReadFile(filepath.Join(configHome, "llm", "config.toml"))
+	if err != nil {
+		return nil, err
+	}
+
+	if path := findRepoFile(); path != "" {
+		cfg.Repo, err = ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if err := cfg.Repo.checkRepoSettings(); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+
+	cfg.Env, err = FromEnv(os.Getenv)
+	if err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+// checkRepoSettings rejects settings that a repository must not be able to
+// set, since cloning it would otherwise run its commands, send the user's
+// API keys to a host it chose or lift the user's budget.
+func (f *File) checkRepoSettings() error {
+	if f == nil {
+		return nil
+	}
+
+	settings := []Settings{f.Settings}
+	for _, s := range f.Commands {
+		settings = append(settings, s)
+	}
+	for _, s := range settings {
+		if keys := s.userOnlyKeys(); len(keys) > 0 {
+			return fmt.Errorf("%s can only be set in the user config or the environment", keys[0])
+		}
+	}
+	return nil
+}
+
+// userOnlyKeys returns the keys set in s that only the user config and the
+// environment may set.
+func (s Settings) userOnlyKeys() []string {
+	var keys []string
+	if s.Endpoint != "" {
+		keys = append(keys, "endpoint")
+	}
+	// A proxy, trusted through a CA bundle, could read the API keys in
+	// every request.
+	if s.Proxy != "" {
+		keys = append(keys, "proxy")
+	}
+	if s.NoProxy != "" {
+		keys = append(keys, "no_proxy")
+	}
+	if s.CABundle != "" {
+		keys = append(keys, "ca_bundle")
+	}
+	// The debug log holds every prompt and answer, and its file is opened
+	// for appending wherever it points.
+	if s.Debug != nil {
+		keys = append(keys, "debug")
+	}
+	if s.DebugFile != "" {
+		keys = append(keys, "debug_file")
+	}
+	if s.CredentialHelper != "" {
+		keys = append(keys, "credential_helper")
+	}
+	// A budget caps what the user spends, which a repository must not be
+	// able to lift.
+	for _, limit := range []struct {
+		key string
+		set bool
+	}{
+		{"budget.daily_tokens", s.Budget.DailyTokens != 0},
+		{"budget.monthly_tokens", s.Budget.MonthlyTokens != 0},
+		{"budget.daily_usd", s.Budget.DailyUSD != 0},
+		{"budget.monthly_usd", s.Budget.MonthlyUSD != 0},
+	} {
+		if limit.set {
+			keys = append(keys, limit.key)
+		}
+	}
+	return keys
+}
+
+// Resolve returns the settings for the given command path (e.g. "gh pr"),
+// applying user config, repository config and environment in that order.
+// Command tables override the top-level keys of the same file.
+func (c *Config) Resolve(command string) Settings {
+	var s Settings
+	if c == nil {
+		return s
+	}
+
+	for _, f := range []*File{c.User, c.Repo} {
+		if f == nil {
+			continue
+		}
+		s = s.Merge(f.Settings).Merge(f.Commands[command])
+	}
+
+	return s.Merge(c.Env)
+}
+
+// ReadFile parses the config file at path. It returns nil if the file does
+// not exist.
+func ReadFile(path string) (*File, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading config: %w", err)
+	}
+
+	f, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return f, nil
+}
+
+// Parse decodes a config file. Unknown keys are rejected so that typos do
+// not silently fall back to defaults.
+func Parse(data []byte) (*File, error) {
+	values, err := parseTOML(data)
+	if err != nil {
+		return nil, err
+	}
+
+	f := &File{Commands: make(map[string]Settings)}
+
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		value := values[key]
+
+		if command, ok := strings.CutPrefix(key, "commands."); ok {
+			dot := strings.LastIndex(command, ".")
+			if dot < 0 {
+				return nil, fmt.Errorf("unknown key %q", key)
+			}
+
+			name := strings.ReplaceAll(command[:dot], ".", " ")
+			s := f.Commands[name]
+			if err := s.set(command[dot+1:], value); err != nil {
+				return nil, fmt.Errorf("%s: %w", key, err)
+			}
+			f.Commands[name] = s
+			continue
+		}
+
+		if err := f.set(key, value); err != nil {
+			return nil, fmt.Errorf("%s: %w", key, err)
+		}
+	}
+
+	return f, nil
+}
+
+// FromEnv reads settings from LLM_PROVIDER, LLM_MODEL, LLM_ENDPOINT,
+// LLM_MAX_TOKENS, LLM_TEMPERATURE, LLM_TIMEOUT, LLM_RETRIES,
+// LLM_MAX_RETRY_DELAY, LLM_FALLBACK (a comma-separated list),
+// LLM_MAX_CONTINUATIONS, LLM_USAGE, LLM_CACHE, LLM_CACHE_TTL,
+// LLM_CACHE_MAX_SIZE, the LLM_BUDGET_* limits, LLM_PROXY, LLM_NO_PROXY,
+// LLM_CA_BUNDLE, LLM_DEBUG, LLM_DEBUG_FILE, LLM_CREDENTIAL_HELPER and
+// LLM_KEYRING.
+func FromEnv(getenv func(string) string) (Settings, error) {
+	var s Settings
+
+	for _, env := range []struct {
+		name string
+		key  string
+	}{
+		{"LLM_PROVIDER", "provider"},
+		{"LLM_MODEL", "model"},
+		{"LLM_ENDPOINT", "endpoint"},
+		{"LLM_MAX_TOKENS", "max_tokens"},
+		{"LLM_TEMPERATURE", "temperature"},
+		{"LLM_TIMEOUT", "timeout"},
+		{"LLM_RETRIES", "retries"},
+		{"LLM_MAX_RETRY_DELAY", "max_retry_delay"},
+		{"LLM_FALLBACK", "fallback"},
+		{"LLM_MAX_CONTINUATIONS", "max_continuations"},
+		{"LLM_USAGE", "usage"},
+		{"LLM_CACHE", "cache"},
+		{"LLM_CACHE_TTL", "cache_ttl"},
+		{"LLM_CACHE_MAX_SIZE", "cache_max_size"},
+		{"LLM_BUDGET_DAILY_TOKENS", "budget.daily_tokens"},
+		{"LLM_BUDGET_MONTHLY_TOKENS", "budget.monthly_tokens"},
+		{"LLM_BUDGET_DAILY_USD", "budget.daily_usd"},
+		{"LLM_BUDGET_MONTHLY_USD", "budget.monthly_usd"},
+		{"LLM_PROXY", "proxy"},
+		{"LLM_NO_PROXY", "no_proxy"},
+		{"LLM_CA_BUNDLE", "ca_bundle"},
+		{"LLM_DEBUG", "debug"},
+		{"LLM_DEBUG_FILE", "debug_file"},
+		{"LLM_CREDENTIAL_HELPER", "credential_helper"},
+		{"LLM_KEYRING", "keyring"},
+	} {
+		raw := getenv(env.name)
+		if raw == "" {
+			continue
+		}
+
+		if err := s.set(env.key, raw); err != nil {
+			return Settings{}, fmt.Errorf("%s: %w", env.name, err)
+		}
+	}
+
+	return s, nil
+}
+
+func (s *Settings) set(key string, value any) error {
+	var err error
+
+	switch key {
+	case "provider":
+		s.Provider, err = toString(value)
+	case "model":
+		s.Model, err = toString(value)
+	case "endpoint":
+		s.Endpoint, err = toString(value)
+	case "max_tokens":
+		s.MaxTokens, err = toInt(value)
+	case "temperature":
+		var t float64
+		t, err = toFloat(value)
+		s.Temperature = &t
+	case "timeout":
+		s.Timeout, err = toDuration(value)
+	case "retries":
+		var n int
+		n, err = toInt(value)
+		s.Retries = &n
+	case "max_retry_delay":
+		s.MaxRetryDelay, err = toDuration(value)
+	case "fallback":
+		s.Fallback, err = toStringList(value)
+	case "max_continuations":
+		s.MaxContinuations, err = toInt(value)
+	case "usage":
+		var b bool
+		b, err = toBool(value)
+		s.Usage = &b
+	case "cache":
+		var b bool
+		b, err = toBool(value)
+		s.Cache = &b
+	case "cache_ttl":
+		s.CacheTTL, err = toDuration(value)
+	case "cache_max_size":
+		s.CacheMaxSize, err = toSize(value)
+	case "budget.daily_tokens":
+		s.Budget.DailyTokens, err = toInt(value)
+	case "budget.monthly_tokens":
+		s.Budget.MonthlyTokens, err = toInt(value)
+	case "budget.daily_usd":
+		s.Budget.DailyUSD, err = toFloat(value)
+	case "budget.monthly_usd":
+		s.Budget.MonthlyUSD, err = toFloat(value)
+	case "proxy":
+		s.Proxy, err = toString(value)
+	case "no_proxy":
+		var hosts []string
+		hosts, err = toStringList(value)
+		s.NoProxy = strings.Join(hosts, ",")
+	case "ca_bundle":
+		s.CABundle, err = toString(value)
+	case "debug":
+		var b bool
+		b, err = toBool(value)
+		s.Debug = &b
+	case "debug_file":
+		s.DebugFile, err = toString(value)
+	case "credential_helper":
+		s.CredentialHelper, err = toString(value)
+	case "keyring":
+		var b bool
+		b, err = toBool(value)
+		s.Keyring = &b
+	default:
+		return fmt.Errorf("unknown key")
+	}
+
+	return err
+}
+
+func toString(value any) (string, error) {
+	s, ok := value.(string)
+	if !ok {
+		return "", fmt.Errorf("expected a string, got %v", value)
+	}
+	return s, nil
+}
+
+// toStringList accepts an array of strings or a comma-separated string.
+func toStringList(value any) ([]string, error) {
+	switch v := value.(type) {
+	case string:
+		var list []string
+		for _, item := range strings.Split(v, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+		return list, nil
+	case []any:
+		list := make([]string, 0, len(v))
+		for _, item := range v {
+			s, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("expected a list of strings, got %v", value)
+			}
+			list = append(list, s)
+		}
+		return list, nil
+	}
+	return nil, fmt.Errorf("expected a list of strings, got %v", value)
+}
+
+func toBool(value any) (bool, error) {
+	switch v := value.(type) {
+	case bool:
+		return v, nil
+	case string:
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return false, fmt.Errorf("expected a boolean, got %q", v)
+		}
+		return b, nil
+	}
+	return false, fmt.Errorf("expected a boolean, got %v", value)
+}
+
+func toInt(value any) (int, error) {
+	switch v := value.(type) {
+	case int64:
+		return int(v), nil
+	case string:
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return 0, fmt.Errorf("expected an integer, got %q", v)
+		}
+		return n, nil
+	}
+	return 0, fmt.Errorf("expected an integer, got %v", value)
+}
+
+func toFloat(value any) (float64, error) {
+	switch v := value.(type) {
+	case float64:
+		return v, nil
+	case int64:
+		return float64(v), nil
+	case string:
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return 0, fmt.Errorf("expected a number, got %q", v)
+		}
+		return f, nil
+	}
+	return 0, fmt.Errorf("expected a number, got %v", value)
+}
+
+// toSize accepts a number of bytes or a string with a KB, MB or GB suffix
+// such as "50MB".
+func toSize(value any) (int64, error) {
+	switch v := value.(type) {
+	case int64:
+		return v, nil
+	case string:
+		units := []struct {
+			suffix string
+			size   int64
+		}{{"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10}, {"B", 1}}
+
+		s := strings.ToUpper(strings.TrimSpace(v))
+		for _, u := range units {
+			if n, ok := strings.CutSuffix(s, u.suffix); ok {
+				size, err := strconv.ParseInt(strings.TrimSpace(n), 10, 64)
+				if err != nil {
+					break
+				}
+				return size * u.size, nil
+			}
+		}
+		if size, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return size, nil
+		}
+		return 0, fmt.Errorf("expected a size such as \"50MB\", got %q", v)
+	}
+	return 0, fmt.Errorf("expected a size, got %v", value)
+}
+
+// toDuration accepts Go duration strings such as "90s" or a whole number
+// of seconds.
+func toDuration(value any) (time.Duration, error) {
+	switch v := value.(type) {
+	case int64:
+		return time.Duration(v) * time.Second, nil
+	case string:
+		if n, err := strconv.Atoi(v); err == nil {
+			return time.Duration(n) * time.Second, nil
+		}
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return 0, fmt.Errorf("expected a duration, got %q", v)
+		}
+		return d, nil
+	}
+	return 0, fmt.Errorf("expected a duration, got %v", value)
+}
+
+// findRepoFile looks for RepoFileName from the working directory upwards,
+// stopping at the first directory that contains .git.
+func findRepoFile() string {
+	dir, err := os.Getwd()
+	if err != nil {
+		return ""
+	}
+
+	for {
+		path := filepath.Join(dir, RepoFileName)
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return ""
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
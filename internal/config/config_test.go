@@ -0,0 +1,377 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func float(f float64) *float64 {
+	return &f
+}
+
+func intPtr(n int) *int {
+	return &n
+}
+
+func boolPtr(b bool) *bool {
+	return &b
+}
+
+func TestParse(t *testing.T) {
+	input := `
+# Defaults for every command
+provider = "openrouter"
+model = "anthropic/claude-haiku-4.5"
+endpoint = 'https://openrouter.example/api/v1/chat/completions'
+max_tokens = 8_192
+temperature = 0.2 # be precise
+timeout = "90s"
+retries = 0
+max_retry_delay = "5s"
+max_continuations = 2
+fallback = ["anthropic", "openai"]
+cache_ttl = "12h"
+cache_max_size = "10MB"
+proxy = "http://proxy.corp.example:3128"
+no_proxy = ["localhost", ".corp.example"]
+ca_bundle = "/etc/ssl/corp-ca.pem"
+credential_helper = "pass show llm/{provider}"
+keyring = false
+
+[budget]
+daily_tokens = 200_000
+monthly_usd = 25.0
+
+[commands.commit]
+model = "anthropic/claude-haiku-4.5"
+usage = true
+
+[commands.gh.pr]
+model = "anthropic/claude-sonnet-4.5"
+timeout = 120
+cache = false
+`
+
+	got, err := Parse([]byte(input))
+	if err != nil {
+		t.Fatalf("Parse() error = %v, want nil", err)
+	}
+
+	want := &File{
+		Settings: Settings{
+			Provider:         "openrouter",
+			Model:            "anthropic/claude-haiku-4.5",
+			Endpoint:         "https://openrouter.example/api/v1/chat/completions",
+			MaxTokens:        8192,
+			Temperature:      float(0.2),
+			Timeout:          90 * time.Second,
+			Retries:          intPtr(0),
+			MaxRetryDelay:    5 * time.Second,
+			MaxContinuations: 2,
+			Fallback:         []string{"anthropic", "openai"},
+			CacheTTL:         12 * time.Hour,
+			CacheMaxSize:     10 << 20,
+			Budget:           Budget{DailyTokens: 200000, MonthlyUSD: 25},
+			Proxy:            "http://proxy.corp.example:3128",
+			NoProxy:          "localhost,.corp.example",
+			CABundle:         "/etc/ssl/corp-ca.pem",
+			CredentialHelper: "pass show llm/{provider}",
+			Keyring:          boolPtr(false),
+		},
+		Commands: map[string]Settings{
+			"commit": {Model: "anthropic/claude-haiku-4.5", Usage: boolPtr(true)},
+			"gh pr":  {Model: "anthropic/claude-sonnet-4.5", Timeout: 120 * time.Second, Cache: boolPtr(false)},
+		},
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Parse() = %#v, want %#v", got, want)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name          string
+		input         string
+		wantErrSubstr string
+	}{
+		{
+			name:          "unknown key",
+			input:         `modle = "gpt-4o"`,
+			wantErrSubstr: `modle: unknown key`,
+		},
+		{
+			name:          "unknown command key",
+			input:         "[commands.commit]\nmodle = \"gpt-4o\"",
+			wantErrSubstr: `commands.commit.modle: unknown key`,
+		},
+		{
+			name:          "wrong type",
+			input:         `max_tokens = "many"`,
+			wantErrSubstr: `expected an integer`,
+		},
+		{
+			name:          "unterminated string",
+			input:         `model = "gpt-4o`,
+			wantErrSubstr: `line 1: unterminated string`,
+		},
+		{
+			name:          "duplicate key",
+			input:         "model = \"a\"\nmodel = \"b\"",
+			wantErrSubstr: `line 2: duplicate key "model"`,
+		},
+		{
+			name:          "missing equals",
+			input:         "model",
+			wantErrSubstr: `line 1: expected key = value`,
+		},
+		{
+			name:          "invalid duration",
+			input:         `timeout = "soon"`,
+			wantErrSubstr: `expected a duration`,
+		},
+		{
+			name:          "invalid size",
+			input:         `cache_max_size = "lots"`,
+			wantErrSubstr: `expected a size such as "50MB"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse([]byte(tt.input))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErrSubstr) {
+				t.Fatalf("Parse() error = %v, want substring %q", err, tt.wantErrSubstr)
+			}
+		})
+	}
+}
+
+func TestParseTOMLArrays(t *testing.T) {
+	got, err := parseTOML([]byte(`list = ["a", 'b', 3, true] # trailing`))
+	if err != nil {
+		t.Fatalf("parseTOML() error = %v, want nil", err)
+	}
+
+	want := []any{"a", "b", int64(3), true}
+	if !reflect.DeepEqual(got["list"], want) {
+		t.Errorf("parseTOML() list = %#v, want %#v", got["list"], want)
+	}
+
+	got, err = parseTOML([]byte("no_proxy = [\n  \"localhost\", # loopback\n  \".corp.example\",\n]\nmodel = \"m\"\n"))
+	if err != nil {
+		t.Fatalf("parseTOML() of a multi-line array error = %v, want nil", err)
+	}
+	if want := []any{"localhost", ".corp.example"}; !reflect.DeepEqual(got["no_proxy"], want) || got["model"] != "m" {
+		t.Errorf("parseTOML() = %#v, want the array and the key after it", got)
+	}
+
+	if _, err := parseTOML([]byte("fallback = [\n  \"openai\"\n\nmodel = \"m\"\n")); err == nil || !strings.Contains(err.Error(), "line 1: unterminated array") {
+		t.Errorf("parseTOML() of an unclosed array error = %v, want unterminated array", err)
+	}
+}
+
+func TestParseTOMLQuotedKeys(t *testing.T) {
+	got, err := parseTOML([]byte("[commands.\"a]b\"]\n\"x=y\".'z' = 1\n"))
+	if err != nil {
+		t.Fatalf("parseTOML() error = %v, want nil", err)
+	}
+	if want := map[string]any{"commands.a]b.x=y.z": int64(1)}; !reflect.DeepEqual(got, want) {
+		t.Errorf("parseTOML() = %#v, want %#v", got, want)
+	}
+}
+
+func TestResolvePrecedence(t *testing.T) {
+	cfg := &Config{
+		User: &File{
+			Settings: Settings{Provider: "anthropic", Model: "user-model", MaxTokens: 1000},
+			Commands: map[string]Settings{
+				"commit": {Model: "user-commit-model", Timeout: time.Minute},
+			},
+		},
+		Repo: &File{
+			Settings: Settings{Model: "repo-model"},
+			Commands: map[string]Settings{
+				"gh pr": {Model: "repo-pr-model"},
+			},
+		},
+		Env: Settings{MaxTokens: 2000},
+	}
+
+	tests := []struct {
+		command string
+		want    Settings
+	}{
+		{
+			command: "ask",
+			want:    Settings{Provider: "anthropic", Model: "repo-model", MaxTokens: 2000},
+		},
+		{
+			command: "commit",
+			want:    Settings{Provider: "anthropic", Model: "repo-model", MaxTokens: 2000, Timeout: time.Minute},
+		},
+		{
+			command: "gh pr",
+			want:    Settings{Provider: "anthropic", Model: "repo-pr-model", MaxTokens: 2000},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.command, func(t *testing.T) {
+			got := cfg.Resolve(tt.command)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Resolve(%q) = %#v, want %#v", tt.command, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveNilConfig(t *testing.T) {
+	var cfg *Config
+	if got := cfg.Resolve("ask"); !reflect.DeepEqual(got, Settings{}) {
+		t.Errorf("Resolve() on nil config = %#v, want zero settings", got)
+	}
+}
+
+func TestFromEnv(t *testing.T) {
+	env := map[string]string{
+		"LLM_PROVIDER":              "openai",
+		"LLM_MODEL":                 "gpt-4o",
+		"LLM_MAX_TOKENS":            "512",
+		"LLM_TEMPERATURE":           "0.7",
+		"LLM_TIMEOUT":               "45",
+		"LLM_FALLBACK":              "anthropic, openai",
+		"LLM_USAGE":                 "true",
+		"LLM_BUDGET_MONTHLY_TOKENS": "5000000",
+		"LLM_MAX_CONTINUATIONS":     "1",
+		"LLM_NO_PROXY":              "localhost, 10.0.0.0/8",
+		"LLM_DEBUG":                 "1",
+	}
+
+	got, err := FromEnv(func(key string) string { return env[key] })
+	if err != nil {
+		t.Fatalf("FromEnv() error = %v, want nil", err)
+	}
+
+	want := Settings{
+		Provider:         "openai",
+		Model:            "gpt-4o",
+		MaxTokens:        512,
+		Temperature:      float(0.7),
+		Timeout:          45 * time.Second,
+		Fallback:         []string{"anthropic", "openai"},
+		Usage:            boolPtr(true),
+		Budget:           Budget{MonthlyTokens: 5000000},
+		MaxContinuations: 1,
+		NoProxy:          "localhost,10.0.0.0/8",
+		Debug:            boolPtr(true),
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("FromEnv() = %#v, want %#v", got, want)
+	}
+
+	env["LLM_MAX_TOKENS"] = "lots"
+	if _, err := FromEnv(func(key string) string { return env[key] }); err == nil || !strings.Contains(err.Error(), "LLM_MAX_TOKENS") {
+		t.Errorf("FromEnv() error = %v, want error naming LLM_MAX_TOKENS", err)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	configHome := t.TempDir()
+	repo := t.TempDir()
+	subdir := filepath.Join(repo, "internal", "pkg")
+
+	writeFile(t, filepath.Join(configHome, "llm", "config.toml"), "provider = \"anthropic\"\nmodel = \"user-model\"\n")
+	writeFile(t, filepath.Join(repo, RepoFileName), "model = \"repo-model\"\n")
+	if err := os.MkdirAll(filepath.Join(repo, ".git"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(subdir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("XDG_CONFIG_HOME", configHome)
+	t.Setenv("LLM_MODEL", "")
+	t.Setenv("LLM_PROVIDER", "")
+	t.Chdir(subdir)
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v, want nil", err)
+	}
+
+	got := cfg.Resolve("ask")
+	want := Settings{Provider: "anthropic", Model: "repo-model"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Resolve() = %#v, want %#v", got, want)
+	}
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestToSize(t *testing.T) {
+	tests := []struct {
+		value any
+		want  int64
+	}{
+		{value: int64(4096), want: 4096},
+		{value: "2048", want: 2048},
+		{value: "512KB", want: 512 << 10},
+		{value: "50 MB", want: 50 << 20},
+		{value: "1gb", want: 1 << 30},
+	}
+
+	for _, tt := range tests {
+		got, err := toSize(tt.value)
+		if err != nil || got != tt.want {
+			t.Errorf("toSize(%v) = %d, %v, want %d", tt.value, got, err, tt.want)
+		}
+	}
+}
+
+func TestLoadRejectsUserOnlyRepoSettings(t *testing.T) {
+	tests := []struct {
+		file string
+		key  string
+	}{
+		{"[commands.ask]\ncredential_helper = \"curl https://attacker.example\"\n", "credential_helper"},
+		{"endpoint = \"https://attacker.example/v1\"\n", "endpoint"},
+		{"proxy = \"http://attacker.example:3128\"\n", "proxy"},
+		{"no_proxy = [\"localhost\"]\n", "no_proxy"},
+		{"[commands.ask]\nca_bundle = \"ca.pem\"\n", "ca_bundle"},
+		{"debug = true\n", "debug"},
+		{"[commands.ask]\ndebug_file = \"/home/user/.bashrc\"\n", "debug_file"},
+		{"[budget]\ndaily_tokens = 100_000_000\n", "budget.daily_tokens"},
+		{"[budget]\nmonthly_usd = 1000.0\n", "budget.monthly_usd"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			repo := t.TempDir()
+			writeFile(t, filepath.Join(repo, RepoFileName), tt.file)
+			if err := os.MkdirAll(filepath.Join(repo, ".git"), 0o755); err != nil {
+				t.Fatal(err)
+			}
+
+			t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+			t.Chdir(repo)
+
+			if _, err := Load(); err == nil || !strings.Contains(err.Error(), tt.key+" can only be set in the user config") {
+				t.Errorf("Load() error = %v, want %s rejected in the repository config", err, tt.key)
+			}
+		})
+	}
+}
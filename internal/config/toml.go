@@ -0,0 +1,231 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// parseTOML parses the subset of TOML used by llm config files: tables,
+// dotted and quoted keys, strings, integers, floats, booleans and arrays,
+// which may span lines. Multi-line strings, dates, inline tables and arrays
+// of tables are not supported. Values are returned keyed by their full
+// dotted path.
+func parseTOML(data []byte) (map[string]any, error) {
+	values := make(map[string]any)
+	var table []string
+
+	src := strings.ReplaceAll(string(data), "\r\n", "\n")
+	s := src
+	for {
+		s = skipBlank(s)
+		if s == "" {
+			return values, nil
+		}
+		lineNo := strings.Count(src[:len(src)-len(s)], "\n") + 1
+
+		var err error
+		if strings.HasPrefix(s, "[") {
+			table, s, err = parseTableHeader(s)
+		} else {
+			s, err = parseKeyValue(s, table, values)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNo, err)
+		}
+	}
+}
+
+// parseTableHeader parses a [table] line and returns its key.
+func parseTableHeader(s string) ([]string, string, error) {
+	if strings.HasPrefix(s, "[[") {
+		return nil, "", fmt.Errorf("arrays of tables are not supported")
+	}
+
+	key, rest, err := parseKey(s[1:])
+	if err != nil {
+		return nil, "", err
+	}
+	if !strings.HasPrefix(rest, "]") {
+		return nil, "", fmt.Errorf("unterminated table header")
+	}
+
+	rest, err = endOfLine(rest[1:], "table header")
+	return key, rest, err
+}
+
+// parseKeyValue parses a key = value pair, which may span lines when the
+// value is an array, and stores it in values under table.
+func parseKeyValue(s string, table []string, values map[string]any) (string, error) {
+	key, rest, err := parseKey(s)
+	if err != nil {
+		return "", err
+	}
+	if !strings.HasPrefix(rest, "=") {
+		return "", fmt.Errorf("expected key = value")
+	}
+
+	value, rest, err := parseValue(skipSpace(rest[1:]))
+	if err != nil {
+		return "", err
+	}
+
+	path := strings.Join(append(append([]string{}, table...), key...), ".")
+	if _, ok := values[path]; ok {
+		return "", fmt.Errorf("duplicate key %q", path)
+	}
+	values[path] = value
+
+	return endOfLine(rest, "value")
+}
+
+// endOfLine checks that only a comment follows what was parsed on the
+// line, and returns the lines after it.
+func endOfLine(s, what string) (string, error) {
+	line, rest, _ := strings.Cut(skipSpace(s), "\n")
+	if line != "" && !strings.HasPrefix(line, "#") {
+		return "", fmt.Errorf("unexpected %q after %s", line, what)
+	}
+	return rest, nil
+}
+
+// skipSpace skips spaces and tabs.
+func skipSpace(s string) string {
+	return strings.TrimLeft(s, " \t")
+}
+
+// skipBlank skips whitespace, newlines and comments.
+func skipBlank(s string) string {
+	for {
+		s = strings.TrimLeft(s, " \t\n")
+		if !strings.HasPrefix(s, "#") {
+			return s
+		}
+		_, s, _ = strings.Cut(s, "\n")
+	}
+}
+
+// parseKey parses a bare, quoted or dotted key and returns its parts and
+// the input after it.
+func parseKey(s string) ([]string, string, error) {
+	var parts []string
+	s = skipSpace(s)
+
+	for {
+		var part string
+		switch {
+		case strings.HasPrefix(s, `"`), strings.HasPrefix(s, "'"):
+			value, rest, err := parseString(s)
+			if err != nil {
+				return nil, "", err
+			}
+			part, s = value, rest
+		default:
+			end := strings.IndexFunc(s, func(r rune) bool { return !isBareKeyRune(r) })
+			if end < 0 {
+				end = len(s)
+			}
+			part, s = s[:end], s[end:]
+			if part == "" {
+				line, _, _ := strings.Cut(s, "\n")
+				return nil, "", fmt.Errorf("invalid key near %q", line)
+			}
+		}
+		parts = append(parts, part)
+
+		s = skipSpace(s)
+		if !strings.HasPrefix(s, ".") {
+			return parts, s, nil
+		}
+		s = skipSpace(s[1:])
+	}
+}
+
+func isBareKeyRune(r rune) bool {
+	return r == '_' || r == '-' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9')
+}
+
+func parseValue(s string) (any, string, error) {
+	switch {
+	case s == "" || s[0] == '\n' || s[0] == '#':
+		return nil, "", fmt.Errorf("missing value")
+	case s[0] == '"' || s[0] == '\'':
+		return parseString(s)
+	case s[0] == '[':
+		return parseArray(s)
+	}
+
+	end := strings.IndexAny(s, " \t\n,]#")
+	if end < 0 {
+		end = len(s)
+	}
+	token, rest := s[:end], s[end:]
+
+	switch token {
+	case "true":
+		return true, rest, nil
+	case "false":
+		return false, rest, nil
+	}
+
+	number := strings.ReplaceAll(token, "_", "")
+	if n, err := strconv.ParseInt(number, 10, 64); err == nil {
+		return n, rest, nil
+	}
+	if f, err := strconv.ParseFloat(number, 64); err == nil {
+		return f, rest, nil
+	}
+
+	return nil, "", fmt.Errorf("invalid value %q", token)
+}
+
+func parseString(s string) (string, string, error) {
+	quote := s[0]
+	for i := 1; i < len(s); i++ {
+		switch {
+		case s[i] == '\n':
+			return "", "", fmt.Errorf("unterminated string %s", s[:i])
+		case quote == '"' && s[i] == '\\':
+			i++
+		case s[i] == quote:
+			if quote == '\'' {
+				return s[1:i], s[i+1:], nil
+			}
+			value, err := strconv.Unquote(s[:i+1])
+			if err != nil {
+				return "", "", fmt.Errorf("invalid string %s", s[:i+1])
+			}
+			return value, s[i+1:], nil
+		}
+	}
+
+	return "", "", fmt.Errorf("unterminated string %s", s)
+}
+
+// parseArray parses an array, whose values may be spread over several
+// lines with comments between them and a trailing comma.
+func parseArray(s string) ([]any, string, error) {
+	values := []any{}
+	s = skipBlank(s[1:])
+
+	for {
+		if strings.HasPrefix(s, "]") {
+			return values, s[1:], nil
+		}
+
+		value, rest, err := parseValue(s)
+		if err != nil {
+			return nil, "", err
+		}
+		values = append(values, value)
+
+		s = skipBlank(rest)
+		switch {
+		case strings.HasPrefix(s, ","):
+			s = skipBlank(s[1:])
+		case strings.HasPrefix(s, "]"):
+		default:
+			return nil, "", fmt.Errorf("unterminated array")
+		}
+	}
+}
@@ -0,0 +1,158 @@
+// Package credentials looks up provider API keys outside the environment:
+// from a credential helper command or from the system keyring.
+package credentials
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// ErrNotFound is returned when no key is stored for a provider.
+var ErrNotFound = errors.New("no API key stored")
+
+// Where a key was found, as reported by Lookup.
+const (
+	SourceHelper  = "credential helper"
+	SourceKeyring = "keyring"
+)
+
+// Keyring stores API keys by provider name.
+type Keyring interface {
+	Get(provider string) (string, error)
+	Set(provider, key string) error
+	Delete(provider string) error
+}
+
+// DefaultKeyring is consulted by Lookup when the keyring is not disabled.
+// Tests replace it to keep the real keyring out of their results.
+var DefaultKeyring Keyring = SecretService{Command: "secret-tool"}
+
+// Lookup returns the API key for provider and where it came from. The
+// credential helper is tried first, when one is configured, and then
+// keyring unless it is nil. When neither has a key it returns the error of
+// the helper, if it failed, or ErrNotFound.
+func Lookup(helper string, keyring Keyring, provider string) (key, source string, err error) {
+	var helperErr error
+	if helper != "" {
+		key, err := Helper(helper, provider)
+		if err == nil {
+			return key, SourceHelper, nil
+		}
+		if !errors.Is(err, ErrNotFound) {
+			helperErr = err
+		}
+	}
+
+	if keyring != nil {
+		key, err := keyring.Get(provider)
+		if err == nil {
+			return key, SourceKeyring, nil
+		}
+		if !errors.Is(err, ErrNotFound) {
+			return "", "", err
+		}
+	}
+
+	if helperErr != nil {
+		return "", "", helperErr
+	}
+	return "", "", ErrNotFound
+}
+
+// Helper runs command with sh, replacing {provider} with the provider
+// name, and returns the first line of its output as the key. The command
+// reads from the terminal, when there is one, so that it can ask for a
+// passphrase without consuming input piped to llm. Empty output means the
+// helper has no key for provider.
+func Helper(command, provider string) (string, error) {
+	cmd := exec.Command("sh", "-c", strings.ReplaceAll(command, "{provider}", provider))
+	if tty, err := os.Open("/dev/tty"); err == nil {
+		defer tty.Close()
+		cmd.Stdin = tty
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("credential helper for %s: %w: %s", provider, err, msg)
+		}
+		return "", fmt.Errorf("credential helper for %s: %w", provider, err)
+	}
+
+	// Tools like pass print the secret on the first line and metadata
+	// after it.
+	key, _, _ := strings.Cut(string(out), "\n")
+	if key = strings.TrimSpace(key); key == "" {
+		return "", ErrNotFound
+	}
+	return key, nil
+}
+
+// SecretService stores keys in the freedesktop Secret Service (GNOME
+// Keyring, KWallet) through the secret-tool command from libsecret.
+type SecretService struct {
+	Command string
+}
+
+// Available reports whether the secret-tool command is installed.
+func (s SecretService) Available() bool {
+	_, err := exec.LookPath(s.Command)
+	return err == nil
+}
+
+func (s SecretService) Get(provider string) (string, error) {
+	if !s.Available() {
+		return "", ErrNotFound
+	}
+
+	cmd := exec.Command(s.Command, "lookup", "service", "llm", "provider", provider)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	key := strings.TrimSpace(string(out))
+
+	// secret-tool exits with status 1 and no output when nothing matches,
+	// and with the same status and a message when the keyring cannot be
+	// reached.
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil && key != "":
+		return key, nil
+	case err == nil, errors.As(err, &exitErr) && exitErr.ExitCode() == 1 && key == "" && stderr.Len() == 0:
+		return "", ErrNotFound
+	}
+	if msg := strings.TrimSpace(stderr.String()); msg != "" {
+		return "", fmt.Errorf("reading keyring: %w: %s", err, msg)
+	}
+	return "", fmt.Errorf("reading keyring: %w", err)
+}
+
+func (s SecretService) Set(provider, key string) error {
+	if !s.Available() {
+		return fmt.Errorf("no keyring available: %s not found", s.Command)
+	}
+
+	cmd := exec.Command(s.Command, "store", "--label", "llm "+provider+" API key", "service", "llm", "provider", provider)
+	cmd.Stdin = strings.NewReader(key)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("writing keyring: %w: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+func (s SecretService) Delete(provider string) error {
+	if !s.Available() {
+		return fmt.Errorf("no keyring available: %s not found", s.Command)
+	}
+
+	if out, err := exec.Command(s.Command, "clear", "service", "llm", "provider", provider).CombinedOutput(); err != nil {
+		return fmt.Errorf("clearing keyring: %w: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
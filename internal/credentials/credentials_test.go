@@ -0,0 +1,179 @@
+package credentials
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeScript creates an executable shell script in a temporary directory.
+func writeScript(t *testing.T, name, body string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+body), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestHelper(t *testing.T) {
+	script := writeScript(t, "pass", `
+case "$2" in
+  llm/openai) printf 'sk-openai\nlogin: me@example.com\n' ;;
+  llm/empty) ;;
+  *) echo "Error: $2 is not in the password store." >&2; exit 1 ;;
+esac
+`)
+	helper := script + " show llm/{provider}"
+
+	key, err := Helper(helper, "openai")
+	if err != nil || key != "sk-openai" {
+		t.Errorf("Helper(openai) = %q, %v, want first line of output", key, err)
+	}
+
+	if _, err := Helper(helper, "empty"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Helper(empty) error = %v, want ErrNotFound", err)
+	}
+
+	_, err = Helper(helper, "gemini")
+	if err == nil || !strings.Contains(err.Error(), "llm/gemini is not in the password store") {
+		t.Errorf("Helper(gemini) error = %v, want the helper's stderr", err)
+	}
+}
+
+func TestHelperLeavesStdinAlone(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	original := os.Stdin
+	t.Cleanup(func() { os.Stdin = original; r.Close() })
+	os.Stdin = r
+
+	if _, err := w.WriteString("piped input"); err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+
+	// The helper drains whatever it is given unless it is a terminal.
+	helper := writeScript(t, "helper", "[ -t 0 ] || cat >/dev/null\necho sk-key\n")
+	if key, err := Helper(helper, "openai"); err != nil || key != "sk-key" {
+		t.Fatalf("Helper() = %q, %v, want sk-key", key, err)
+	}
+
+	if data, _ := io.ReadAll(r); string(data) != "piped input" {
+		t.Errorf("stdin after Helper() = %q, want the piped input left for llm", data)
+	}
+}
+
+func TestSecretService(t *testing.T) {
+	store := t.TempDir()
+	// A stand-in for secret-tool that keeps each secret in a file named
+	// after its provider attribute.
+	secretTool := writeScript(t, "secret-tool", `
+store="`+store+`"
+case "$1" in
+  lookup) cat "$store/$5" 2>/dev/null || exit 1 ;;
+  store) cat > "$store/$7" ;;
+  clear) rm -f "$store/$5" ;;
+esac
+`)
+	keyring := SecretService{Command: secretTool}
+
+	if _, err := keyring.Get("openai"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Get() before Set error = %v, want ErrNotFound", err)
+	}
+
+	if err := keyring.Set("openai", "sk-stored"); err != nil {
+		t.Fatalf("Set() error = %v, want nil", err)
+	}
+	if key, err := keyring.Get("openai"); err != nil || key != "sk-stored" {
+		t.Errorf("Get() = %q, %v, want stored key", key, err)
+	}
+
+	if err := keyring.Delete("openai"); err != nil {
+		t.Fatalf("Delete() error = %v, want nil", err)
+	}
+	if _, err := keyring.Get("openai"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get() after Delete error = %v, want ErrNotFound", err)
+	}
+
+	locked := SecretService{Command: writeScript(t, "secret-tool", `echo "Cannot autolaunch D-Bus without X11 $DISPLAY" >&2; exit 1`)}
+	if _, err := locked.Get("openai"); err == nil || errors.Is(err, ErrNotFound) || !strings.Contains(err.Error(), "Cannot autolaunch D-Bus") {
+		t.Errorf("Get() from an unreachable keyring error = %v, want its message", err)
+	}
+
+	missing := SecretService{Command: filepath.Join(t.TempDir(), "secret-tool")}
+	if _, err := missing.Get("openai"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get() without secret-tool error = %v, want ErrNotFound", err)
+	}
+	if err := missing.Set("openai", "sk"); err == nil {
+		t.Error("Set() without secret-tool error = nil, want error")
+	}
+}
+
+// errHelper stands for the error reported by a failing credential helper.
+var errHelper = errors.New("helper failed")
+
+type mapKeyring map[string]string
+
+func (m mapKeyring) Get(provider string) (string, error) {
+	if key, ok := m[provider]; ok {
+		return key, nil
+	}
+	return "", ErrNotFound
+}
+
+func (m mapKeyring) Set(provider, key string) error {
+	m[provider] = key
+	return nil
+}
+
+func (m mapKeyring) Delete(provider string) error {
+	delete(m, provider)
+	return nil
+}
+
+func TestLookup(t *testing.T) {
+	helper := writeScript(t, "helper", `
+case "$1" in
+  openai) echo sk-helper ;;
+  azure) echo "vault sealed" >&2; exit 1 ;;
+esac
+`) + " {provider}"
+	keyring := mapKeyring{"openai": "sk-keyring", "gemini": "gm-keyring"}
+
+	tests := []struct {
+		name       string
+		helper     string
+		keyring    Keyring
+		provider   string
+		wantKey    string
+		wantSource string
+		wantErr    error
+	}{
+		{"helper first", helper, keyring, "openai", "sk-helper", SourceHelper, nil},
+		{"keyring when helper has none", helper, keyring, "gemini", "gm-keyring", SourceKeyring, nil},
+		{"keyring without helper", "", keyring, "openai", "sk-keyring", SourceKeyring, nil},
+		{"nothing stored", helper, keyring, "anthropic", "", "", ErrNotFound},
+		{"helper failure without a stored key", helper, keyring, "azure", "", "", errHelper},
+		{"keyring disabled", "", nil, "openai", "", "", ErrNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, source, err := Lookup(tt.helper, tt.keyring, tt.provider)
+			matches := errors.Is(err, tt.wantErr)
+			if tt.wantErr == errHelper {
+				matches = err != nil && strings.Contains(err.Error(), "vault sealed")
+			}
+			if key != tt.wantKey || source != tt.wantSource || !matches {
+				t.Errorf("Lookup() = %q, %q, %v, want %q, %q, %v", key, source, err, tt.wantKey, tt.wantSource, tt.wantErr)
+			}
+		})
+	}
+}
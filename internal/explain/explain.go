@@ -0,0 +1,357 @@
+// Package explain implements llm explain, which runs a command and, when it
+// fails, asks for a diagnosis of its output.
+package explain
+
+import (
+	"bufio"
+	"context"
+	_ "embed"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"llm/internal/loading"
+	"llm/internal/markdown"
+	"llm/internal/providers"
+	"llm/internal/sh"
+	"llm/internal/term"
+)
+
+//go:embed prompt.md
+var systemPrompt string
+
+const usage = "usage: llm explain [--raw] (--last | [--] <command>...)"
+
+const (
+	// maxOutputLines and maxOutputBytes bound the tail of the output sent
+	// for diagnosis; the error is almost always near the end.
+	maxOutputLines = 200
+	maxOutputBytes = 16 << 10
+	// versionTimeout bounds each command run to find a tool's version.
+	versionTimeout = 5 * time.Second
+)
+
+var (
+	toolVersion = toolVersionExec
+	currentEnv  = sh.CurrentEnv
+	interactive = term.IsTerminal
+)
+
+// versionCommands report the version of the tools a failure may depend on,
+// keyed by the program that is run.
+var versionCommands = map[string][]string{
+	"go":      {"go", "env", "GOVERSION"},
+	"nix":     {"nix", "--version"},
+	"cargo":   {"cargo", "--version"},
+	"node":    {"node", "--version"},
+	"npm":     {"npm", "--version"},
+	"python":  {"python", "--version"},
+	"python3": {"python3", "--version"},
+}
+
+type Config struct {
+	// Last reruns the previous shell command recorded by the hook, after
+	// asking when stdin is a terminal.
+	Last bool
+	// Raw writes the diagnosis as it is, without rendering Markdown.
+	Raw bool
+}
+
+// ParseConfig reads the flags at the start of args and returns the command
+// that follows them, after an optional --.
+func ParseConfig(args []string) (*Config, []string, error) {
+	cfg := &Config{}
+
+	for i, arg := range args {
+		switch arg {
+		case "--last":
+			cfg.Last = true
+		case "--raw":
+			cfg.Raw = true
+		case "--":
+			return cfg, args[i+1:], nil
+		default:
+			if strings.HasPrefix(arg, "-") {
+				return nil, nil, fmt.Errorf("unknown flag %s; put the command after --", arg)
+			}
+			return cfg, args[i:], nil
+		}
+	}
+
+	return cfg, nil, nil
+}
+
+// ExitError reports that the command failed with Code, so that llm can exit
+// with the same status once the failure has been explained.
+type ExitError struct {
+	Code int
+}
+
+func (e *ExitError) Error() string {
+	return fmt.Sprintf("command exited with status %d", e.Code)
+}
+
+// Result is the outcome of running a command.
+type Result struct {
+	// Command is the command line as the user would type it.
+	Command  string
+	ExitCode int
+	// Output is the tail of stdout and stderr, interleaved as written.
+	Output string
+}
+
+// Execute runs argv with the given streams, copying its output into the
+// result as well. A program that cannot be found or run fails with the
+// status and message a shell would give, 127 or 126.
+func Execute(ctx context.Context, command string, argv []string, stdin io.Reader, stdout, stderr io.Writer) (*Result, error) {
+	var output tail
+	cmd := exec.CommandContext(ctx, argv[0], argv[1:]...)
+	cmd.Stdin = stdin
+	cmd.Stdout = io.MultiWriter(stdout, &output)
+	cmd.Stderr = io.MultiWriter(stderr, &output)
+
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil, errors.As(err, &exitErr):
+		return &Result{Command: command, ExitCode: cmd.ProcessState.ExitCode(), Output: output.String()}, nil
+	case errors.Is(err, exec.ErrNotFound), errors.Is(err, fs.ErrNotExist):
+		_, _ = fmt.Fprintf(cmd.Stderr, "%s: command not found\n", argv[0])
+		return &Result{Command: command, ExitCode: 127, Output: output.String()}, nil
+	case errors.Is(err, fs.ErrPermission):
+		_, _ = fmt.Fprintf(cmd.Stderr, "%s: permission denied\n", argv[0])
+		return &Result{Command: command, ExitCode: 126, Output: output.String()}, nil
+	}
+	return nil, err
+}
+
+func BuildPrompt(r *Result, env sh.Env, versions []string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Command: %s\nExit status: %d\n", r.Command, r.ExitCode)
+	fmt.Fprintf(&b, "OS: %s\nShell: %s\nWorking directory: %s\n", env.OS, env.Shell, env.Dir)
+	for _, v := range versions {
+		fmt.Fprintf(&b, "%s\n", v)
+	}
+
+	output := strings.TrimRight(r.Output, "\n")
+	if output == "" {
+		output = "(no output)"
+	}
+	fmt.Fprintf(&b, "\nOutput:\n<output>\n%s\n</output>", output)
+	return b.String()
+}
+
+// Run executes the command in args, or with --last the previous shell
+// command once confirmed, showing its output as it runs. When the command fails, the
+// diagnosis is streamed to stdout and an *ExitError with the command's
+// status is returned.
+func Run(ctx context.Context, provider providers.Provider, stdin io.Reader, stdout, stderr io.Writer, args []string) error {
+	cfg, args, err := ParseConfig(args)
+	if err != nil {
+		return err
+	}
+
+	env := currentEnv()
+
+	var command string
+	var argv []string
+	switch {
+	case cfg.Last && len(args) > 0:
+		return fmt.Errorf("%s", usage)
+	case cfg.Last:
+		if command, err = LastCommand(); err != nil {
+			return err
+		}
+		// The line may use pipes, globs and quoting, so the shell runs it.
+		argv = []string{env.Shell, "-c", command}
+		if interactive(stdin) {
+			ok, err := confirm(ctx, stdin, stderr, command)
+			if err != nil || !ok {
+				return err
+			}
+		}
+		_, _ = fmt.Fprintf(stderr, "Running: %s\n", command)
+	case len(args) == 0:
+		return fmt.Errorf("%s", usage)
+	default:
+		command, argv = strings.Join(args, " "), args
+	}
+
+	result, err := Execute(ctx, command, argv, stdin, stdout, stderr)
+	if err != nil {
+		return err
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if result.ExitCode == 0 {
+		_, _ = fmt.Fprintln(stderr, "The command succeeded; there is nothing to explain.")
+		return nil
+	}
+
+	_, _ = fmt.Fprintf(stderr, "\nThe command exited with status %d. Asking for a diagnosis", result.ExitCode)
+
+	answer := stdout
+	var md *markdown.Renderer
+	if !cfg.Raw && term.IsTerminal(stdout) && os.Getenv("NO_COLOR") == "" {
+		md = markdown.NewRenderer(stdout)
+		answer = md
+	}
+
+	prompt := BuildPrompt(result, env, versions(ctx, argv, cfg.Last))
+
+	ind := loading.Start(stderr)
+	_, err = providers.Stream(ctx, provider, systemPrompt, prompt, func(delta string) error {
+		ind.Stop()
+		_, err := io.WriteString(answer, delta)
+		return err
+	})
+	ind.Stop()
+
+	if md != nil {
+		if flushErr := md.Flush(); err == nil {
+			err = flushErr
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	_, _ = fmt.Fprintln(stdout)
+	return &ExitError{Code: result.ExitCode}
+}
+
+// confirm asks on stderr whether command should be run again, since it
+// may do more than fail, and reads the answer from stdin. Only a yes runs
+// it.
+func confirm(ctx context.Context, stdin io.Reader, stderr io.Writer, command string) (bool, error) {
+	_, _ = fmt.Fprintf(stderr, "Run `%s` again to explain it? [y/N] ", command)
+
+	type answer struct {
+		line string
+		err  error
+	}
+	read := make(chan answer, 1)
+	go func() {
+		line, err := bufio.NewReader(stdin).ReadString('\n')
+		read <- answer{line, err}
+	}()
+
+	var a answer
+	select {
+	case <-ctx.Done():
+		_, _ = fmt.Fprintln(stderr)
+		return false, ctx.Err()
+	case a = <-read:
+	}
+
+	if a.err != nil && !errors.Is(a.err, io.EOF) {
+		return false, fmt.Errorf("reading answer: %w", a.err)
+	}
+	switch strings.ToLower(strings.TrimSpace(a.line)) {
+	case "y", "yes":
+		return true, nil
+	}
+	if errors.Is(a.err, io.EOF) {
+		_, _ = fmt.Fprintln(stderr)
+	}
+	_, _ = fmt.Fprintln(stderr, "Not running it.")
+	return false, nil
+}
+
+// versions returns the version of Go, when it is installed, and of the
+// program the command runs, when it is a known tool. The program of a
+// command line rerun by the shell is its first word.
+func versions(ctx context.Context, argv []string, shell bool) []string {
+	program := argv[0]
+	if shell {
+		program, _, _ = strings.Cut(strings.TrimSpace(argv[2]), " ")
+	}
+	program = filepath.Base(program)
+
+	names := []string{"go"}
+	if program != "go" {
+		names = append(names, program)
+	}
+
+	var out []string
+	for _, name := range names {
+		versionCmd, ok := versionCommands[name]
+		if !ok {
+			continue
+		}
+		if v := toolVersion(ctx, versionCmd); v != "" {
+			out = append(out, fmt.Sprintf("%s version: %s", name, v))
+		}
+	}
+	return out
+}
+
+func toolVersionExec(ctx context.Context, argv []string) string {
+	ctx, cancel := context.WithTimeout(ctx, versionTimeout)
+	defer cancel()
+
+	out, err := exec.CommandContext(ctx, argv[0], argv[1:]...).Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// tail keeps the end of what is written to it. It is safe for concurrent
+// use, since a command's stdout and stderr are copied separately.
+type tail struct {
+	mu      sync.Mutex
+	buf     []byte
+	dropped bool
+}
+
+func (t *tail) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.buf = append(t.buf, p...)
+	if over := len(t.buf) - maxOutputBytes; over > 0 {
+		t.buf = append(t.buf[:0], t.buf[over:]...)
+		t.dropped = true
+	}
+	return len(p), nil
+}
+
+// String returns the last maxOutputLines complete lines, noting when the
+// output was cut.
+func (t *tail) String() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	out := string(t.buf)
+	dropped := t.dropped
+	if dropped {
+		// The first line is most likely cut in half.
+		if _, rest, ok := strings.Cut(out, "\n"); ok {
+			out = rest
+		}
+	}
+
+	lines := strings.SplitAfter(out, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	if len(lines) > maxOutputLines {
+		lines = lines[len(lines)-maxOutputLines:]
+		dropped = true
+	}
+	out = strings.Join(lines, "")
+
+	if dropped {
+		return "[earlier output omitted]\n" + out
+	}
+	return out
+}
@@ -0,0 +1,282 @@
+package explain
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"llm/internal/providers"
+	"llm/internal/sh"
+)
+
+type stubProvider struct {
+	resp   string
+	calls  int
+	system string
+	prompt string
+}
+
+func (s *stubProvider) Complete(ctx context.Context, system, userMsg string) (providers.Response, error) {
+	s.calls++
+	s.system, s.prompt = system, userMsg
+	return providers.Response{Text: s.resp}, nil
+}
+
+// stubEnv fixes the environment and tool versions reported to the
+// provider.
+func stubEnv(t *testing.T) {
+	t.Helper()
+
+	originalEnv, originalVersion := currentEnv, toolVersion
+	t.Cleanup(func() { currentEnv, toolVersion = originalEnv, originalVersion })
+
+	currentEnv = func() sh.Env {
+		return sh.Env{OS: "linux (Ubuntu 24.04 LTS)", Shell: "/bin/sh", Dir: "/src/app"}
+	}
+	toolVersion = func(ctx context.Context, argv []string) string {
+		if argv[0] == "go" {
+			return "go1.26.0"
+		}
+		return ""
+	}
+}
+
+func TestParseConfig(t *testing.T) {
+	tests := []struct {
+		args     []string
+		wantCfg  *Config
+		wantArgs []string
+		wantErr  bool
+	}{
+		{[]string{"--", "go", "test", "-run", "X"}, &Config{}, []string{"go", "test", "-run", "X"}, false},
+		{[]string{"go", "vet", "./..."}, &Config{}, []string{"go", "vet", "./..."}, false},
+		{[]string{"--raw", "--last"}, &Config{Last: true, Raw: true}, nil, false},
+		{[]string{"-v", "go"}, nil, nil, true},
+	}
+
+	for _, tt := range tests {
+		cfg, args, err := ParseConfig(tt.args)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseConfig(%q) error = %v, wantErr %v", tt.args, err, tt.wantErr)
+			continue
+		}
+		if !reflect.DeepEqual(cfg, tt.wantCfg) || !reflect.DeepEqual(args, tt.wantArgs) {
+			t.Errorf("ParseConfig(%q) = %+v, %q, want %+v, %q", tt.args, cfg, args, tt.wantCfg, tt.wantArgs)
+		}
+	}
+}
+
+func TestRunExplainsFailure(t *testing.T) {
+	stubEnv(t)
+	provider := &stubProvider{resp: "**Cause**: the test is broken."}
+
+	var stdout, stderr bytes.Buffer
+	// Both lines go to stdout so that they arrive in order.
+	err := Run(context.Background(), provider, nil, &stdout, &stderr, []string{"--", "sh", "-c", "exec 2>&1; echo ok; echo FAIL: TestX >&2; exit 3"})
+	var exitErr *ExitError
+	if !errors.As(err, &exitErr) || exitErr.Code != 3 || err.Error() != "command exited with status 3" {
+		t.Fatalf("Run() error = %v, want exit status 3", err)
+	}
+
+	want := "Command: sh -c exec 2>&1; echo ok; echo FAIL: TestX >&2; exit 3\nExit status: 3\n" +
+		"OS: linux (Ubuntu 24.04 LTS)\nShell: /bin/sh\nWorking directory: /src/app\n" +
+		"go version: go1.26.0\n\nOutput:\n<output>\nok\nFAIL: TestX\n</output>"
+	if provider.prompt != want {
+		t.Errorf("prompt =\n%s\nwant\n%s", provider.prompt, want)
+	}
+	if provider.system != systemPrompt {
+		t.Errorf("system prompt = %q, want the embedded prompt", provider.system)
+	}
+
+	if stdout.String() != "ok\nFAIL: TestX\n**Cause**: the test is broken.\n" {
+		t.Errorf("stdout = %q, want the command output then the diagnosis", stdout.String())
+	}
+	if !strings.HasPrefix(stderr.String(), "\nThe command exited with status 3.") {
+		t.Errorf("stderr = %q", stderr.String())
+	}
+}
+
+func TestRunSucceeds(t *testing.T) {
+	stubEnv(t)
+	provider := &stubProvider{}
+
+	var stderr bytes.Buffer
+	if err := Run(context.Background(), provider, nil, &bytes.Buffer{}, &stderr, []string{"true"}); err != nil {
+		t.Fatalf("Run() error = %v, want nil", err)
+	}
+	if provider.calls != 0 {
+		t.Errorf("provider called %d times, want none for a command that succeeded", provider.calls)
+	}
+	if !strings.Contains(stderr.String(), "nothing to explain") {
+		t.Errorf("stderr = %q", stderr.String())
+	}
+}
+
+func TestRunLast(t *testing.T) {
+	stubEnv(t)
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+
+	provider := &stubProvider{resp: "Install it."}
+	args := []string{"--last"}
+	if err := Run(context.Background(), provider, nil, &bytes.Buffer{}, &bytes.Buffer{}, args); err == nil || !strings.Contains(err.Error(), "llm hook <shell>") {
+		t.Fatalf("Run() without a recorded command error = %v, want a hint to install the hook", err)
+	}
+
+	path, err := LastCommandFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("  echo missing | grep found\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var stderr bytes.Buffer
+	err = Run(context.Background(), provider, nil, &bytes.Buffer{}, &stderr, args)
+	if err == nil || err.Error() != "command exited with status 1" {
+		t.Fatalf("Run(--last) error = %v, want exit status 1", err)
+	}
+	if !strings.HasPrefix(stderr.String(), "Running: echo missing | grep found\n") {
+		t.Errorf("stderr = %q, want the command being rerun", stderr.String())
+	}
+	if !strings.HasPrefix(provider.prompt, "Command: echo missing | grep found\nExit status: 1\n") {
+		t.Errorf("prompt = %q", provider.prompt)
+	}
+
+	if err := os.WriteFile(path, []byte("llm explain --last\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LastCommand(); err == nil || !strings.Contains(err.Error(), "run the failing command first") {
+		t.Errorf("LastCommand() error = %v, want it to refuse to rerun itself", err)
+	}
+}
+
+func TestRunLastAsksFirst(t *testing.T) {
+	stubEnv(t)
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+
+	originalInteractive := interactive
+	t.Cleanup(func() { interactive = originalInteractive })
+	interactive = func(any) bool { return true }
+
+	path, err := LastCommandFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("false\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		answer string
+		want   string
+		calls  int
+	}{
+		{answer: "\n", want: "Not running it.\n", calls: 0},
+		{answer: "n\n", want: "Not running it.\n", calls: 0},
+		{answer: "", want: "Not running it.\n", calls: 0},
+		{answer: "y\n", want: "Running: false\n", calls: 1},
+	}
+
+	for _, tt := range tests {
+		provider := &stubProvider{resp: "It always fails."}
+		var stderr bytes.Buffer
+		err := Run(context.Background(), provider, strings.NewReader(tt.answer), &bytes.Buffer{}, &stderr, []string{"--last"})
+		if tt.calls == 0 && err != nil {
+			t.Errorf("Run() with answer %q error = %v, want nil", tt.answer, err)
+		}
+		if !strings.HasPrefix(stderr.String(), "Run `false` again to explain it? [y/N] ") || !strings.Contains(stderr.String(), tt.want) {
+			t.Errorf("Run() with answer %q stderr = %q, want the question and %q", tt.answer, stderr.String(), tt.want)
+		}
+		if provider.calls != tt.calls {
+			t.Errorf("Run() with answer %q made %d provider calls, want %d", tt.answer, provider.calls, tt.calls)
+		}
+	}
+}
+
+func TestRunErrors(t *testing.T) {
+	stubEnv(t)
+
+	for _, args := range [][]string{nil, {"--"}, {"--last", "go", "test"}} {
+		err := Run(context.Background(), &stubProvider{}, nil, &bytes.Buffer{}, &bytes.Buffer{}, args)
+		if err == nil || !strings.HasPrefix(err.Error(), "usage: llm explain") {
+			t.Errorf("Run(%q) error = %v, want usage", args, err)
+		}
+	}
+}
+
+func TestRunExplainsMissingCommand(t *testing.T) {
+	stubEnv(t)
+	provider := &stubProvider{resp: "Install it."}
+
+	var stderr bytes.Buffer
+	err := Run(context.Background(), provider, nil, &bytes.Buffer{}, &stderr, []string{"llm-no-such-command", "--help"})
+	var exitErr *ExitError
+	if !errors.As(err, &exitErr) || exitErr.Code != 127 {
+		t.Fatalf("Run() error = %v, want exit status 127", err)
+	}
+	if !strings.HasPrefix(stderr.String(), "llm-no-such-command: command not found\n") {
+		t.Errorf("stderr = %q, want the command reported as not found", stderr.String())
+	}
+	if !strings.Contains(provider.prompt, "Exit status: 127\n") || !strings.Contains(provider.prompt, "<output>\nllm-no-such-command: command not found\n</output>") {
+		t.Errorf("prompt = %q", provider.prompt)
+	}
+}
+
+func TestTailKeepsEndOfOutput(t *testing.T) {
+	var short tail
+	fmt.Fprint(&short, "one\ntwo")
+	if got := short.String(); got != "one\ntwo" {
+		t.Errorf("short output = %q, want it whole", got)
+	}
+
+	var long tail
+	for i := range 1000 {
+		fmt.Fprintf(&long, "line %d\n", i)
+	}
+	lines := strings.Split(strings.TrimSuffix(long.String(), "\n"), "\n")
+	if len(lines) != maxOutputLines+1 || lines[0] != "[earlier output omitted]" || lines[len(lines)-1] != "line 999" {
+		t.Errorf("long output has %d lines, first %q, last %q", len(lines), lines[0], lines[len(lines)-1])
+	}
+
+	var wide tail
+	fmt.Fprint(&wide, strings.Repeat("x", 3*maxOutputBytes)+"\nerror: the end\n")
+	if got := wide.String(); got != "[earlier output omitted]\nerror: the end\n" {
+		t.Errorf("wide output = %q, want the cut line dropped", got)
+	}
+}
+
+func TestHook(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", "/home/o'neil/state")
+
+	var stdout bytes.Buffer
+	if err := Hook(&stdout, []string{"zsh"}); err != nil {
+		t.Fatalf("Hook(zsh) error = %v", err)
+	}
+	if !strings.Contains(stdout.String(), `fc -ln -1 >| '/home/o'\''neil/state/llm/last-command'`) {
+		t.Errorf("zsh hook =\n%s", stdout.String())
+	}
+
+	stdout.Reset()
+	if err := Hook(&stdout, []string{"fish"}); err != nil {
+		t.Fatalf("Hook(fish) error = %v", err)
+	}
+	if !strings.Contains(stdout.String(), `mkdir -p '/home/o\'neil/state/llm'`) {
+		t.Errorf("fish hook =\n%s", stdout.String())
+	}
+
+	if err := Hook(&stdout, []string{"tcsh"}); err == nil || !strings.Contains(err.Error(), "available: bash, fish, zsh") {
+		t.Errorf("Hook(tcsh) error = %v, want the supported shells", err)
+	}
+}
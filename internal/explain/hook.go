@@ -0,0 +1,102 @@
+package explain
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"llm/internal/xdg"
+)
+
+// hooks record the previous command line in a file each time the shell
+// shows a prompt, so that the command running when --last is given is not
+// the one recorded. %[1]s is the directory and %[2]s the file, both quoted.
+var hooks = map[string]string{
+	"bash": `mkdir -p %[1]s
+_llm_record_last() { HISTTIMEFORMAT= builtin history 1 | sed 's/^ *[0-9]* *//' >| %[2]s; }
+PROMPT_COMMAND="_llm_record_last${PROMPT_COMMAND:+;$PROMPT_COMMAND}"
+`,
+	"zsh": `mkdir -p %[1]s
+_llm_record_last() { fc -ln -1 >| %[2]s 2>/dev/null; }
+autoload -Uz add-zsh-hook
+add-zsh-hook precmd _llm_record_last
+`,
+	"fish": `mkdir -p %[1]s
+function _llm_record_last --on-event fish_postexec
+    echo $argv[1] > %[2]s
+end
+`,
+}
+
+// LastCommandFile returns $XDG_STATE_HOME/llm/last-command, where the shell
+// hook records the previous command line.
+func LastCommandFile() (string, error) {
+	dir, err := xdg.StateHome()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "llm", "last-command"), nil
+}
+
+// LastCommand returns the previous command line recorded by the shell hook.
+func LastCommand() (string, error) {
+	path, err := LastCommandFile()
+	if err != nil {
+		return "", err
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", fmt.Errorf("no command recorded; add the hook printed by llm hook <shell> to your shell's startup file")
+	}
+	if err != nil {
+		return "", err
+	}
+
+	command := strings.TrimSpace(string(data))
+	if command == "" {
+		return "", fmt.Errorf("no command recorded in %s", path)
+	}
+	if fields := strings.Fields(command); filepath.Base(fields[0]) == "llm" && slices.Contains(fields, "explain") {
+		return "", fmt.Errorf("the previous command was %q; run the failing command first", command)
+	}
+	return command, nil
+}
+
+// Hook writes the hook for the shell named in args, to be evaluated by
+// the shell's startup file.
+func Hook(stdout io.Writer, args []string) error {
+	names := slices.Sorted(maps.Keys(hooks))
+	if len(args) != 1 {
+		return fmt.Errorf("usage: llm hook <%s>", strings.Join(names, "|"))
+	}
+
+	hook, ok := hooks[args[0]]
+	if !ok {
+		return fmt.Errorf("unsupported shell %q (available: %s)", args[0], strings.Join(names, ", "))
+	}
+
+	path, err := LastCommandFile()
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(stdout, hook, quote(args[0], filepath.Dir(path)), quote(args[0], path))
+	return err
+}
+
+// quote puts s in single quotes for shell.
+func quote(shell, s string) string {
+	if shell == "fish" {
+		// fish allows \ and \' inside single quotes.
+		s = strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(s)
+		return "'" + s + "'"
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	_ "embed"
+	"errors"
 	"fmt"
 	"io"
 	"regexp"
@@ -48,14 +49,17 @@ func BuildPrompt(diff, branch, base string) string {
 
 func GeneratePullRequest(ctx context.Context, provider providers.Provider, prompt string, stderr io.Writer) (*PullRequest, error) {
 	ind := loading.Start(stderr)
-	raw, err := provider.Complete(ctx, systemPrompt, prompt)
+	resp, err := provider.Complete(ctx, systemPrompt, prompt)
 	ind.Stop()
 
+	if errors.Is(err, providers.ErrTruncated) {
+		return nil, fmt.Errorf("pull request description was cut off: %w", err)
+	}
 	if err != nil {
 		return nil, err
 	}
 
-	pr, err := parsePullRequest(raw)
+	pr, err := parsePullRequest(resp.Text)
 	if err != nil {
 		return nil, fmt.Errorf("parsing generated pull request content: %w", err)
 	}
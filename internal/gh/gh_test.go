@@ -6,6 +6,8 @@ import (
 	"errors"
 	"strings"
 	"testing"
+
+	"llm/internal/providers"
 )
 
 type stubProvider struct {
@@ -13,8 +15,8 @@ type stubProvider struct {
 	err  error
 }
 
-func (s *stubProvider) Complete(ctx context.Context, system, userMsg string) (string, error) {
-	return s.resp, s.err
+func (s *stubProvider) Complete(ctx context.Context, system, userMsg string) (providers.Response, error) {
+	return providers.Response{Text: s.resp}, s.err
 }
 
 type stubGitClient struct {
@@ -98,6 +100,12 @@ func TestGeneratePullRequest(t *testing.T) {
 			resp:    "just text",
 			wantErr: true,
 		},
+		{
+			name:    "truncated response",
+			resp:    "<title>Add GH PR generation</title>\n<body>## Summary\n- Add PR",
+			err:     &providers.TruncatedError{},
+			wantErr: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -107,6 +115,10 @@ func TestGeneratePullRequest(t *testing.T) {
 
 			got, err := GeneratePullRequest(context.Background(), provider, "prompt", &stderr)
 
+			if errors.Is(tt.err, providers.ErrTruncated) && (!errors.Is(err, providers.ErrTruncated) || !strings.Contains(err.Error(), "cut off")) {
+				t.Errorf("GeneratePullRequest() error = %v, want truncation error", err)
+			}
+
 			if (err != nil) != tt.wantErr {
 				t.Errorf("GeneratePullRequest() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -0,0 +1,57 @@
+// Package interrupt lets interactive commands handle Ctrl+C themselves.
+// While a scope is open an interrupt cancels only the work done under it,
+// such as one answer in a chat, instead of the whole program.
+package interrupt
+
+import (
+	"context"
+	"sync"
+)
+
+var (
+	mu     sync.Mutex
+	scopes []*scope
+)
+
+type scope struct {
+	cancel context.CancelFunc
+}
+
+// Scope returns a copy of ctx that Interrupt cancels and a function that
+// closes the scope and releases its resources. Scopes nest; an interrupt
+// cancels the innermost one.
+func Scope(ctx context.Context) (context.Context, func()) {
+	ctx, cancel := context.WithCancel(ctx)
+	s := &scope{cancel: cancel}
+
+	mu.Lock()
+	scopes = append(scopes, s)
+	mu.Unlock()
+
+	return ctx, func() {
+		mu.Lock()
+		for i, open := range scopes {
+			if open == s {
+				scopes = append(scopes[:i], scopes[i+1:]...)
+				break
+			}
+		}
+		mu.Unlock()
+		cancel()
+	}
+}
+
+// Interrupt cancels the innermost open scope and reports whether there was
+// one. When it returns false the interrupt should stop the program.
+func Interrupt() bool {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if len(scopes) == 0 {
+		return false
+	}
+	s := scopes[len(scopes)-1]
+	scopes = scopes[:len(scopes)-1]
+	s.cancel()
+	return true
+}
@@ -0,0 +1,39 @@
+package interrupt
+
+import (
+	"context"
+	"testing"
+)
+
+func TestInterruptCancelsInnermostScope(t *testing.T) {
+	outer, closeOuter := Scope(context.Background())
+	defer closeOuter()
+	inner, closeInner := Scope(outer)
+	defer closeInner()
+
+	if !Interrupt() {
+		t.Fatal("Interrupt() = false, want true with open scopes")
+	}
+	if inner.Err() == nil || outer.Err() != nil {
+		t.Errorf("after one Interrupt() inner = %v, outer = %v, want only inner cancelled", inner.Err(), outer.Err())
+	}
+
+	if !Interrupt() || outer.Err() == nil {
+		t.Errorf("second Interrupt() did not cancel the outer scope")
+	}
+	if Interrupt() {
+		t.Error("Interrupt() = true, want false once every scope is cancelled")
+	}
+}
+
+func TestClosedScopesAreNotInterrupted(t *testing.T) {
+	ctx, closeScope := Scope(context.Background())
+	closeScope()
+
+	if Interrupt() {
+		t.Error("Interrupt() = true, want false after the scope was closed")
+	}
+	if ctx.Err() == nil {
+		t.Error("closing a scope did not cancel its context")
+	}
+}
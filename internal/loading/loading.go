@@ -3,6 +3,7 @@ package loading
 import (
 	"fmt"
 	"io"
+	"sync"
 	"time"
 )
 
@@ -10,6 +11,7 @@ import (
 // until Stop is called. It is safe for concurrent use.
 type Indicator struct {
 	done chan struct{}
+	once sync.Once
 	w    io.Writer
 }
 
@@ -42,7 +44,10 @@ func Start(w io.Writer) *Indicator {
 }
 
 // Stop ends the loading indicator and prints a trailing newline.
+// Calling Stop more than once has no further effect.
 func (ind *Indicator) Stop() {
-	close(ind.done)
-	_, _ = fmt.Fprintln(ind.w)
+	ind.once.Do(func() {
+		close(ind.done)
+		_, _ = fmt.Fprintln(ind.w)
+	})
 }
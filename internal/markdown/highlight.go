@@ -0,0 +1,146 @@
+package markdown
+
+import "strings"
+
+// syntax describes enough of a language to colour its keywords, strings,
+// numbers and line comments.
+type syntax struct {
+	keywords map[string]bool
+	comment  string
+	quotes   string
+}
+
+func words(s string) map[string]bool {
+	m := make(map[string]bool)
+	for _, w := range strings.Fields(s) {
+		m[w] = true
+	}
+	return m
+}
+
+var (
+	goSyntax = &syntax{
+		keywords: words(`break case chan const continue default defer else fallthrough for func go goto if
+			import interface map package range return select struct switch type var
+			nil true false iota`),
+		comment: "//",
+		quotes:  "\"'`",
+	}
+	pythonSyntax = &syntax{
+		keywords: words(`and as assert async await break class continue def del elif else except finally
+			for from global if import in is lambda nonlocal not or pass raise return try while with yield
+			None True False self`),
+		comment: "#",
+		quotes:  `"'`,
+	}
+	jsSyntax = &syntax{
+		keywords: words(`async await break case catch class const continue debugger default delete do
+			else export extends finally for function if import in instanceof let new of return static
+			super switch this throw try typeof var void while yield null undefined true false
+			interface type enum implements private public readonly`),
+		comment: "//",
+		quotes:  "\"'`",
+	}
+	shellSyntax = &syntax{
+		keywords: words(`if then else elif fi for while until do done case esac in function return
+			local export readonly set unset shift exit source alias cd echo sudo`),
+		comment: "#",
+		quotes:  `"'`,
+	}
+	rustSyntax = &syntax{
+		keywords: words(`as async await break const continue crate dyn else enum extern fn for if impl
+			in let loop match mod move mut pub ref return self Self static struct super trait type
+			unsafe use where while true false Some None Ok Err`),
+		comment: "//",
+		quotes:  `"`,
+	}
+	cSyntax = &syntax{
+		keywords: words(`auto break case catch char class const continue default delete do double else
+			enum extends final float for if implements import int long new nullptr null package private
+			protected public return short signed sizeof static struct switch template this throw try
+			typedef union unsigned using virtual void volatile while true false bool boolean include define`),
+		comment: "//",
+		quotes:  `"'`,
+	}
+)
+
+// syntaxes maps the info strings of fenced code blocks to a syntax.
+var syntaxes = map[string]*syntax{
+	"go":         goSyntax,
+	"golang":     goSyntax,
+	"python":     pythonSyntax,
+	"py":         pythonSyntax,
+	"javascript": jsSyntax,
+	"js":         jsSyntax,
+	"typescript": jsSyntax,
+	"ts":         jsSyntax,
+	"sh":         shellSyntax,
+	"bash":       shellSyntax,
+	"shell":      shellSyntax,
+	"zsh":        shellSyntax,
+	"console":    shellSyntax,
+	"rust":       rustSyntax,
+	"rs":         rustSyntax,
+	"c":          cSyntax,
+	"cpp":        cSyntax,
+	"c++":        cSyntax,
+	"java":       cSyntax,
+}
+
+// highlight colours one line of code written in lang. Lines in languages
+// it does not know are returned as they are.
+func highlight(line, lang string) string {
+	syn := syntaxes[lang]
+	if syn == nil {
+		return line
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(line); {
+		rest := line[i:]
+		c := line[i]
+		switch {
+		case strings.HasPrefix(rest, syn.comment) && (syn.comment != "#" || i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+			b.WriteString(gray + rest + reset)
+			return b.String()
+		case strings.IndexByte(syn.quotes, c) >= 0:
+			n := stringLen(rest)
+			b.WriteString(green + rest[:n] + reset)
+			i += n
+		case isWord(c):
+			n := 1
+			for n < len(rest) && isWord(rest[n]) {
+				n++
+			}
+			switch word := rest[:n]; {
+			case syn.keywords[word]:
+				b.WriteString(magenta + word + reset)
+			case c >= '0' && c <= '9':
+				b.WriteString(yellow + word + reset)
+			default:
+				b.WriteString(word)
+			}
+			i += n
+		default:
+			b.WriteByte(c)
+			i++
+		}
+	}
+	return b.String()
+}
+
+// stringLen returns the length of the string literal at the start of s,
+// which runs to the end of the line if it is not closed.
+func stringLen(s string) int {
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			if s[0] != '`' {
+				i++
+			}
+		case s[0]:
+			return i + 1
+		}
+	}
+	return len(s)
+}
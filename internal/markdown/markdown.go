@@ -0,0 +1,214 @@
+// Package markdown renders Markdown for a terminal with ANSI styling. It
+// works a line at a time, so that answers can be rendered while they are
+// streamed.
+package markdown
+
+import (
+	"bytes"
+	"io"
+	"regexp"
+	"strings"
+)
+
+const (
+	reset     = "\x1b[0m"
+	bold      = "\x1b[1m"
+	faint     = "\x1b[2m"
+	italic    = "\x1b[3m"
+	underline = "\x1b[4m"
+	green     = "\x1b[32m"
+	yellow    = "\x1b[33m"
+	blue      = "\x1b[34m"
+	magenta   = "\x1b[35m"
+	cyan      = "\x1b[36m"
+	gray      = "\x1b[90m"
+)
+
+var (
+	heading     = regexp.MustCompile(`^(#{1,6})\s+(.*?)\s*#*\s*$`)
+	rule        = regexp.MustCompile(`^\s*([-*_])(\s*([-*_])){2,}\s*$`)
+	bullet      = regexp.MustCompile(`^(\s*)[-*+]\s+(.*)$`)
+	numbered    = regexp.MustCompile(`^(\s*)(\d{1,9}[.)])\s+(.*)$`)
+	quote       = regexp.MustCompile(`^\s*>\s?(.*)$`)
+	fenceOpener = regexp.MustCompile("^\\s*(```+|~~~+)\\s*([^`\\s]*)")
+)
+
+// Renderer styles the Markdown written to it and writes the result to the
+// underlying writer. Complete lines are written as they arrive; call Flush
+// to write the rest.
+type Renderer struct {
+	w       io.Writer
+	pending []byte
+	// fence is the marker that opened the code block being written, or
+	// empty outside code blocks.
+	fence string
+	lang  string
+}
+
+// NewRenderer returns a renderer that writes to w.
+func NewRenderer(w io.Writer) *Renderer {
+	return &Renderer{w: w}
+}
+
+// Write renders every complete line in p and keeps the rest until the
+// line is completed or Flush is called.
+func (r *Renderer) Write(p []byte) (int, error) {
+	r.pending = append(r.pending, p...)
+	for {
+		i := bytes.IndexByte(r.pending, '\n')
+		if i < 0 {
+			return len(p), nil
+		}
+		line := string(r.pending[:i])
+		r.pending = r.pending[i+1:]
+		if _, err := io.WriteString(r.w, r.render(line)+"\n"); err != nil {
+			return len(p), err
+		}
+	}
+}
+
+// Flush renders the last line if it has no newline yet.
+func (r *Renderer) Flush() error {
+	if len(r.pending) == 0 {
+		return nil
+	}
+	line := string(r.pending)
+	r.pending = nil
+	_, err := io.WriteString(r.w, r.render(line))
+	return err
+}
+
+// render styles one line, keeping track of code blocks.
+func (r *Renderer) render(line string) string {
+	line = strings.TrimSuffix(line, "\r")
+
+	if r.fence != "" {
+		if t := strings.TrimSpace(line); strings.HasPrefix(t, r.fence) && strings.Trim(t, r.fence[:1]) == "" {
+			r.fence, r.lang = "", ""
+			return faint + line + reset
+		}
+		return highlight(line, r.lang)
+	}
+
+	if m := fenceOpener.FindStringSubmatch(line); m != nil {
+		r.fence, r.lang = m[1], strings.ToLower(m[2])
+		return faint + line + reset
+	}
+
+	if m := heading.FindStringSubmatch(line); m != nil {
+		style := bold + cyan
+		if len(m[1]) == 1 {
+			style += underline
+		}
+		return style + inline(m[2], style) + reset
+	}
+	if rule.MatchString(line) {
+		return faint + strings.Repeat("─", 40) + reset
+	}
+	if m := quote.FindStringSubmatch(line); m != nil {
+		return gray + "│ " + reset + italic + inline(m[1], italic) + reset
+	}
+	if m := bullet.FindStringSubmatch(line); m != nil {
+		return m[1] + yellow + "•" + reset + " " + inline(m[2], "")
+	}
+	if m := numbered.FindStringSubmatch(line); m != nil {
+		return m[1] + yellow + m[2] + reset + " " + inline(m[3], "")
+	}
+	return inline(line, "")
+}
+
+// inline styles code spans, emphasis and links in s. base is the style s
+// is written in, which is restored after each span.
+func inline(s, base string) string {
+	var b strings.Builder
+	span := func(style, text string) {
+		b.WriteString(reset + style + text + reset + base)
+	}
+
+	for i := 0; i < len(s); {
+		rest := s[i:]
+		switch {
+		case rest[0] == '`':
+			ticks := rest[:len(rest)-len(strings.TrimLeft(rest, "`"))]
+			if end := strings.Index(rest[len(ticks):], ticks); end >= 0 {
+				span(green, rest[len(ticks):len(ticks)+end])
+				i += 2*len(ticks) + end
+				continue
+			}
+			b.WriteString(ticks)
+			i += len(ticks)
+			continue
+		case strings.HasPrefix(rest, "**") || strings.HasPrefix(rest, "__"):
+			if end := closing(s, i, rest[:2]); end > 0 {
+				span(bold, inline(s[i+2:end], bold))
+				i = end + 2
+				continue
+			}
+		case rest[0] == '*' || rest[0] == '_':
+			if end := closing(s, i, rest[:1]); end > 0 {
+				span(italic, inline(s[i+1:end], italic))
+				i = end + 1
+				continue
+			}
+		case rest[0] == '[':
+			if text, url, n, ok := link(rest); ok {
+				span(underline+blue, text)
+				if url != text {
+					span(gray, " ("+url+")")
+				}
+				i += n
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+		i++
+	}
+	return b.String()
+}
+
+// closing returns the index of the delimiter that closes the emphasis
+// opened at s[i], or -1. Emphasis must hug its text, and underscores only
+// count at word boundaries so that snake_case names are left alone.
+func closing(s string, i int, delim string) int {
+	start := i + len(delim)
+	if start >= len(s) || s[start] == ' ' {
+		return -1
+	}
+	if delim[0] == '_' && i > 0 && isWord(s[i-1]) {
+		return -1
+	}
+
+	for j := start + 1; j+len(delim) <= len(s); j++ {
+		if s[j:j+len(delim)] != delim || s[j-1] == ' ' {
+			continue
+		}
+		after := j + len(delim)
+		if delim[0] == '_' && after < len(s) && isWord(s[after]) {
+			continue
+		}
+		// A single * or _ must not be half of a double one.
+		if len(delim) == 1 && after < len(s) && s[after] == delim[0] {
+			j++
+			continue
+		}
+		return j
+	}
+	return -1
+}
+
+// link parses a [text](url) link at the start of s and returns its length.
+func link(s string) (text, url string, n int, ok bool) {
+	end := strings.Index(s, "](")
+	if end < 0 || strings.ContainsAny(s[1:end], "[]") {
+		return "", "", 0, false
+	}
+	paren := strings.IndexByte(s[end+2:], ')')
+	if paren < 0 {
+		return "", "", 0, false
+	}
+	return s[1:end], s[end+2 : end+2+paren], end + 3 + paren, true
+}
+
+func isWord(c byte) bool {
+	return c == '_' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
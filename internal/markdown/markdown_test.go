@@ -0,0 +1,87 @@
+package markdown
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func render(t *testing.T, chunks ...string) string {
+	t.Helper()
+
+	var out bytes.Buffer
+	r := NewRenderer(&out)
+	for _, chunk := range chunks {
+		if _, err := r.Write([]byte(chunk)); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+	}
+	if err := r.Flush(); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+	return out.String()
+}
+
+func TestRenderLines(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"plain text", "Just text, with snake_case_names and 2 * 3 * 4.", "Just text, with snake_case_names and 2 * 3 * 4."},
+		{"heading", "## Install it ##", bold + cyan + "Install it" + reset},
+		{"title", "# Title", bold + cyan + underline + "Title" + reset},
+		{"bullet", "  - first", "  " + yellow + "•" + reset + " first"},
+		{"numbered", "2. second", yellow + "2." + reset + " second"},
+		{"quote", "> note", gray + "│ " + reset + italic + "note" + reset},
+		{"rule", "* * *", faint + strings.Repeat("─", 40) + reset},
+		{"bold", "a **b** c", "a " + reset + bold + "b" + reset + " c"},
+		{"italic", "a _b_ c", "a " + reset + italic + "b" + reset + " c"},
+		{"code span", "run `go test ./...`", "run " + reset + green + "go test ./..." + reset},
+		{"code span hides emphasis", "`*x*`", reset + green + "*x*" + reset},
+		{"link", "[Go](https://go.dev)", reset + underline + blue + "Go" + reset + reset + gray + " (https://go.dev)" + reset},
+		{"bold in heading", "# A **b**", bold + cyan + underline + "A " + reset + bold + "b" + reset + bold + cyan + underline + reset},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := render(t, tt.in); got != tt.want {
+				t.Errorf("render(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderCodeBlocks(t *testing.T) {
+	got := render(t, "```go\n", "x := \"# not\" // note\n", "# not a heading\n", "```\n")
+
+	want := faint + "```go" + reset + "\n" +
+		"x := " + green + `"# not"` + reset + " " + gray + "// note" + reset + "\n" +
+		"# not a heading\n" +
+		faint + "```" + reset + "\n"
+	if got != want {
+		t.Errorf("render() =\n%q\nwant\n%q", got, want)
+	}
+
+	if got := render(t, "~~~\nfunc *x*\n~~~"); !strings.Contains(got, "\nfunc *x*\n") {
+		t.Errorf("code in an unknown language = %q, want it unchanged", got)
+	}
+}
+
+func TestRenderStreamedChunks(t *testing.T) {
+	in := "# Title\n\nSome **bold** text.\n```sh\nrm -rf build # clean\n```\nDone"
+	whole := render(t, in)
+
+	var chunks []string
+	for len(in) > 0 {
+		n := min(3, len(in))
+		chunks, in = append(chunks, in[:n]), in[n:]
+	}
+	if got := render(t, chunks...); got != whole {
+		t.Errorf("streamed render =\n%q\nwant\n%q", got, whole)
+	}
+
+	if !strings.HasSuffix(whole, "\nDone") {
+		t.Errorf("render() = %q, want the last line without a newline", whole)
+	}
+}
@@ -1,21 +1,52 @@
 package providers
 
 import (
+	"cmp"
 	"context"
+	"encoding/json"
 	"fmt"
+	"strings"
 )
 
 type anthropicRequest struct {
-	Model     string    `json:"model"`
-	MaxTokens int       `json:"max_tokens"`
-	System    string    `json:"system,omitempty"`
-	Messages  []Message `json:"messages"`
+	Model       string    `json:"model"`
+	MaxTokens   int       `json:"max_tokens"`
+	System      string    `json:"system,omitempty"`
+	Messages    []Message `json:"messages"`
+	Temperature *float64  `json:"temperature,omitempty"`
+	Stream      bool      `json:"stream,omitempty"`
+}
+
+type anthropicUsage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
 }
 
 type anthropicResponse struct {
+	Model   string `json:"model"`
 	Content []struct {
+		Type string `json:"type"`
 		Text string `json:"text"`
 	} `json:"content"`
+	StopReason string          `json:"stop_reason"`
+	Usage      *anthropicUsage `json:"usage,omitempty"`
+	Error      *struct {
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+}
+
+type anthropicStreamEvent struct {
+	Type    string `json:"type"`
+	Message struct {
+		Model string          `json:"model"`
+		Usage *anthropicUsage `json:"usage,omitempty"`
+	} `json:"message"`
+	Delta struct {
+		Type       string `json:"type"`
+		Text       string `json:"text"`
+		StopReason string `json:"stop_reason"`
+	} `json:"delta"`
+	Usage *anthropicUsage `json:"usage,omitempty"`
 	Error *struct {
 		Message string `json:"message"`
 	} `json:"error,omitempty"`
@@ -25,35 +56,147 @@ type AnthropicProvider struct {
 	endpoint string
 	model    string
 	apiKey   string
+	options
 }
 
-func NewAnthropicProvider(endpoint, model, apiKey string) Provider {
+func NewAnthropicProvider(endpoint, model, apiKey string, opts ...Option) Provider {
 	return &AnthropicProvider{
 		endpoint: endpoint,
 		model:    model,
 		apiKey:   apiKey,
+		options:  newOptions(opts),
 	}
 }
 
-func (a *AnthropicProvider) Complete(ctx context.Context, system, userMsg string) (string, error) {
+func (a *AnthropicProvider) Complete(ctx context.Context, system, userMsg string) (Response, error) {
+	return a.Chat(ctx, system, userTurn(userMsg))
+}
+
+func (a *AnthropicProvider) Chat(ctx context.Context, system string, msgs []Message) (Response, error) {
+	return withContinuations(a.options, msgs, func(msgs []Message) (Response, error) {
+		return completeAnthropic(ctx, a.endpoint, anthropicRequest{
+			Model:       a.model,
+			MaxTokens:   a.maxTokensOrDefault(),
+			System:      system,
+			Messages:    msgs,
+			Temperature: a.temperature,
+		}, map[string]string{
+			"x-api-key":         a.apiKey,
+			"anthropic-version": "2023-06-01",
+		}, a.options, a.describe())
+	})
+}
+
+func (a *AnthropicProvider) Stream(ctx context.Context, system, userMsg string, onDelta func(string) error) (Response, error) {
+	return a.StreamChat(ctx, system, userTurn(userMsg), onDelta)
+}
+
+func (a *AnthropicProvider) StreamChat(ctx context.Context, system string, msgs []Message, onDelta func(string) error) (Response, error) {
+	return withContinuations(a.options, msgs, func(msgs []Message) (Response, error) {
+		return streamAnthropic(ctx, a.endpoint, anthropicRequest{
+			Model:       a.model,
+			MaxTokens:   a.maxTokensOrDefault(),
+			System:      system,
+			Messages:    msgs,
+			Temperature: a.temperature,
+			Stream:      true,
+		}, map[string]string{
+			"x-api-key":         a.apiKey,
+			"anthropic-version": "2023-06-01",
+		}, a.options, a.describe(), onDelta)
+	})
+}
+
+func (a *AnthropicProvider) describe() Usage {
+	return Usage{Provider: "anthropic", Model: a.model}
+}
+
+func (a *AnthropicProvider) cacheScope() string {
+	return a.options.cacheScope(a.endpoint, a.model)
+}
+
+// completeAnthropic sends a messages request and reads the text content
+// blocks and the reported usage. A message that stopped at the token limit
+// is returned with a *TruncatedError. It is shared by every provider that
+// speaks the Anthropic wire format.
+func completeAnthropic(ctx context.Context, endpoint string, req any, headers map[string]string, o options, usage Usage) (Response, error) {
 	var r anthropicResponse
-	if err := doJSONRequest(ctx, a.endpoint, anthropicRequest{
-		Model:     a.model,
-		MaxTokens: 4096,
-		System:    system,
-		Messages:  []Message{{Role: "user", Content: userMsg}},
-	}, &r, map[string]string{
-		"x-api-key":         a.apiKey,
-		"anthropic-version": "2023-06-01",
-	}); err != nil {
-		return "", err
+	if err := doJSONRequest(ctx, endpoint, req, &r, headers, o); err != nil {
+		return Response{}, err
 	}
 
 	if r.Error != nil {
-		return "", fmt.Errorf("%s", r.Error.Message)
+		return Response{}, fmt.Errorf("%s", r.Error.Message)
+	}
+
+	var text strings.Builder
+	for _, block := range r.Content {
+		if block.Type == "text" {
+			text.WriteString(block.Text)
+		}
 	}
-	if len(r.Content) > 0 {
-		return r.Content[0].Text, nil
+
+	resp := Response{Text: text.String(), Usage: usage.withAnthropic(r.Model, r.Usage)}
+	if r.StopReason == "max_tokens" {
+		return resp, &TruncatedError{Response: resp}
+	}
+	return resp, nil
+}
+
+// streamAnthropic sends a messages request with streaming enabled and
+// collects the text deltas. It is shared by every provider that speaks
+// the Anthropic wire format.
+func streamAnthropic(ctx context.Context, endpoint string, req any, headers map[string]string, o options, usage Usage, onDelta func(string) error) (Response, error) {
+	var text strings.Builder
+	truncated := false
+	err := doStreamRequest(ctx, endpoint, req, headers, o, func(data []byte) error {
+		var event anthropicStreamEvent
+		if err := json.Unmarshal(data, &event); err != nil {
+			return fmt.Errorf("failed to parse stream event: %w", err)
+		}
+
+		switch event.Type {
+		case "error":
+			if event.Error != nil {
+				return fmt.Errorf("%s", event.Error.Message)
+			}
+			return fmt.Errorf("stream error")
+		case "message_start":
+			usage = usage.withAnthropic(event.Message.Model, event.Message.Usage)
+		case "message_delta":
+			// Only the output token count is cumulative here.
+			if event.Usage != nil {
+				usage.OutputTokens = event.Usage.OutputTokens
+			}
+			if event.Delta.StopReason == "max_tokens" {
+				truncated = true
+			}
+		case "content_block_delta":
+			if event.Delta.Type != "text_delta" || event.Delta.Text == "" {
+				return nil
+			}
+			text.WriteString(event.Delta.Text)
+			return onDelta(event.Delta.Text)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return Response{}, err
+	}
+
+	resp := Response{Text: text.String(), Usage: usage}
+	if truncated {
+		return resp, &TruncatedError{Response: resp}
+	}
+	return resp, nil
+}
+
+func (u Usage) withAnthropic(model string, usage *anthropicUsage) Usage {
+	u.Model = cmp.Or(model, u.Model)
+	if usage != nil {
+		u.InputTokens = usage.InputTokens
+		u.OutputTokens = usage.OutputTokens
 	}
-	return "", nil
+	return u
 }
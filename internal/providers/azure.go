@@ -0,0 +1,95 @@
+package providers
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// azureAPIVersion is the Azure OpenAI REST API version used when
+// AZURE_OPENAI_API_VERSION is not set.
+const azureAPIVersion = "2024-10-21"
+
+// AzureOpenAIProvider talks to an Azure OpenAI resource. Requests go to a
+// deployment rather than a model and authenticate with an api-key header.
+type AzureOpenAIProvider struct {
+	endpoint   string
+	deployment string
+	apiVersion string
+	apiKey     string
+	options
+}
+
+// NewAzureOpenAIProvider returns a provider for the given resource endpoint
+// (e.g. https://my-resource.openai.azure.com) and deployment name.
+func NewAzureOpenAIProvider(endpoint, deployment, apiVersion, apiKey string, opts ...Option) Provider {
+	return &AzureOpenAIProvider{
+		endpoint:   endpoint,
+		deployment: deployment,
+		apiVersion: apiVersion,
+		apiKey:     apiKey,
+		options:    newOptions(opts),
+	}
+}
+
+// newAzureOpenAIProviderFromEnv adapts NewAzureOpenAIProvider to the backend
+// table, taking the API version from AZURE_OPENAI_API_VERSION.
+func newAzureOpenAIProviderFromEnv(endpoint, deployment, apiKey string, opts ...Option) Provider {
+	apiVersion := cmp.Or(os.Getenv("AZURE_OPENAI_API_VERSION"), azureAPIVersion)
+	return NewAzureOpenAIProvider(endpoint, deployment, apiVersion, apiKey, opts...)
+}
+
+func (a *AzureOpenAIProvider) Complete(ctx context.Context, system, userMsg string) (Response, error) {
+	return a.Chat(ctx, system, userTurn(userMsg))
+}
+
+func (a *AzureOpenAIProvider) Chat(ctx context.Context, system string, msgs []Message) (Response, error) {
+	return withContinuations(a.options, msgs, func(msgs []Message) (Response, error) {
+		return completeOpenAI(ctx, a.url(), openaiRequest{
+			Model:       a.deployment,
+			Messages:    buildMessages(system, msgs),
+			MaxTokens:   a.maxTokens,
+			Temperature: a.temperature,
+		}, map[string]string{
+			"api-key": a.apiKey,
+		}, a.options, a.describe())
+	})
+}
+
+func (a *AzureOpenAIProvider) Stream(ctx context.Context, system, userMsg string, onDelta func(string) error) (Response, error) {
+	return a.StreamChat(ctx, system, userTurn(userMsg), onDelta)
+}
+
+func (a *AzureOpenAIProvider) StreamChat(ctx context.Context, system string, msgs []Message, onDelta func(string) error) (Response, error) {
+	return withContinuations(a.options, msgs, func(msgs []Message) (Response, error) {
+		return streamOpenAI(ctx, a.url(), openaiRequest{
+			Model:         a.deployment,
+			Messages:      buildMessages(system, msgs),
+			MaxTokens:     a.maxTokens,
+			Temperature:   a.temperature,
+			Stream:        true,
+			StreamOptions: &openaiStreamOptions{IncludeUsage: true},
+		}, map[string]string{
+			"api-key": a.apiKey,
+		}, a.options, a.describe(), onDelta)
+	})
+}
+
+func (a *AzureOpenAIProvider) describe() Usage {
+	return Usage{Provider: "azure", Model: a.deployment}
+}
+
+func (a *AzureOpenAIProvider) cacheScope() string {
+	return a.options.cacheScope(a.url(), a.deployment)
+}
+
+func (a *AzureOpenAIProvider) url() string {
+	return fmt.Sprintf("%s/openai/deployments/%s/chat/completions?api-version=%s",
+		strings.TrimSuffix(a.endpoint, "/"),
+		url.PathEscape(a.deployment),
+		url.QueryEscape(a.apiVersion),
+	)
+}
@@ -0,0 +1,62 @@
+package providers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAzureOpenAIProviderComplete(t *testing.T) {
+	var gotPath, gotVersion, gotKey, gotAuth string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		gotVersion = r.URL.Query().Get("api-version")
+		gotKey = r.Header.Get("api-key")
+		gotAuth = r.Header.Get("Authorization")
+
+		_, _ = w.Write([]byte(`{"choices":[{"message":{"content":"hello from azure"}}]}`))
+	}))
+	t.Cleanup(server.Close)
+
+	p := NewAzureOpenAIProvider(server.URL+"/", "gpt-4o-prod", "2024-10-21", "secret")
+	resp, err := p.Complete(context.Background(), "", "hi")
+	if err != nil {
+		t.Fatalf("Complete() error = %v, want nil", err)
+	}
+
+	if resp.Text != "hello from azure" {
+		t.Errorf("Complete() = %q, want %q", resp.Text, "hello from azure")
+	}
+
+	if gotPath != "/openai/deployments/gpt-4o-prod/chat/completions" {
+		t.Errorf("request path = %q, want deployment chat/completions path", gotPath)
+	}
+
+	if gotVersion != "2024-10-21" {
+		t.Errorf("api-version = %q, want %q", gotVersion, "2024-10-21")
+	}
+
+	if gotKey != "secret" || gotAuth != "" {
+		t.Errorf("headers api-key = %q, Authorization = %q, want api-key only", gotKey, gotAuth)
+	}
+}
+
+func TestAzureOpenAIProviderStream(t *testing.T) {
+	server := newStreamServer(t, []string{
+		`{"choices":[]}`,
+		`{"choices":[{"delta":{"content":"hello"}}]}`,
+		`[DONE]`,
+	})
+
+	p := NewAzureOpenAIProvider(server.URL, "gpt-4o-prod", "2024-10-21", "secret")
+	resp, err := Stream(context.Background(), p, "", "hi", func(string) error { return nil })
+	if err != nil {
+		t.Fatalf("Stream() error = %v, want nil", err)
+	}
+
+	if resp.Text != "hello" {
+		t.Errorf("Stream() = %q, want %q", resp.Text, "hello")
+	}
+}
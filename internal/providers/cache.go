@@ -0,0 +1,331 @@
+package providers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"llm/internal/xdg"
+)
+
+const (
+	// DefaultCacheTTL is how long cached responses are reused.
+	DefaultCacheTTL = 24 * time.Hour
+	// DefaultCacheMaxSize bounds the total size of cached responses.
+	DefaultCacheMaxSize = 50 << 20
+)
+
+// cacheable is implemented by providers whose responses are determined by
+// their scope and the prompt, so that they can be cached.
+type cacheable interface {
+	cacheScope() string
+}
+
+// ResponseCache stores completions on disk, one file per prompt, so that
+// repeating a request does not pay for it again. Entries expire after a
+// TTL and the oldest are evicted once the cache grows past its size limit.
+type ResponseCache struct {
+	dir     string
+	ttl     time.Duration
+	maxSize int64
+	now     func() time.Time
+}
+
+type cacheEntry struct {
+	Created  time.Time `json:"created"`
+	Text     string    `json:"text"`
+	Provider string    `json:"provider,omitempty"`
+	Model    string    `json:"model,omitempty"`
+}
+
+// NewResponseCache returns a cache stored in dir. Zero ttl or maxSize use
+// DefaultCacheTTL and DefaultCacheMaxSize.
+func NewResponseCache(dir string, ttl time.Duration, maxSize int64) *ResponseCache {
+	if ttl <= 0 {
+		ttl = DefaultCacheTTL
+	}
+	if maxSize <= 0 {
+		maxSize = DefaultCacheMaxSize
+	}
+
+	return &ResponseCache{
+		dir:     dir,
+		ttl:     ttl,
+		maxSize: maxSize,
+		now:     time.Now,
+	}
+}
+
+// DefaultCacheDir returns $XDG_CACHE_HOME/llm/responses.
+func DefaultCacheDir() (string, error) {
+	dir, err := xdg.CacheHome()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "llm", "responses"), nil
+}
+
+// Wrap returns a provider that answers from the cache when it can and
+// stores new responses from p. Providers that cannot identify their
+// endpoint and model are returned unchanged.
+func (c *ResponseCache) Wrap(p Provider) Provider {
+	scoped, ok := p.(cacheable)
+	if !ok || scoped.cacheScope() == "" {
+		return p
+	}
+	return &CachedProvider{provider: p, cache: c, scoped: scoped}
+}
+
+// Clear removes every cached response and returns how many there were.
+func (c *ResponseCache) Clear() (int, error) {
+	entries, err := c.entries()
+	if err != nil {
+		return 0, err
+	}
+
+	for _, e := range entries {
+		if err := os.Remove(e.path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return 0, err
+		}
+	}
+	return len(entries), nil
+}
+
+func (c *ResponseCache) get(key string) (cacheEntry, bool) {
+	data, err := os.ReadFile(c.path(key))
+	if err != nil {
+		return cacheEntry{}, false
+	}
+
+	var e cacheEntry
+	if err := json.Unmarshal(data, &e); err != nil {
+		return cacheEntry{}, false
+	}
+
+	if c.now().Sub(e.Created) > c.ttl {
+		_ = os.Remove(c.path(key))
+		return cacheEntry{}, false
+	}
+	return e, true
+}
+
+// put stores e under key and then evicts expired entries and, if the
+// cache is still too large, the oldest ones. Errors are ignored since a
+// failed write only costs a future cache miss.
+func (c *ResponseCache) put(key string, e cacheEntry) {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+
+	if err := os.MkdirAll(c.dir, 0o700); err != nil {
+		return
+	}
+
+	// Write to a temporary file first so that readers never see a
+	// partial entry.
+	tmp, err := os.CreateTemp(c.dir, key+".*.tmp")
+	if err != nil {
+		return
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil || os.Rename(tmp.Name(), c.path(key)) != nil {
+		_ = os.Remove(tmp.Name())
+		return
+	}
+
+	c.evict()
+}
+
+type cacheFile struct {
+	path    string
+	size    int64
+	modTime time.Time
+}
+
+func (c *ResponseCache) evict() {
+	entries, err := c.entries()
+	if err != nil {
+		return
+	}
+
+	slices.SortFunc(entries, func(a, b cacheFile) int {
+		return a.modTime.Compare(b.modTime)
+	})
+
+	var total int64
+	for _, e := range entries {
+		total += e.size
+	}
+
+	now := c.now()
+	for _, e := range entries {
+		if total <= c.maxSize && now.Sub(e.modTime) <= c.ttl {
+			continue
+		}
+		if os.Remove(e.path) == nil {
+			total -= e.size
+		}
+	}
+}
+
+func (c *ResponseCache) entries() ([]cacheFile, error) {
+	dirEntries, err := os.ReadDir(c.dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var files []cacheFile
+	for _, d := range dirEntries {
+		if d.IsDir() || !strings.HasSuffix(d.Name(), ".json") {
+			continue
+		}
+		info, err := d.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, cacheFile{
+			path:    filepath.Join(c.dir, d.Name()),
+			size:    info.Size(),
+			modTime: info.ModTime(),
+		})
+	}
+	return files, nil
+}
+
+func (c *ResponseCache) path(key string) string {
+	return filepath.Join(c.dir, key+".json")
+}
+
+// CachedProvider answers from a ResponseCache and falls through to the
+// wrapped provider on a miss.
+type CachedProvider struct {
+	provider Provider
+	cache    *ResponseCache
+	// scoped is read on every call, since a Switch may change its scope.
+	scoped cacheable
+}
+
+func (c *CachedProvider) Complete(ctx context.Context, system, userMsg string) (Response, error) {
+	return c.Chat(ctx, system, userTurn(userMsg))
+}
+
+func (c *CachedProvider) Chat(ctx context.Context, system string, msgs []Message) (Response, error) {
+	key, ok := c.key(system, msgs)
+	if !ok {
+		return Chat(ctx, c.provider, system, msgs)
+	}
+	if e, ok := c.cache.get(key); ok {
+		return e.response(), nil
+	}
+
+	resp, err := Chat(ctx, c.provider, system, msgs)
+	if err != nil {
+		return resp, err
+	}
+
+	c.store(key, resp)
+	return resp, nil
+}
+
+func (c *CachedProvider) Stream(ctx context.Context, system, userMsg string, onDelta func(string) error) (Response, error) {
+	return c.StreamChat(ctx, system, userTurn(userMsg), onDelta)
+}
+
+func (c *CachedProvider) StreamChat(ctx context.Context, system string, msgs []Message, onDelta func(string) error) (Response, error) {
+	key, ok := c.key(system, msgs)
+	if !ok {
+		return StreamChat(ctx, c.provider, system, msgs, onDelta)
+	}
+	if e, ok := c.cache.get(key); ok {
+		resp := e.response()
+		if resp.Text != "" {
+			if err := onDelta(resp.Text); err != nil {
+				return Response{}, err
+			}
+		}
+		return resp, nil
+	}
+
+	resp, err := StreamChat(ctx, c.provider, system, msgs, onDelta)
+	if err != nil {
+		return resp, err
+	}
+
+	c.store(key, resp)
+	return resp, nil
+}
+
+// store caches resp unless it is empty, which is more likely a glitch
+// than an answer worth repeating.
+func (c *CachedProvider) store(key string, resp Response) {
+	if strings.TrimSpace(resp.Text) == "" {
+		return
+	}
+	c.cache.put(key, newCacheEntry(c.cache.now(), resp))
+}
+
+func (c *CachedProvider) describe() Usage {
+	return Describe(c.provider)
+}
+
+// key hashes the scope and prompt. Each part is prefixed with its length
+// so that moving text between the system prompt and the user message
+// changes the key. A single user turn is hashed as its content alone, and
+// longer conversations with the role of every turn. It reports false when
+// the provider cannot be cached.
+func (c *CachedProvider) key(system string, msgs []Message) (string, bool) {
+	scope := c.scoped.cacheScope()
+	if scope == "" {
+		return "", false
+	}
+
+	parts := []string{scope, system}
+	if len(msgs) == 1 && msgs[0].Role == "user" {
+		parts = append(parts, msgs[0].Content)
+	} else {
+		for _, msg := range msgs {
+			parts = append(parts, msg.Role, msg.Content)
+		}
+	}
+
+	h := sha256.New()
+	for _, part := range parts {
+		_, _ = fmt.Fprintf(h, "%d:%s", len(part), part)
+	}
+	return hex.EncodeToString(h.Sum(nil)), true
+}
+
+func newCacheEntry(now time.Time, resp Response) cacheEntry {
+	return cacheEntry{
+		Created:  now,
+		Text:     resp.Text,
+		Provider: resp.Usage.Provider,
+		Model:    resp.Usage.Model,
+	}
+}
+
+// response returns the cached text. Token counts and cost are left zero
+// since serving it cost nothing.
+func (e cacheEntry) response() Response {
+	return Response{
+		Text:   e.Text,
+		Usage:  Usage{Provider: e.Provider, Model: e.Model},
+		Cached: true,
+	}
+}
@@ -0,0 +1,168 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// newCountingServer answers every chat completion with the number of
+// requests it has seen so far.
+func newCountingServer(t *testing.T) (*httptest.Server, *int) {
+	t.Helper()
+
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		_, _ = fmt.Fprintf(w, `{"model":"gpt-4o-mini","choices":[{"message":{"content":"answer %d"}}],"usage":{"prompt_tokens":10,"completion_tokens":2}}`, requests)
+	}))
+	t.Cleanup(server.Close)
+
+	return server, &requests
+}
+
+func TestResponseCache(t *testing.T) {
+	server, requests := newCountingServer(t)
+	cache := NewResponseCache(t.TempDir(), time.Hour, 0)
+
+	p := cache.Wrap(NewOpenAIProvider(server.URL, "gpt-4o-mini", "key"))
+	complete := func(p Provider, system, userMsg string) Response {
+		t.Helper()
+		resp, err := p.Complete(context.Background(), system, userMsg)
+		if err != nil {
+			t.Fatalf("Complete() error = %v, want nil", err)
+		}
+		return resp
+	}
+
+	first := complete(p, "system", "diff")
+	if first.Cached || first.Usage.InputTokens != 10 {
+		t.Errorf("first Complete() = %+v, want uncached response with usage", first)
+	}
+
+	second := complete(p, "system", "diff")
+	want := Response{Text: "answer 1", Usage: Usage{Provider: "openai", Model: "gpt-4o-mini"}, Cached: true}
+	if second != want {
+		t.Errorf("second Complete() = %+v, want %+v", second, want)
+	}
+
+	complete(p, "other system", "diff")
+	complete(p, "system", "other diff")
+	complete(cache.Wrap(NewOpenAIProvider(server.URL, "gpt-4o", "key")), "system", "diff")
+	complete(cache.Wrap(NewOpenAIProvider(server.URL, "gpt-4o-mini", "key", WithTemperature(0))), "system", "diff")
+
+	if *requests != 5 {
+		t.Errorf("server saw %d requests, want 5", *requests)
+	}
+
+	var deltas []string
+	resp, err := Stream(context.Background(), p, "system", "diff", func(delta string) error {
+		deltas = append(deltas, delta)
+		return nil
+	})
+	if err != nil || !resp.Cached || len(deltas) != 1 || deltas[0] != "answer 1" {
+		t.Errorf("Stream() = %+v, %v with deltas %q, want cached answer 1", resp, err, deltas)
+	}
+}
+
+func TestResponseCacheExpires(t *testing.T) {
+	server, requests := newCountingServer(t)
+	cache := NewResponseCache(t.TempDir(), time.Hour, 0)
+	p := cache.Wrap(NewOpenAIProvider(server.URL, "gpt-4o-mini", "key"))
+
+	now := time.Now()
+	cache.now = func() time.Time { return now }
+	if _, err := p.Complete(context.Background(), "", "hi"); err != nil {
+		t.Fatal(err)
+	}
+
+	cache.now = func() time.Time { return now.Add(2 * time.Hour) }
+	resp, err := p.Complete(context.Background(), "", "hi")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.Cached || resp.Text != "answer 2" || *requests != 2 {
+		t.Errorf("Complete() after TTL = %+v after %d requests, want fresh answer 2", resp, *requests)
+	}
+}
+
+func TestResponseCacheEvictsOldestOverSizeLimit(t *testing.T) {
+	dir := t.TempDir()
+	cache := NewResponseCache(dir, time.Hour, 300)
+
+	base := time.Now()
+	for i := range 4 {
+		key := fmt.Sprintf("entry%d", i)
+		cache.put(key, cacheEntry{Created: base, Text: fmt.Sprintf("%080d", i)})
+		// Give each entry a distinct age so eviction order is deterministic.
+		mtime := base.Add(time.Duration(i-4) * time.Minute)
+		if err := os.Chtimes(cache.path(key), mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cache.evict()
+
+	var kept []string
+	for i := range 4 {
+		if _, err := os.Stat(filepath.Join(dir, fmt.Sprintf("entry%d.json", i))); err == nil {
+			kept = append(kept, fmt.Sprint(i))
+		}
+	}
+	if fmt.Sprint(kept) != "[2 3]" {
+		t.Errorf("kept entries %v, want the two newest [2 3]", kept)
+	}
+}
+
+func TestResponseCacheClear(t *testing.T) {
+	server, _ := newCountingServer(t)
+	dir := t.TempDir()
+	cache := NewResponseCache(dir, time.Hour, 0)
+	p := cache.Wrap(NewOpenAIProvider(server.URL, "gpt-4o-mini", "key"))
+
+	for _, msg := range []string{"a", "b"} {
+		if _, err := p.Complete(context.Background(), "", msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	n, err := cache.Clear()
+	if err != nil || n != 2 {
+		t.Errorf("Clear() = %d, %v, want 2, nil", n, err)
+	}
+
+	if n, err := NewResponseCache(filepath.Join(dir, "missing"), 0, 0).Clear(); err != nil || n != 0 {
+		t.Errorf("Clear() on missing dir = %d, %v, want 0, nil", n, err)
+	}
+}
+
+func TestResponseCacheSkipsEmptyAndUncacheable(t *testing.T) {
+	cache := NewResponseCache(t.TempDir(), time.Hour, 0)
+
+	stub := &stubProvider{resp: "ok"}
+	if got := cache.Wrap(stub); got != Provider(stub) {
+		t.Errorf("Wrap() = %T, want provider without a cache scope returned unchanged", got)
+	}
+
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		_, _ = w.Write([]byte(`{"choices":[{"message":{"content":"  "}}]}`))
+	}))
+	t.Cleanup(server.Close)
+
+	p := cache.Wrap(NewOpenAIProvider(server.URL, "gpt-4o-mini", "key"))
+	for range 2 {
+		if _, err := p.Complete(context.Background(), "", "hi"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if requests != 2 {
+		t.Errorf("server saw %d requests, want empty responses not cached", requests)
+	}
+}
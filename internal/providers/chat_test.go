@@ -0,0 +1,185 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+var conversation = []Message{
+	{Role: "user", Content: "Write a commit message"},
+	{Role: "assistant", Content: "Add usage notes to the README"},
+	{Role: "user", Content: "Make it shorter"},
+}
+
+func TestChatSendsConversation(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		provider func(endpoint string) Provider
+		want     string
+	}{
+		{
+			name: "openai",
+			body: `{"choices":[{"message":{"content":"Document usage"}}]}`,
+			provider: func(endpoint string) Provider {
+				return NewOpenAIProvider(endpoint, "gpt-4o-mini", "key")
+			},
+			want: `{"model":"gpt-4o-mini","messages":[{"role":"system","content":"Be brief."},{"role":"user","content":"Write a commit message"},{"role":"assistant","content":"Add usage notes to the README"},{"role":"user","content":"Make it shorter"}]}`,
+		},
+		{
+			name: "anthropic",
+			body: `{"content":[{"type":"text","text":"Document usage"}],"stop_reason":"end_turn"}`,
+			provider: func(endpoint string) Provider {
+				return NewAnthropicProvider(endpoint, "claude-haiku-4-5", "key")
+			},
+			want: `{"model":"claude-haiku-4-5","max_tokens":4096,"system":"Be brief.","messages":[{"role":"user","content":"Write a commit message"},{"role":"assistant","content":"Add usage notes to the README"},{"role":"user","content":"Make it shorter"}]}`,
+		},
+		{
+			name: "gemini",
+			body: `{"candidates":[{"content":{"parts":[{"text":"Document usage"}]},"finishReason":"STOP"}]}`,
+			provider: func(endpoint string) Provider {
+				return NewGeminiProvider(endpoint, "gemini-2.5-flash", "key")
+			},
+			want: `{"systemInstruction":{"parts":[{"text":"Be brief."}]},"contents":[{"role":"user","parts":[{"text":"Write a commit message"}]},{"role":"model","parts":[{"text":"Add usage notes to the README"}]},{"role":"user","parts":[{"text":"Make it shorter"}]}]}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []byte
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got, _ = io.ReadAll(r.Body)
+				_, _ = w.Write([]byte(tt.body))
+			}))
+			t.Cleanup(server.Close)
+
+			resp, err := Chat(context.Background(), tt.provider(server.URL), "Be brief.", conversation)
+			if err != nil {
+				t.Fatalf("Chat() error = %v, want nil", err)
+			}
+			if resp.Text != "Document usage" {
+				t.Errorf("Chat() = %q, want %q", resp.Text, "Document usage")
+			}
+			if string(canonicalBody(got)) != string(canonicalBody([]byte(tt.want))) {
+				t.Errorf("request body =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStreamChatSendsConversation(t *testing.T) {
+	var got openaiRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewDecoder(r.Body).Decode(&got)
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = w.Write([]byte("data: {\"choices\":[{\"delta\":{\"content\":\"Document \"}}]}\n\ndata: {\"choices\":[{\"delta\":{\"content\":\"usage\"}}]}\n\ndata: [DONE]\n\n"))
+	}))
+	t.Cleanup(server.Close)
+
+	var deltas []string
+	resp, err := StreamChat(context.Background(), NewOpenAIProvider(server.URL, "gpt-4o-mini", "key"), "", conversation, func(delta string) error {
+		deltas = append(deltas, delta)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("StreamChat() error = %v, want nil", err)
+	}
+
+	if resp.Text != "Document usage" || !reflect.DeepEqual(deltas, []string{"Document ", "usage"}) {
+		t.Errorf("StreamChat() = %q with deltas %q", resp.Text, deltas)
+	}
+	if !got.Stream || !reflect.DeepEqual(got.Messages, conversation) {
+		t.Errorf("request = %+v, want the conversation streamed", got)
+	}
+}
+
+func TestChatWithoutChatter(t *testing.T) {
+	p := &completeOnlyProvider{resp: "whole answer"}
+
+	resp, err := Chat(context.Background(), p, "", conversation[:1])
+	if err != nil || resp.Text != "whole answer" {
+		t.Errorf("Chat(one turn) = %q, %v, want the Complete answer", resp.Text, err)
+	}
+
+	var deltas []string
+	resp, err = StreamChat(context.Background(), p, "", conversation[:1], func(delta string) error {
+		deltas = append(deltas, delta)
+		return nil
+	})
+	if err != nil || resp.Text != "whole answer" || len(deltas) != 1 {
+		t.Errorf("StreamChat(one turn) = %q, %v with deltas %q, want the Complete answer", resp.Text, err, deltas)
+	}
+
+	if _, err := Chat(context.Background(), p, "", conversation); err == nil || !strings.Contains(err.Error(), "cannot continue a conversation") {
+		t.Errorf("Chat(three turns) error = %v, want cannot continue a conversation", err)
+	}
+}
+
+func TestChatRejectsInvalidConversations(t *testing.T) {
+	p := NewOpenAIProvider("http://localhost:1", "gpt-4o-mini", "key")
+
+	tests := []struct {
+		name string
+		msgs []Message
+		want string
+	}{
+		{"empty", nil, "no messages"},
+		{"system message", []Message{{Role: "system", Content: "Be brief."}, {Role: "user", Content: "hi"}}, `role "system"`},
+		{"ends with the assistant", conversation[:2], "must end with a user message"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Chat(context.Background(), p, "", tt.msgs)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Chat() error = %v, want to contain %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestResponseCacheKeysConversations(t *testing.T) {
+	server, requests := newCountingServer(t)
+	p := NewResponseCache(t.TempDir(), time.Hour, 0).Wrap(NewOpenAIProvider(server.URL, "gpt-4o-mini", "key"))
+	chat := func(msgs []Message) Response {
+		t.Helper()
+		resp, err := Chat(context.Background(), p, "", msgs)
+		if err != nil {
+			t.Fatalf("Chat() error = %v, want nil", err)
+		}
+		return resp
+	}
+
+	if _, err := p.Complete(context.Background(), "", "Write a commit message"); err != nil {
+		t.Fatalf("Complete() error = %v, want nil", err)
+	}
+	if resp := chat(conversation[:1]); !resp.Cached {
+		t.Errorf("Chat(one turn) = %+v, want the answer cached by Complete", resp)
+	}
+
+	first := chat(conversation)
+	if first.Cached || !chat(conversation).Cached {
+		t.Errorf("Chat(three turns) = %+v, want a new answer that is then cached", first)
+	}
+
+	// The same text in different roles is a different conversation.
+	swapped := []Message{
+		{Role: "user", Content: "Write a commit message"},
+		{Role: "user", Content: "Add usage notes to the README"},
+		{Role: "user", Content: "Make it shorter"},
+	}
+	if resp := chat(swapped); resp.Cached {
+		t.Errorf("Chat(swapped roles) = %+v, want a new answer", resp)
+	}
+
+	if *requests != 3 {
+		t.Errorf("server saw %d requests, want 3", *requests)
+	}
+}
@@ -0,0 +1,124 @@
+package providers
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// WithDebugLog writes every request made by the provider and its response
+// to w: the method, URL, headers with credentials redacted, the size of
+// the request body, the status, the latency and the response body.
+func WithDebugLog(w io.Writer) Option {
+	return func(o *options) { o.debug = w }
+}
+
+// debugTransport logs the requests sent through next to w.
+type debugTransport struct {
+	next http.RoundTripper
+	w    io.Writer
+	mu   sync.Mutex
+}
+
+func newDebugTransport(next http.RoundTripper, w io.Writer) *debugTransport {
+	return &debugTransport{next: next, w: w}
+}
+
+func (d *debugTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "debug: > %s %s\n", req.Method, redactURL(req.URL))
+	for _, name := range slices.Sorted(maps.Keys(req.Header)) {
+		fmt.Fprintf(&b, "debug: > %s: %s\n", name, redactHeader(name, req.Header.Get(name)))
+	}
+	fmt.Fprintf(&b, "debug: > body: %d bytes\n", req.ContentLength)
+	d.write(b.String())
+
+	start := time.Now()
+	resp, err := d.next.RoundTrip(req)
+	latency := time.Since(start).Round(time.Millisecond)
+	if err != nil {
+		d.write(fmt.Sprintf("debug: < error after %s: %v\n", latency, err))
+		return nil, err
+	}
+
+	d.write(fmt.Sprintf("debug: < %s in %s\n", resp.Status, latency))
+	resp.Body = &debugBody{ReadCloser: resp.Body, log: d}
+	return resp, nil
+}
+
+func (d *debugTransport) write(s string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	_, _ = io.WriteString(d.w, s)
+}
+
+// debugBody logs the response body once it has been read and closed, so
+// that streamed responses are still delivered as they arrive.
+type debugBody struct {
+	io.ReadCloser
+	log  *debugTransport
+	buf  bytes.Buffer
+	once sync.Once
+}
+
+func (b *debugBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.buf.Write(p[:n])
+	return n, err
+}
+
+func (b *debugBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(func() {
+		body := strings.TrimRight(b.buf.String(), "\n")
+		b.log.write(fmt.Sprintf("debug: < body: %d bytes\n%s\n", b.buf.Len(), body))
+	})
+	return err
+}
+
+// sensitiveNames are substrings of header and query parameter names whose
+// values are never logged.
+var sensitiveNames = []string{"auth", "key", "token", "secret", "cookie", "signature"}
+
+func isSensitive(name string) bool {
+	name = strings.ToLower(name)
+	for _, s := range sensitiveNames {
+		if strings.Contains(name, s) {
+			return true
+		}
+	}
+	return false
+}
+
+func redactHeader(name, value string) string {
+	if isSensitive(name) {
+		return "[redacted]"
+	}
+	return value
+}
+
+func redactURL(u *url.URL) string {
+	redacted := *u
+	if redacted.User != nil {
+		redacted.User = url.User("redacted")
+	}
+
+	query := redacted.Query()
+	for name := range query {
+		if isSensitive(name) {
+			query.Set(name, "redacted")
+		}
+	}
+	if len(query) > 0 {
+		redacted.RawQuery = query.Encode()
+	}
+
+	return redacted.String()
+}
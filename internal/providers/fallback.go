@@ -0,0 +1,116 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Fallback is a provider in a fallback chain together with the name
+// used to report it.
+type Fallback struct {
+	Name     string
+	Provider Provider
+}
+
+// FallbackProvider tries a chain of providers in order. It moves on to the
+// next one only when a provider fails with an error that IsRetryable
+// reports as transient, such as an outage or rate limiting that outlasted
+// the provider's own retries. Other errors are returned immediately.
+type FallbackProvider struct {
+	chain  []Fallback
+	stderr io.Writer
+}
+
+// NewFallbackProvider returns a provider that tries chain in order and
+// writes a line to stderr whenever it falls back.
+func NewFallbackProvider(stderr io.Writer, chain ...Fallback) Provider {
+	if stderr == nil {
+		stderr = io.Discard
+	}
+
+	return &FallbackProvider{
+		chain:  chain,
+		stderr: stderr,
+	}
+}
+
+func (f *FallbackProvider) Complete(ctx context.Context, system, userMsg string) (Response, error) {
+	return f.Chat(ctx, system, userTurn(userMsg))
+}
+
+func (f *FallbackProvider) Chat(ctx context.Context, system string, msgs []Message) (Response, error) {
+	return f.try(func(p Provider) (Response, bool, error) {
+		resp, err := Chat(ctx, p, system, msgs)
+		return resp, false, err
+	})
+}
+
+func (f *FallbackProvider) Stream(ctx context.Context, system, userMsg string, onDelta func(string) error) (Response, error) {
+	return f.StreamChat(ctx, system, userTurn(userMsg), onDelta)
+}
+
+// StreamChat falls back only while nothing has been written to onDelta,
+// since output already shown cannot be taken back.
+func (f *FallbackProvider) StreamChat(ctx context.Context, system string, msgs []Message, onDelta func(string) error) (Response, error) {
+	return f.try(func(p Provider) (Response, bool, error) {
+		started := false
+		resp, err := StreamChat(ctx, p, system, msgs, func(delta string) error {
+			started = true
+			return onDelta(delta)
+		})
+		return resp, started, err
+	})
+}
+
+func (f *FallbackProvider) describe() Usage {
+	if len(f.chain) == 0 {
+		return Usage{}
+	}
+	return Describe(f.chain[0].Provider)
+}
+
+// cacheScope covers every provider in the chain, since any of them may
+// answer. Chains with a provider that cannot be cached return "".
+func (f *FallbackProvider) cacheScope() string {
+	scopes := make([]string, 0, len(f.chain))
+	for _, fb := range f.chain {
+		c, ok := fb.Provider.(cacheable)
+		if !ok {
+			return ""
+		}
+		scopes = append(scopes, c.cacheScope())
+	}
+	return strings.Join(scopes, "\n\n")
+}
+
+// try calls attempt with each provider in turn. attempt reports whether it
+// produced output, in which case its error is final.
+func (f *FallbackProvider) try(attempt func(Provider) (Response, bool, error)) (Response, error) {
+	var lastErr error
+
+	for i, fb := range f.chain {
+		resp, started, err := attempt(fb.Provider)
+		if err == nil {
+			if i > 0 {
+				_, _ = fmt.Fprintf(f.stderr, "llm: answered by %s\n", fb.Name)
+			}
+			return resp, nil
+		}
+
+		if started || !IsRetryable(err) {
+			return resp, err
+		}
+
+		lastErr = fmt.Errorf("%s: %w", fb.Name, err)
+		if i+1 < len(f.chain) {
+			_, _ = fmt.Fprintf(f.stderr, "llm: %s failed (%v), trying %s\n", fb.Name, err, f.chain[i+1].Name)
+		}
+	}
+
+	if lastErr == nil {
+		return Response{}, fmt.Errorf("no providers configured")
+	}
+	return Response{}, fmt.Errorf("all providers failed, last error: %w", lastErr)
+}
@@ -0,0 +1,191 @@
+package providers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"llm/internal/config"
+)
+
+type stubProvider struct {
+	resp   string
+	deltas []string
+	err    error
+	calls  int
+}
+
+func (s *stubProvider) Complete(ctx context.Context, system, userMsg string) (Response, error) {
+	s.calls++
+	return Response{Text: s.resp}, s.err
+}
+
+type stubStreamProvider struct {
+	stubProvider
+}
+
+func (s *stubStreamProvider) Stream(ctx context.Context, system, userMsg string, onDelta func(string) error) (Response, error) {
+	s.calls++
+	for _, delta := range s.deltas {
+		if err := onDelta(delta); err != nil {
+			return Response{}, err
+		}
+	}
+	return Response{Text: strings.Join(s.deltas, "")}, s.err
+}
+
+func TestFallbackProviderComplete(t *testing.T) {
+	unavailable := &StatusError{StatusCode: 503, Message: "down"}
+
+	tests := []struct {
+		name          string
+		chain         []*stubProvider
+		want          string
+		wantErrSubstr string
+		wantCalls     []int
+		wantStderr    string
+	}{
+		{
+			name:      "primary answers",
+			chain:     []*stubProvider{{resp: "primary"}, {resp: "secondary"}},
+			want:      "primary",
+			wantCalls: []int{1, 0},
+		},
+		{
+			name:       "falls back on retryable error",
+			chain:      []*stubProvider{{err: unavailable}, {resp: "secondary"}},
+			want:       "secondary",
+			wantCalls:  []int{1, 1},
+			wantStderr: "llm: first failed (503 Service Unavailable: down), trying second\nllm: answered by second\n",
+		},
+		{
+			name:          "stops on non-retryable error",
+			chain:         []*stubProvider{{err: &StatusError{StatusCode: 401, Message: "bad key"}}, {resp: "secondary"}},
+			wantErrSubstr: "401 Unauthorized: bad key",
+			wantCalls:     []int{1, 0},
+		},
+		{
+			name:          "reports last error when all fail",
+			chain:         []*stubProvider{{err: unavailable}, {err: &StatusError{StatusCode: 429}}},
+			wantErrSubstr: "all providers failed, last error: second: 429 Too Many Requests",
+			wantCalls:     []int{1, 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stderr bytes.Buffer
+			names := []string{"first", "second"}
+			var chain []Fallback
+			for i, p := range tt.chain {
+				chain = append(chain, Fallback{Name: names[i], Provider: p})
+			}
+
+			resp, err := NewFallbackProvider(&stderr, chain...).Complete(context.Background(), "", "hi")
+
+			if tt.wantErrSubstr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErrSubstr) {
+					t.Errorf("Complete() error = %v, want substring %q", err, tt.wantErrSubstr)
+				}
+			} else if err != nil || resp.Text != tt.want {
+				t.Errorf("Complete() = %q, %v, want %q", resp.Text, err, tt.want)
+			}
+
+			for i, p := range tt.chain {
+				if p.calls != tt.wantCalls[i] {
+					t.Errorf("provider %d calls = %d, want %d", i, p.calls, tt.wantCalls[i])
+				}
+			}
+
+			if tt.wantStderr != "" && stderr.String() != tt.wantStderr {
+				t.Errorf("stderr = %q, want %q", stderr.String(), tt.wantStderr)
+			}
+		})
+	}
+}
+
+func TestFallbackProviderStream(t *testing.T) {
+	unavailable := &StatusError{StatusCode: 503}
+
+	t.Run("falls back before any output", func(t *testing.T) {
+		first := &stubStreamProvider{stubProvider{err: unavailable}}
+		second := &stubStreamProvider{stubProvider{deltas: []string{"hel", "lo"}}}
+
+		var deltas []string
+		resp, err := Stream(context.Background(), NewFallbackProvider(io.Discard,
+			Fallback{Name: "first", Provider: first},
+			Fallback{Name: "second", Provider: second},
+		), "", "hi", func(delta string) error {
+			deltas = append(deltas, delta)
+			return nil
+		})
+
+		if err != nil || resp.Text != "hello" || len(deltas) != 2 {
+			t.Errorf("Stream() = %q, %v with deltas %q, want %q", resp.Text, err, deltas, "hello")
+		}
+	})
+
+	t.Run("does not fall back after output", func(t *testing.T) {
+		first := &stubStreamProvider{stubProvider{deltas: []string{"partial"}, err: unavailable}}
+		second := &stubStreamProvider{stubProvider{deltas: []string{"full"}}}
+
+		_, err := Stream(context.Background(), NewFallbackProvider(io.Discard,
+			Fallback{Name: "first", Provider: first},
+			Fallback{Name: "second", Provider: second},
+		), "", "hi", func(string) error { return nil })
+
+		if !errors.Is(err, unavailable) {
+			t.Errorf("Stream() error = %v, want %v", err, unavailable)
+		}
+		if second.calls != 0 {
+			t.Errorf("second provider calls = %d, want 0", second.calls)
+		}
+	})
+}
+
+func TestResolveFallbackChain(t *testing.T) {
+	clearAPIKeys(t)
+	t.Setenv("OPENROUTER_API_KEY", "r")
+	t.Setenv("ANTHROPIC_API_KEY", "a")
+	t.Setenv("OPENAI_API_KEY", "o")
+
+	p, err := Resolve(config.Settings{
+		Model:    "anthropic/claude-sonnet-4.5",
+		Fallback: []string{"openrouter", "anthropic", "openai"},
+	}, io.Discard)
+	if err != nil {
+		t.Fatalf("Resolve() error = %v, want nil", err)
+	}
+
+	fp, ok := p.(*FallbackProvider)
+	if !ok {
+		t.Fatalf("Resolve() = %T, want *FallbackProvider", p)
+	}
+
+	var names []string
+	for _, fb := range fp.chain {
+		names = append(names, fb.Name)
+	}
+	if strings.Join(names, ",") != "openrouter,anthropic,openai" {
+		t.Errorf("chain = %v, want openrouter,anthropic,openai", names)
+	}
+
+	if _, model := describe(fp.chain[1].Provider); model != "claude-haiku-4-5" {
+		t.Errorf("fallback model = %q, want provider default %q", model, "claude-haiku-4-5")
+	}
+
+	var stderr bytes.Buffer
+	p, err = Resolve(config.Settings{Fallback: []string{"gemini"}}, &stderr)
+	if err != nil {
+		t.Fatalf("Resolve() with an unusable fallback error = %v, want nil", err)
+	}
+	if _, ok := p.(*FallbackProvider); ok {
+		t.Errorf("Resolve() = %T, want the primary provider alone", p)
+	}
+	if !strings.Contains(stderr.String(), "skipping fallback gemini: provider \"gemini\" requires GEMINI_API_KEY") {
+		t.Errorf("stderr = %q, want a warning about the missing fallback key", stderr.String())
+	}
+}
@@ -0,0 +1,208 @@
+package providers
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+type geminiRequest struct {
+	SystemInstruction *geminiContent          `json:"systemInstruction,omitempty"`
+	Contents          []geminiContent         `json:"contents"`
+	GenerationConfig  *geminiGenerationConfig `json:"generationConfig,omitempty"`
+}
+
+type geminiContent struct {
+	Role  string       `json:"role,omitempty"`
+	Parts []geminiPart `json:"parts"`
+}
+
+type geminiPart struct {
+	Text string `json:"text"`
+}
+
+type geminiGenerationConfig struct {
+	MaxOutputTokens int      `json:"maxOutputTokens,omitempty"`
+	Temperature     *float64 `json:"temperature,omitempty"`
+}
+
+type geminiResponse struct {
+	Candidates []struct {
+		Content      geminiContent `json:"content"`
+		FinishReason string        `json:"finishReason"`
+	} `json:"candidates"`
+	ModelVersion  string `json:"modelVersion"`
+	UsageMetadata *struct {
+		PromptTokenCount     int `json:"promptTokenCount"`
+		CandidatesTokenCount int `json:"candidatesTokenCount"`
+	} `json:"usageMetadata,omitempty"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+}
+
+// GeminiProvider talks to the Gemini generateContent API. Its endpoint is
+// the models collection URL; the model and method are appended per request.
+type GeminiProvider struct {
+	endpoint string
+	model    string
+	apiKey   string
+	options
+}
+
+func NewGeminiProvider(endpoint, model, apiKey string, opts ...Option) Provider {
+	return &GeminiProvider{
+		endpoint: endpoint,
+		model:    model,
+		apiKey:   apiKey,
+		options:  newOptions(opts),
+	}
+}
+
+func (g *GeminiProvider) Complete(ctx context.Context, system, userMsg string) (Response, error) {
+	return g.Chat(ctx, system, userTurn(userMsg))
+}
+
+func (g *GeminiProvider) Chat(ctx context.Context, system string, msgs []Message) (Response, error) {
+	return withContinuations(g.options, msgs, func(msgs []Message) (Response, error) {
+		return g.complete(ctx, system, msgs)
+	})
+}
+
+func (g *GeminiProvider) Stream(ctx context.Context, system, userMsg string, onDelta func(string) error) (Response, error) {
+	return g.StreamChat(ctx, system, userTurn(userMsg), onDelta)
+}
+
+func (g *GeminiProvider) StreamChat(ctx context.Context, system string, msgs []Message, onDelta func(string) error) (Response, error) {
+	return withContinuations(g.options, msgs, func(msgs []Message) (Response, error) {
+		return g.stream(ctx, system, msgs, onDelta)
+	})
+}
+
+func (g *GeminiProvider) complete(ctx context.Context, system string, msgs []Message) (Response, error) {
+	var r geminiResponse
+	if err := doJSONRequest(ctx, g.url("generateContent"), g.request(system, msgs), &r, map[string]string{
+		"x-goog-api-key": g.apiKey,
+	}, g.options); err != nil {
+		return Response{}, err
+	}
+
+	if r.Error != nil {
+		return Response{}, fmt.Errorf("%s", r.Error.Message)
+	}
+
+	resp := Response{Text: r.text(), Usage: r.usage(g.model)}
+	if r.truncated() {
+		return resp, &TruncatedError{Response: resp}
+	}
+	return resp, nil
+}
+
+func (g *GeminiProvider) stream(ctx context.Context, system string, msgs []Message, onDelta func(string) error) (Response, error) {
+	var text strings.Builder
+	usage := g.describe()
+	truncated := false
+	err := doStreamRequest(ctx, g.url("streamGenerateContent")+"?alt=sse", g.request(system, msgs), map[string]string{
+		"x-goog-api-key": g.apiKey,
+	}, g.options, func(data []byte) error {
+		var chunk geminiResponse
+		if err := json.Unmarshal(data, &chunk); err != nil {
+			return fmt.Errorf("failed to parse stream event: %w", err)
+		}
+
+		if chunk.Error != nil {
+			return fmt.Errorf("%s", chunk.Error.Message)
+		}
+
+		// Every chunk carries the usage so far.
+		if chunk.UsageMetadata != nil {
+			usage = chunk.usage(g.model)
+		}
+		if chunk.truncated() {
+			truncated = true
+		}
+
+		delta := chunk.text()
+		if delta == "" {
+			return nil
+		}
+		text.WriteString(delta)
+		return onDelta(delta)
+	})
+	if err != nil {
+		return Response{}, err
+	}
+
+	resp := Response{Text: text.String(), Usage: usage}
+	if truncated {
+		return resp, &TruncatedError{Response: resp}
+	}
+	return resp, nil
+}
+
+func (g *GeminiProvider) describe() Usage {
+	return Usage{Provider: "gemini", Model: g.model}
+}
+
+func (g *GeminiProvider) cacheScope() string {
+	return g.options.cacheScope(g.endpoint, g.model)
+}
+
+func (g *GeminiProvider) url(method string) string {
+	return fmt.Sprintf("%s/%s:%s", strings.TrimSuffix(g.endpoint, "/"), g.model, method)
+}
+
+// request converts msgs to Gemini contents, where the assistant is called
+// "model".
+func (g *GeminiProvider) request(system string, msgs []Message) geminiRequest {
+	var req geminiRequest
+	for _, msg := range msgs {
+		role := msg.Role
+		if role == "assistant" {
+			role = "model"
+		}
+		req.Contents = append(req.Contents, geminiContent{Role: role, Parts: []geminiPart{{Text: msg.Content}}})
+	}
+
+	if system != "" {
+		req.SystemInstruction = &geminiContent{Parts: []geminiPart{{Text: system}}}
+	}
+
+	if g.maxTokens > 0 || g.temperature != nil {
+		req.GenerationConfig = &geminiGenerationConfig{
+			MaxOutputTokens: g.maxTokens,
+			Temperature:     g.temperature,
+		}
+	}
+
+	return req
+}
+
+// text joins the text parts of the first candidate.
+func (r geminiResponse) text() string {
+	if len(r.Candidates) == 0 {
+		return ""
+	}
+
+	var text strings.Builder
+	for _, part := range r.Candidates[0].Content.Parts {
+		text.WriteString(part.Text)
+	}
+	return text.String()
+}
+
+// truncated reports whether the first candidate stopped at the token limit.
+func (r geminiResponse) truncated() bool {
+	return len(r.Candidates) > 0 && r.Candidates[0].FinishReason == "MAX_TOKENS"
+}
+
+func (r geminiResponse) usage(model string) Usage {
+	u := Usage{Provider: "gemini", Model: cmp.Or(r.ModelVersion, model)}
+	if r.UsageMetadata != nil {
+		u.InputTokens = r.UsageMetadata.PromptTokenCount
+		u.OutputTokens = r.UsageMetadata.CandidatesTokenCount
+	}
+	return u
+}
@@ -0,0 +1,101 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestGeminiProviderComplete(t *testing.T) {
+	var gotReq geminiRequest
+	var gotKey string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1beta/models/gemini-2.5-flash:generateContent" {
+			t.Errorf("request path = %q, want generateContent for the model", r.URL.Path)
+		}
+		gotKey = r.Header.Get("x-goog-api-key")
+		if err := json.NewDecoder(r.Body).Decode(&gotReq); err != nil {
+			t.Errorf("decoding request body: %v", err)
+		}
+
+		_, _ = w.Write([]byte(`{"candidates":[{"content":{"role":"model","parts":[{"text":"Hello"},{"text":", world"}]}}]}`))
+	}))
+	t.Cleanup(server.Close)
+
+	p := NewGeminiProvider(server.URL+"/v1beta/models", "gemini-2.5-flash", "key", WithMaxTokens(256))
+	resp, err := p.Complete(context.Background(), "be brief", "hi")
+	if err != nil {
+		t.Fatalf("Complete() error = %v, want nil", err)
+	}
+
+	if resp.Text != "Hello, world" {
+		t.Errorf("Complete() = %q, want %q", resp.Text, "Hello, world")
+	}
+
+	if gotKey != "key" {
+		t.Errorf("x-goog-api-key = %q, want %q", gotKey, "key")
+	}
+
+	wantReq := geminiRequest{
+		SystemInstruction: &geminiContent{Parts: []geminiPart{{Text: "be brief"}}},
+		Contents:          []geminiContent{{Role: "user", Parts: []geminiPart{{Text: "hi"}}}},
+		GenerationConfig:  &geminiGenerationConfig{MaxOutputTokens: 256},
+	}
+	if !reflect.DeepEqual(gotReq, wantReq) {
+		t.Errorf("request = %#v, want %#v", gotReq, wantReq)
+	}
+}
+
+func TestGeminiProviderOmitsEmptySystemInstruction(t *testing.T) {
+	var body map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		_, _ = w.Write([]byte(`{"candidates":[]}`))
+	}))
+	t.Cleanup(server.Close)
+
+	p := NewGeminiProvider(server.URL, "gemini-2.5-flash", "key")
+	if _, err := p.Complete(context.Background(), "", "hi"); err != nil {
+		t.Fatalf("Complete() error = %v, want nil", err)
+	}
+
+	for _, key := range []string{"systemInstruction", "generationConfig"} {
+		if _, ok := body[key]; ok {
+			t.Errorf("request has %q, want it omitted", key)
+		}
+	}
+}
+
+func TestGeminiProviderStream(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/models/gemini-2.5-flash:streamGenerateContent" || r.URL.Query().Get("alt") != "sse" {
+			t.Errorf("request URL = %q, want streamGenerateContent with alt=sse", r.URL.String())
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, text := range []string{"Hello", ", world"} {
+			_, _ = fmt.Fprintf(w, "data: {\"candidates\":[{\"content\":{\"parts\":[{\"text\":%q}]}}]}\n\n", text)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	p := NewGeminiProvider(server.URL+"/models", "gemini-2.5-flash", "key")
+
+	var deltas []string
+	resp, err := Stream(context.Background(), p, "", "hi", func(delta string) error {
+		deltas = append(deltas, delta)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Stream() error = %v, want nil", err)
+	}
+
+	if resp.Text != "Hello, world" || !reflect.DeepEqual(deltas, []string{"Hello", ", world"}) {
+		t.Errorf("Stream() = %q with deltas %q, want %q", resp.Text, deltas, "Hello, world")
+	}
+}
@@ -1,70 +1,236 @@
 package providers
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"strings"
 	"time"
 )
 
-// doRequest executes an HTTP POST request with the given endpoint, body, and headers.
-// It applies a 30-second timeout and returns the response body or an error.
-func doRequest(ctx context.Context, endpoint string, body []byte, headers map[string]string) ([]byte, error) {
-	req, err := http.NewRequestWithContext(ctx, "POST", endpoint, bytes.NewReader(body))
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
-	}
+// defaultTimeout bounds blocking requests when no timeout is configured.
+// It leaves room for long answers from slow models, including those run
+// on local servers.
+const defaultTimeout = 2 * time.Minute
 
-	req.Header.Set("Content-Type", "application/json")
-	for key, value := range headers {
-		req.Header.Set(key, value)
+// doRequest executes an HTTP request with the given method, endpoint, body, and headers,
+// retrying transient failures as configured in o. Each attempt is bounded by the
+// configured timeout (2 minutes when unset). It returns the response body or an error.
+func doRequest(ctx context.Context, method, endpoint string, body []byte, headers map[string]string, o options) ([]byte, error) {
+	timeout := o.timeout
+	if timeout <= 0 {
+		timeout = defaultTimeout
 	}
 
-	client := &http.Client{Timeout: 30 * time.Second}
-	resp, err := client.Do(req)
+	client := &http.Client{Timeout: timeout, Transport: o.transportOrDefault()}
+	resp, err := send(ctx, client, func() (*http.Request, error) {
+		return newRequest(ctx, method, endpoint, body, headers)
+	}, o)
 	if err != nil {
 		return nil, err
 	}
 	defer func() { _ = resp.Body.Close() }()
 
-	respBody, err := io.ReadAll(resp.Body)
+	return io.ReadAll(resp.Body)
+}
+
+func doJSONRequest(ctx context.Context, endpoint string, req, resp any, headers map[string]string, o options) error {
+	jsonData, err := json.Marshal(req)
 	if err != nil {
-		return nil, err
+		return fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	body, err := doRequest(ctx, http.MethodPost, endpoint, jsonData, headers, o)
+	if err != nil {
+		return err
+	}
+
+	if err := json.Unmarshal(body, resp); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
 	}
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("%d: %s", resp.StatusCode, string(respBody))
+	return nil
+}
+
+// getJSON fetches endpoint with a GET request and decodes the JSON answer
+// into resp.
+func getJSON(ctx context.Context, endpoint string, resp any, headers map[string]string, o options) error {
+	body, err := doRequest(ctx, http.MethodGet, endpoint, nil, headers, o)
+	if err != nil {
+		return err
 	}
 
-	return respBody, nil
+	if err := json.Unmarshal(body, resp); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	return nil
 }
 
-func doJSONRequest(ctx context.Context, endpoint string, req, resp any, headers map[string]string) error {
+// errStreamIdle cancels a stream that has waited too long for data.
+var errStreamIdle = errors.New("stream idle")
+
+// doStreamRequest executes an HTTP POST request that answers with
+// server-sent events and calls onEvent with the data of each event.
+// Failures before the stream starts are retried as configured in o.
+// The client has no overall timeout because a stream may legitimately
+// run longer than a blocking request. Instead the configured timeout
+// (2 minutes when unset) bounds the wait for the response headers and
+// for each read of the body after them.
+func doStreamRequest(ctx context.Context, endpoint string, req any, headers map[string]string, o options, onEvent func(data []byte) error) error {
 	jsonData, err := json.Marshal(req)
 	if err != nil {
 		return fmt.Errorf("failed to marshal request: %w", err)
 	}
 
-	body, err := doRequest(ctx, endpoint, jsonData, headers)
+	timeout := o.timeout
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	idle := time.AfterFunc(timeout, func() { cancel(errStreamIdle) })
+	idle.Stop()
+
+	client := &http.Client{Transport: &idleTransport{next: o.transportOrDefault(), idle: idle, timeout: timeout}}
+	resp, err := send(ctx, client, func() (*http.Request, error) {
+		httpReq, err := newRequest(ctx, http.MethodPost, endpoint, jsonData, headers)
+		if err != nil {
+			return nil, err
+		}
+		httpReq.Header.Set("Accept", "text/event-stream")
+		return httpReq, nil
+	}, o)
+	if err == nil {
+		defer func() { _ = resp.Body.Close() }()
+		err = readEvents(resp.Body, onEvent)
+	}
+	if err != nil && errors.Is(context.Cause(ctx), errStreamIdle) {
+		return fmt.Errorf("no data from %s for %s: %w", endpoint, timeout, context.DeadlineExceeded)
+	}
+	return err
+}
+
+// idleTransport runs idle while a stream waits for response headers or
+// body data, so that a server that goes quiet cancels the request. The
+// timer is stopped between attempts, leaving retry delays unbounded by it.
+type idleTransport struct {
+	next    http.RoundTripper
+	idle    *time.Timer
+	timeout time.Duration
+}
+
+func (t *idleTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.idle.Reset(t.timeout)
+	resp, err := t.next.RoundTrip(req)
 	if err != nil {
-		return err
+		t.idle.Stop()
+		return nil, err
 	}
+	t.idle.Reset(t.timeout)
+	resp.Body = &idleBody{ReadCloser: resp.Body, t: t}
+	return resp, nil
+}
 
-	if err := json.Unmarshal(body, resp); err != nil {
-		return fmt.Errorf("failed to parse response: %w", err)
+// idleBody pushes back the idle timer whenever data arrives and stops it
+// once the body is closed.
+type idleBody struct {
+	io.ReadCloser
+	t *idleTransport
+}
+
+func (b *idleBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 {
+		b.t.idle.Reset(b.t.timeout)
 	}
+	return n, err
+}
 
-	return nil
+func (b *idleBody) Close() error {
+	b.t.idle.Stop()
+	return b.ReadCloser.Close()
+}
+
+// readEvents parses a text/event-stream body and calls onEvent with the
+// joined data lines of each event. Comments and other fields are ignored,
+// and the OpenAI-style "[DONE]" sentinel ends the stream.
+func readEvents(r io.Reader, onEvent func(data []byte) error) error {
+	reader := bufio.NewReader(r)
+	var data []string
+
+	dispatch := func() error {
+		if len(data) == 0 {
+			return nil
+		}
+		payload := strings.Join(data, "\n")
+		data = data[:0]
+		if payload == "[DONE]" {
+			return io.EOF
+		}
+		return onEvent([]byte(payload))
+	}
+
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return err
+		}
+		atEOF := errors.Is(err, io.EOF)
+
+		line = strings.TrimRight(line, "\r\n")
+		switch {
+		case line == "":
+			if err := dispatch(); err != nil {
+				if errors.Is(err, io.EOF) {
+					return nil
+				}
+				return err
+			}
+		case strings.HasPrefix(line, "data:"):
+			data = append(data, strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		}
+
+		if atEOF {
+			if err := dispatch(); err != nil && !errors.Is(err, io.EOF) {
+				return err
+			}
+			return nil
+		}
+	}
+}
+
+func newRequest(ctx context.Context, method, endpoint string, body []byte, headers map[string]string) (*http.Request, error) {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	return req, nil
 }
 
-func buildMessages(system, user string) []Message {
-	msgs := make([]Message, 0, 2)
+func buildMessages(system string, conversation []Message) []Message {
+	msgs := make([]Message, 0, len(conversation)+1)
 	if system != "" {
 		msgs = append(msgs, Message{Role: "system", Content: system})
 	}
-	msgs = append(msgs, Message{Role: "user", Content: user})
-	return msgs
+	return append(msgs, conversation...)
 }
@@ -0,0 +1,270 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadEvents(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []string
+	}{
+		{
+			name:  "single data line events",
+			input: "data: one\n\ndata: two\n\n",
+			want:  []string{"one", "two"},
+		},
+		{
+			name:  "joins multi-line data",
+			input: "data: one\ndata: two\n\n",
+			want:  []string{"one\ntwo"},
+		},
+		{
+			name:  "ignores comments and event fields",
+			input: ": keep-alive\n\nevent: ping\ndata: {}\n\n",
+			want:  []string{"{}"},
+		},
+		{
+			name:  "stops at DONE sentinel",
+			input: "data: one\n\ndata: [DONE]\n\ndata: two\n\n",
+			want:  []string{"one"},
+		},
+		{
+			name:  "handles CRLF line endings",
+			input: "data: one\r\n\r\n",
+			want:  []string{"one"},
+		},
+		{
+			name:  "dispatches trailing event without blank line",
+			input: "data: one",
+			want:  []string{"one"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			err := readEvents(strings.NewReader(tt.input), func(data []byte) error {
+				got = append(got, string(data))
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("readEvents() error = %v, want nil", err)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("readEvents() events = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadEvents_StopsOnCallbackError(t *testing.T) {
+	want := errors.New("write failed")
+	err := readEvents(strings.NewReader("data: one\n\ndata: two\n\n"), func(data []byte) error {
+		return want
+	})
+
+	if !errors.Is(err, want) {
+		t.Fatalf("readEvents() error = %v, want %v", err, want)
+	}
+}
+
+func newStreamServer(t *testing.T, events []string) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("decoding request body: %v", err)
+		}
+		if body["stream"] != true {
+			t.Errorf("request stream = %v, want true", body["stream"])
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, event := range events {
+			_, _ = fmt.Fprintf(w, "data: %s\n\n", event)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func TestStream(t *testing.T) {
+	tests := []struct {
+		name     string
+		events   []string
+		provider func(endpoint string) Provider
+		want     []string
+	}{
+		{
+			name: "openai chat completions",
+			events: []string{
+				`{"choices":[{"delta":{"role":"assistant"}}]}`,
+				`{"choices":[{"delta":{"content":"Hello"}}]}`,
+				`{"choices":[{"delta":{"content":", world"}}]}`,
+				`[DONE]`,
+			},
+			provider: func(endpoint string) Provider {
+				return NewOpenAIProvider(endpoint, "gpt-4o-mini", "key")
+			},
+			want: []string{"Hello", ", world"},
+		},
+		{
+			name: "openrouter chat completions",
+			events: []string{
+				`{"choices":[{"delta":{"content":"Hi"}}]}`,
+				`[DONE]`,
+			},
+			provider: func(endpoint string) Provider {
+				return NewOpenRouterProvider(endpoint, "anthropic/claude-haiku-4.5", "key")
+			},
+			want: []string{"Hi"},
+		},
+		{
+			name: "anthropic messages",
+			events: []string{
+				`{"type":"message_start","message":{}}`,
+				`{"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`,
+				`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Hello"}}`,
+				`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":", world"}}`,
+				`{"type":"message_stop"}`,
+			},
+			provider: func(endpoint string) Provider {
+				return NewAnthropicProvider(endpoint, "claude-haiku-4-5", "key")
+			},
+			want: []string{"Hello", ", world"},
+		},
+		{
+			name: "opencode zen messages",
+			events: []string{
+				`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Hi"}}`,
+				`{"type":"message_stop"}`,
+			},
+			provider: func(endpoint string) Provider {
+				return NewOpencodeZenProvider(endpoint, "claude-haiku-4-5", "key")
+			},
+			want: []string{"Hi"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newStreamServer(t, tt.events)
+
+			var got []string
+			resp, err := Stream(context.Background(), tt.provider(server.URL), "system", "hello", func(delta string) error {
+				got = append(got, delta)
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("Stream() error = %v, want nil", err)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Stream() deltas = %q, want %q", got, tt.want)
+			}
+
+			if resp.Text != strings.Join(tt.want, "") {
+				t.Errorf("Stream() resp.Text = %q, want %q", resp.Text, strings.Join(tt.want, ""))
+			}
+		})
+	}
+}
+
+func TestStream_ReturnsStreamErrors(t *testing.T) {
+	server := newStreamServer(t, []string{
+		`{"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}`,
+	})
+
+	provider := NewAnthropicProvider(server.URL, "claude-haiku-4-5", "key")
+	_, err := Stream(context.Background(), provider, "", "hello", func(string) error { return nil })
+
+	if err == nil || !strings.Contains(err.Error(), "Overloaded") {
+		t.Fatalf("Stream() error = %v, want to contain %q", err, "Overloaded")
+	}
+}
+
+func TestStream_TimesOutWhenServerGoesQuiet(t *testing.T) {
+	tests := []struct {
+		name   string
+		events []string
+		want   []string
+	}{
+		{
+			name: "no response",
+		},
+		{
+			name:   "no more events",
+			events: []string{`{"choices":[{"delta":{"content":"par"}}]}`},
+			want:   []string{"par"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			release := make(chan struct{})
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if len(tt.events) > 0 {
+					w.Header().Set("Content-Type", "text/event-stream")
+					for _, event := range tt.events {
+						_, _ = fmt.Fprintf(w, "data: %s\n\n", event)
+					}
+					w.(http.Flusher).Flush()
+				}
+				<-release
+			}))
+			t.Cleanup(server.Close)
+			t.Cleanup(func() { close(release) })
+
+			provider := NewOpenAIProvider(server.URL, "gpt-4o-mini", "key", WithTimeout(50*time.Millisecond), WithRetries(0))
+			var got []string
+			_, err := Stream(context.Background(), provider, "", "hello", func(delta string) error {
+				got = append(got, delta)
+				return nil
+			})
+
+			if !errors.Is(err, context.DeadlineExceeded) {
+				t.Fatalf("Stream() error = %v, want context.DeadlineExceeded", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Stream() deltas = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+type completeOnlyProvider struct {
+	resp string
+}
+
+func (c *completeOnlyProvider) Complete(ctx context.Context, system, userMsg string) (Response, error) {
+	return Response{Text: c.resp}, nil
+}
+
+func TestStream_FallsBackToComplete(t *testing.T) {
+	var got []string
+	resp, err := Stream(context.Background(), &completeOnlyProvider{resp: "whole answer"}, "", "hello", func(delta string) error {
+		got = append(got, delta)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Stream() error = %v, want nil", err)
+	}
+
+	if !reflect.DeepEqual(got, []string{"whole answer"}) || resp.Text != "whole answer" {
+		t.Errorf("Stream() = %q, deltas %q, want single delta %q", resp.Text, got, "whole answer")
+	}
+}
@@ -0,0 +1,71 @@
+package providers
+
+import "context"
+
+// LocalProvider talks to a self-hosted OpenAI-compatible server such as
+// Ollama, the llama.cpp server or vLLM. No data leaves the configured
+// endpoint and no API key is required.
+type LocalProvider struct {
+	endpoint string
+	model    string
+	apiKey   string
+	options
+}
+
+// NewLocalProvider returns a provider for an OpenAI-compatible
+// chat/completions endpoint. apiKey is optional and only sent when set.
+func NewLocalProvider(endpoint, model, apiKey string, opts ...Option) Provider {
+	return &LocalProvider{
+		endpoint: endpoint,
+		model:    model,
+		apiKey:   apiKey,
+		options:  newOptions(opts),
+	}
+}
+
+func (l *LocalProvider) Complete(ctx context.Context, system, userMsg string) (Response, error) {
+	return l.Chat(ctx, system, userTurn(userMsg))
+}
+
+func (l *LocalProvider) Chat(ctx context.Context, system string, msgs []Message) (Response, error) {
+	return withContinuations(l.options, msgs, func(msgs []Message) (Response, error) {
+		return completeOpenAI(ctx, l.endpoint, openaiRequest{
+			Model:       l.model,
+			Messages:    buildMessages(system, msgs),
+			MaxTokens:   l.maxTokens,
+			Temperature: l.temperature,
+		}, l.headers(), l.options, l.describe())
+	})
+}
+
+func (l *LocalProvider) Stream(ctx context.Context, system, userMsg string, onDelta func(string) error) (Response, error) {
+	return l.StreamChat(ctx, system, userTurn(userMsg), onDelta)
+}
+
+func (l *LocalProvider) StreamChat(ctx context.Context, system string, msgs []Message, onDelta func(string) error) (Response, error) {
+	return withContinuations(l.options, msgs, func(msgs []Message) (Response, error) {
+		return streamOpenAI(ctx, l.endpoint, openaiRequest{
+			Model:         l.model,
+			Messages:      buildMessages(system, msgs),
+			MaxTokens:     l.maxTokens,
+			Temperature:   l.temperature,
+			Stream:        true,
+			StreamOptions: &openaiStreamOptions{IncludeUsage: true},
+		}, l.headers(), l.options, l.describe(), onDelta)
+	})
+}
+
+func (l *LocalProvider) describe() Usage {
+	return Usage{Provider: localName, Model: l.model}
+}
+
+func (l *LocalProvider) cacheScope() string {
+	return l.options.cacheScope(l.endpoint, l.model)
+}
+
+func (l *LocalProvider) headers() map[string]string {
+	if l.apiKey == "" {
+		return nil
+	}
+	return map[string]string{"Authorization": "Bearer " + l.apiKey}
+}
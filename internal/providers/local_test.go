@@ -0,0 +1,102 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestLocalProviderComplete(t *testing.T) {
+	var gotReq openaiRequest
+	var gotAuth string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/chat/completions" {
+			t.Errorf("request path = %q, want %q", r.URL.Path, "/v1/chat/completions")
+		}
+		gotAuth = r.Header.Get("Authorization")
+		if err := json.NewDecoder(r.Body).Decode(&gotReq); err != nil {
+			t.Errorf("decoding request body: %v", err)
+		}
+
+		_, _ = w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"feat: add local provider"}}]}`))
+	}))
+	t.Cleanup(server.Close)
+
+	p := NewLocalProvider(server.URL+"/v1/chat/completions", "llama3.2", "")
+	resp, err := p.Complete(context.Background(), "system prompt", "diff")
+	if err != nil {
+		t.Fatalf("Complete() error = %v, want nil", err)
+	}
+
+	if resp.Text != "feat: add local provider" {
+		t.Errorf("Complete() = %q, want %q", resp.Text, "feat: add local provider")
+	}
+
+	if gotAuth != "" {
+		t.Errorf("Authorization header = %q, want none", gotAuth)
+	}
+
+	wantReq := openaiRequest{
+		Model: "llama3.2",
+		Messages: []Message{
+			{Role: "system", Content: "system prompt"},
+			{Role: "user", Content: "diff"},
+		},
+	}
+	if !reflect.DeepEqual(gotReq, wantReq) {
+		t.Errorf("request = %#v, want %#v", gotReq, wantReq)
+	}
+}
+
+func TestLocalProviderSendsOptionalAPIKey(t *testing.T) {
+	var gotAuth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotAuth = r.Header.Get("Authorization")
+		_, _ = w.Write([]byte(`{"choices":[{"message":{"content":"ok"}}]}`))
+	}))
+	t.Cleanup(server.Close)
+
+	p := NewLocalProvider(server.URL, "llama3.2", "secret")
+	if _, err := p.Complete(context.Background(), "", "hi"); err != nil {
+		t.Fatalf("Complete() error = %v, want nil", err)
+	}
+
+	if gotAuth != "Bearer secret" {
+		t.Errorf("Authorization header = %q, want %q", gotAuth, "Bearer secret")
+	}
+}
+
+func TestLocalProviderReturnsServerErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"error":{"message":"model \"llama9\" not found"}}`))
+	}))
+	t.Cleanup(server.Close)
+
+	p := NewLocalProvider(server.URL, "llama9", "")
+	_, err := p.Complete(context.Background(), "", "hi")
+	if err == nil || err.Error() != `model "llama9" not found` {
+		t.Fatalf("Complete() error = %v, want model not found", err)
+	}
+}
+
+func TestLocalProviderStream(t *testing.T) {
+	server := newStreamServer(t, []string{
+		`{"choices":[{"delta":{"content":"Hello"}}]}`,
+		`{"choices":[{"delta":{"content":" from Ollama"}}]}`,
+		`[DONE]`,
+	})
+
+	p := NewLocalProvider(server.URL, "llama3.2", "")
+	resp, err := Stream(context.Background(), p, "", "hi", func(string) error { return nil })
+	if err != nil {
+		t.Fatalf("Stream() error = %v, want nil", err)
+	}
+
+	if resp.Text != "Hello from Ollama" {
+		t.Errorf("Stream() = %q, want %q", resp.Text, "Hello from Ollama")
+	}
+}
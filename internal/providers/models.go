@@ -0,0 +1,88 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"llm/internal/config"
+)
+
+// ModelLister is implemented by providers that can list the models they
+// serve.
+type ModelLister interface {
+	Models(ctx context.Context) ([]string, error)
+}
+
+// ListModels returns the models served by p, sorted by name.
+func ListModels(ctx context.Context, p Provider) ([]string, error) {
+	l, ok := p.(ModelLister)
+	if !ok {
+		return nil, fmt.Errorf("provider %q cannot list its models", Describe(p).Provider)
+	}
+
+	models, err := l.Models(ctx)
+	if err != nil {
+		return nil, err
+	}
+	slices.Sort(models)
+	return models, nil
+}
+
+type openaiModelsResponse struct {
+	Data []struct {
+		ID string `json:"id"`
+	} `json:"data"`
+}
+
+// listOpenAIModels reads the /models endpoint next to an OpenAI-compatible
+// chat completions endpoint.
+func listOpenAIModels(ctx context.Context, endpoint string, headers map[string]string, o options) ([]string, error) {
+	var r openaiModelsResponse
+	if err := getJSON(ctx, modelsURL(endpoint), &r, headers, o); err != nil {
+		return nil, err
+	}
+
+	models := make([]string, 0, len(r.Data))
+	for _, m := range r.Data {
+		models = append(models, m.ID)
+	}
+	return models, nil
+}
+
+// modelsURL returns the models endpoint of the API that serves endpoint,
+// such as https://api.openai.com/v1/models for
+// https://api.openai.com/v1/chat/completions.
+func modelsURL(endpoint string) string {
+	return strings.TrimSuffix(strings.TrimSuffix(endpoint, "/"), "/chat/completions") + "/models"
+}
+
+func (o *OpenAIProvider) Models(ctx context.Context) ([]string, error) {
+	return listOpenAIModels(ctx, o.endpoint, map[string]string{"Authorization": "Bearer " + o.apiKey}, o.options)
+}
+
+func (o *OpenRouterProvider) Models(ctx context.Context) ([]string, error) {
+	return listOpenAIModels(ctx, o.endpoint, map[string]string{"Authorization": "Bearer " + o.apiKey}, o.options)
+}
+
+func (l *LocalProvider) Models(ctx context.Context) ([]string, error) {
+	return listOpenAIModels(ctx, l.endpoint, l.headers(), l.options)
+}
+
+// ResolveModels returns the models served by the provider Resolve picks
+// for s, ignoring fallbacks. No model needs to be configured, since none
+// is sent when listing models. The provider is passed through wrap, when
+// it is not nil, before it is asked.
+func ResolveModels(ctx context.Context, s config.Settings, wrap func(Provider) Provider, opts ...Option) ([]string, error) {
+	s.Fallback = nil
+	p, err := Resolve(s, io.Discard, append(slices.Clip(opts), WithoutModel())...)
+	if err != nil {
+		return nil, err
+	}
+	if wrap != nil {
+		p = wrap(p)
+	}
+	return ListModels(ctx, p)
+}
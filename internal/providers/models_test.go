@@ -0,0 +1,74 @@
+package providers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"llm/internal/config"
+)
+
+func TestModelsURL(t *testing.T) {
+	tests := []struct {
+		endpoint string
+		want     string
+	}{
+		{"https://api.openai.com/v1/chat/completions", "https://api.openai.com/v1/models"},
+		{"http://localhost:11434/v1/chat/completions/", "http://localhost:11434/v1/models"},
+		{"http://localhost:8080/v1", "http://localhost:8080/v1/models"},
+	}
+
+	for _, tt := range tests {
+		if got := modelsURL(tt.endpoint); got != tt.want {
+			t.Errorf("modelsURL(%q) = %q, want %q", tt.endpoint, got, tt.want)
+		}
+	}
+}
+
+func TestListModels(t *testing.T) {
+	var gotMethod, gotPath, gotAuth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotMethod, gotPath, gotAuth = r.Method, r.URL.Path, r.Header.Get("Authorization")
+		_, _ = w.Write([]byte(`{"object":"list","data":[{"id":"gpt-4o"},{"id":"gpt-4o-mini"},{"id":"chatgpt-4o-latest"}]}`))
+	}))
+	t.Cleanup(server.Close)
+
+	p := NewOpenAIProvider(server.URL+"/v1/chat/completions", "gpt-4o-mini", "key")
+	models, err := ListModels(context.Background(), p)
+	if err != nil {
+		t.Fatalf("ListModels() error = %v, want nil", err)
+	}
+
+	if want := []string{"chatgpt-4o-latest", "gpt-4o", "gpt-4o-mini"}; !reflect.DeepEqual(models, want) {
+		t.Errorf("ListModels() = %v, want %v", models, want)
+	}
+	if gotMethod != http.MethodGet || gotPath != "/v1/models" || gotAuth != "Bearer key" {
+		t.Errorf("request = %s %s with Authorization %q, want GET /v1/models with the key", gotMethod, gotPath, gotAuth)
+	}
+}
+
+func TestListModelsUnsupported(t *testing.T) {
+	p := NewAnthropicProvider("http://localhost", "claude-haiku-4-5", "key")
+	if _, err := ListModels(context.Background(), p); err == nil {
+		t.Error("ListModels(anthropic) error = nil, want an error")
+	}
+}
+
+func TestResolveModelsWithoutLocalModel(t *testing.T) {
+	clearAPIKeys(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"data":[{"id":"llama3.2"},{"id":"qwen2.5-coder"}]}`))
+	}))
+	t.Cleanup(server.Close)
+	t.Setenv("LLM_BASE_URL", server.URL+"/v1")
+
+	models, err := ResolveModels(context.Background(), config.Settings{}, nil)
+	if err != nil {
+		t.Fatalf("ResolveModels() error = %v, want nil", err)
+	}
+	if want := []string{"llama3.2", "qwen2.5-coder"}; !reflect.DeepEqual(models, want) {
+		t.Errorf("ResolveModels() = %v, want %v", models, want)
+	}
+}
@@ -1,21 +1,55 @@
 package providers
 
 import (
+	"cmp"
 	"context"
+	"encoding/json"
 	"fmt"
+	"strings"
 )
 
 type openaiRequest struct {
-	Model    string    `json:"model"`
-	Messages []Message `json:"messages"`
+	Model         string               `json:"model"`
+	Messages      []Message            `json:"messages"`
+	MaxTokens     int                  `json:"max_tokens,omitempty"`
+	Temperature   *float64             `json:"temperature,omitempty"`
+	Stream        bool                 `json:"stream,omitempty"`
+	StreamOptions *openaiStreamOptions `json:"stream_options,omitempty"`
+}
+
+type openaiStreamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+type openaiUsage struct {
+	PromptTokens     int     `json:"prompt_tokens"`
+	CompletionTokens int     `json:"completion_tokens"`
+	Cost             float64 `json:"cost"`
 }
 
 type openaiResponse struct {
+	Model   string `json:"model"`
 	Choices []struct {
 		Message struct {
 			Content string `json:"content"`
 		} `json:"message"`
+		FinishReason string `json:"finish_reason"`
+	} `json:"choices"`
+	Usage *openaiUsage `json:"usage,omitempty"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+}
+
+type openaiStreamChunk struct {
+	Model   string `json:"model"`
+	Choices []struct {
+		Delta struct {
+			Content string `json:"content"`
+		} `json:"delta"`
+		FinishReason string `json:"finish_reason"`
 	} `json:"choices"`
+	Usage *openaiUsage `json:"usage,omitempty"`
 	Error *struct {
 		Message string `json:"message"`
 	} `json:"error,omitempty"`
@@ -25,32 +59,140 @@ type OpenAIProvider struct {
 	endpoint string
 	model    string
 	apiKey   string
+	options
 }
 
-func NewOpenAIProvider(endpoint, model, apiKey string) Provider {
+func NewOpenAIProvider(endpoint, model, apiKey string, opts ...Option) Provider {
 	return &OpenAIProvider{
 		endpoint: endpoint,
 		model:    model,
 		apiKey:   apiKey,
+		options:  newOptions(opts),
 	}
 }
 
-func (o *OpenAIProvider) Complete(ctx context.Context, system, userMsg string) (string, error) {
+func (o *OpenAIProvider) Complete(ctx context.Context, system, userMsg string) (Response, error) {
+	return o.Chat(ctx, system, userTurn(userMsg))
+}
+
+func (o *OpenAIProvider) Chat(ctx context.Context, system string, msgs []Message) (Response, error) {
+	return withContinuations(o.options, msgs, func(msgs []Message) (Response, error) {
+		return completeOpenAI(ctx, o.endpoint, openaiRequest{
+			Model:       o.model,
+			Messages:    buildMessages(system, msgs),
+			MaxTokens:   o.maxTokens,
+			Temperature: o.temperature,
+		}, map[string]string{
+			"Authorization": "Bearer " + o.apiKey,
+		}, o.options, o.describe())
+	})
+}
+
+func (o *OpenAIProvider) Stream(ctx context.Context, system, userMsg string, onDelta func(string) error) (Response, error) {
+	return o.StreamChat(ctx, system, userTurn(userMsg), onDelta)
+}
+
+func (o *OpenAIProvider) StreamChat(ctx context.Context, system string, msgs []Message, onDelta func(string) error) (Response, error) {
+	return withContinuations(o.options, msgs, func(msgs []Message) (Response, error) {
+		return streamOpenAI(ctx, o.endpoint, openaiRequest{
+			Model:         o.model,
+			Messages:      buildMessages(system, msgs),
+			MaxTokens:     o.maxTokens,
+			Temperature:   o.temperature,
+			Stream:        true,
+			StreamOptions: &openaiStreamOptions{IncludeUsage: true},
+		}, map[string]string{
+			"Authorization": "Bearer " + o.apiKey,
+		}, o.options, o.describe(), onDelta)
+	})
+}
+
+func (o *OpenAIProvider) describe() Usage {
+	return Usage{Provider: "openai", Model: o.model}
+}
+
+func (o *OpenAIProvider) cacheScope() string {
+	return o.options.cacheScope(o.endpoint, o.model)
+}
+
+// completeOpenAI sends a chat/completions request and reads the first
+// choice and the reported usage. usage names the provider and configured
+// model; the model reported by the API takes precedence. A choice cut off
+// at the token limit is returned with a *TruncatedError. It is shared by
+// every provider that speaks the OpenAI wire format.
+func completeOpenAI(ctx context.Context, endpoint string, req any, headers map[string]string, o options, usage Usage) (Response, error) {
 	var r openaiResponse
-	if err := doJSONRequest(ctx, o.endpoint, openaiRequest{
-		Model:    o.model,
-		Messages: buildMessages(system, userMsg),
-	}, &r, map[string]string{
-		"Authorization": "Bearer " + o.apiKey,
-	}); err != nil {
-		return "", err
+	if err := doJSONRequest(ctx, endpoint, req, &r, headers, o); err != nil {
+		return Response{}, err
 	}
 
 	if r.Error != nil {
-		return "", fmt.Errorf("%s", r.Error.Message)
+		return Response{}, fmt.Errorf("%s", r.Error.Message)
+	}
+
+	resp := Response{Usage: usage.withOpenAI(r.Model, r.Usage)}
+	if len(r.Choices) == 0 {
+		return resp, nil
+	}
+
+	resp.Text = r.Choices[0].Message.Content
+	if r.Choices[0].FinishReason == "length" {
+		return resp, &TruncatedError{Response: resp}
+	}
+	return resp, nil
+}
+
+// streamOpenAI sends a chat/completions request with streaming enabled and
+// collects the content deltas. It is shared by every provider that speaks
+// the OpenAI wire format.
+func streamOpenAI(ctx context.Context, endpoint string, req any, headers map[string]string, o options, usage Usage, onDelta func(string) error) (Response, error) {
+	var text strings.Builder
+	truncated := false
+	err := doStreamRequest(ctx, endpoint, req, headers, o, func(data []byte) error {
+		var chunk openaiStreamChunk
+		if err := json.Unmarshal(data, &chunk); err != nil {
+			return fmt.Errorf("failed to parse stream event: %w", err)
+		}
+
+		if chunk.Error != nil {
+			return fmt.Errorf("%s", chunk.Error.Message)
+		}
+
+		// The usage chunk requested with include_usage has no choices.
+		usage = usage.withOpenAI(chunk.Model, chunk.Usage)
+
+		if len(chunk.Choices) == 0 {
+			return nil
+		}
+
+		choice := chunk.Choices[0]
+		if choice.FinishReason == "length" {
+			truncated = true
+		}
+		if choice.Delta.Content == "" {
+			return nil
+		}
+
+		text.WriteString(choice.Delta.Content)
+		return onDelta(choice.Delta.Content)
+	})
+	if err != nil {
+		return Response{}, err
+	}
+
+	resp := Response{Text: text.String(), Usage: usage}
+	if truncated {
+		return resp, &TruncatedError{Response: resp}
 	}
-	if len(r.Choices) > 0 {
-		return r.Choices[0].Message.Content, nil
+	return resp, nil
+}
+
+func (u Usage) withOpenAI(model string, usage *openaiUsage) Usage {
+	u.Model = cmp.Or(model, u.Model)
+	if usage != nil {
+		u.InputTokens = usage.PromptTokens
+		u.OutputTokens = usage.CompletionTokens
+		u.Cost = usage.Cost
 	}
-	return "", nil
+	return u
 }
@@ -2,58 +2,76 @@ package providers
 
 import (
 	"context"
-	"fmt"
 )
 
 type opencodeZenRequest struct {
-	Model     string    `json:"model"`
-	MaxTokens int       `json:"max_tokens"`
-	System    string    `json:"system,omitempty"`
-	Messages  []Message `json:"messages"`
-}
-
-type opencodeZenResponse struct {
-	Content []struct {
-		Text string `json:"text"`
-	} `json:"content"`
-	Error *struct {
-		Message string `json:"message"`
-	} `json:"error,omitempty"`
+	Model       string    `json:"model"`
+	MaxTokens   int       `json:"max_tokens"`
+	System      string    `json:"system,omitempty"`
+	Messages    []Message `json:"messages"`
+	Temperature *float64  `json:"temperature,omitempty"`
+	Stream      bool      `json:"stream,omitempty"`
 }
 
 type OpencodeZenProvider struct {
 	endpoint string
 	model    string
 	apiKey   string
+	options
 }
 
-func NewOpencodeZenProvider(endpoint, model, apiKey string) Provider {
+func NewOpencodeZenProvider(endpoint, model, apiKey string, opts ...Option) Provider {
 	return &OpencodeZenProvider{
 		endpoint: endpoint,
 		model:    model,
 		apiKey:   apiKey,
+		options:  newOptions(opts),
 	}
 }
 
-func (o *OpencodeZenProvider) Complete(ctx context.Context, system, userMsg string) (string, error) {
-	var r opencodeZenResponse
-	if err := doJSONRequest(ctx, o.endpoint, opencodeZenRequest{
-		Model:     o.model,
-		MaxTokens: 4096,
-		System:    system,
-		Messages:  []Message{{Role: "user", Content: userMsg}},
-	}, &r, map[string]string{
-		"x-api-key":         o.apiKey,
-		"anthropic-version": "2023-06-01",
-	}); err != nil {
-		return "", err
-	}
+func (o *OpencodeZenProvider) Complete(ctx context.Context, system, userMsg string) (Response, error) {
+	return o.Chat(ctx, system, userTurn(userMsg))
+}
 
-	if r.Error != nil {
-		return "", fmt.Errorf("%s", r.Error.Message)
-	}
-	if len(r.Content) > 0 {
-		return r.Content[0].Text, nil
-	}
-	return "", nil
+func (o *OpencodeZenProvider) Chat(ctx context.Context, system string, msgs []Message) (Response, error) {
+	return withContinuations(o.options, msgs, func(msgs []Message) (Response, error) {
+		return completeAnthropic(ctx, o.endpoint, opencodeZenRequest{
+			Model:       o.model,
+			MaxTokens:   o.maxTokensOrDefault(),
+			System:      system,
+			Messages:    msgs,
+			Temperature: o.temperature,
+		}, map[string]string{
+			"x-api-key":         o.apiKey,
+			"anthropic-version": "2023-06-01",
+		}, o.options, o.describe())
+	})
+}
+
+func (o *OpencodeZenProvider) Stream(ctx context.Context, system, userMsg string, onDelta func(string) error) (Response, error) {
+	return o.StreamChat(ctx, system, userTurn(userMsg), onDelta)
+}
+
+func (o *OpencodeZenProvider) StreamChat(ctx context.Context, system string, msgs []Message, onDelta func(string) error) (Response, error) {
+	return withContinuations(o.options, msgs, func(msgs []Message) (Response, error) {
+		return streamAnthropic(ctx, o.endpoint, opencodeZenRequest{
+			Model:       o.model,
+			MaxTokens:   o.maxTokensOrDefault(),
+			System:      system,
+			Messages:    msgs,
+			Temperature: o.temperature,
+			Stream:      true,
+		}, map[string]string{
+			"x-api-key":         o.apiKey,
+			"anthropic-version": "2023-06-01",
+		}, o.options, o.describe(), onDelta)
+	})
+}
+
+func (o *OpencodeZenProvider) describe() Usage {
+	return Usage{Provider: "opencode-zen", Model: o.model}
+}
+
+func (o *OpencodeZenProvider) cacheScope() string {
+	return o.options.cacheScope(o.endpoint, o.model)
 }
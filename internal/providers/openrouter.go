@@ -2,44 +2,78 @@ package providers
 
 import (
 	"context"
-	"fmt"
 )
 
 type openrouterRequest struct {
-	Model    string    `json:"model"`
-	Messages []Message `json:"messages"`
+	Model       string                    `json:"model"`
+	Messages    []Message                 `json:"messages"`
+	MaxTokens   int                       `json:"max_tokens,omitempty"`
+	Temperature *float64                  `json:"temperature,omitempty"`
+	Stream      bool                      `json:"stream,omitempty"`
+	Usage       openrouterUsageAccounting `json:"usage"`
+}
+
+// openrouterUsageAccounting asks OpenRouter to report token counts and
+// cost in the response, including the final chunk of a stream.
+type openrouterUsageAccounting struct {
+	Include bool `json:"include"`
 }
 
 type OpenRouterProvider struct {
 	endpoint string
 	model    string
 	apiKey   string
+	options
 }
 
-func NewOpenRouterProvider(endpoint, model, apiKey string) Provider {
+func NewOpenRouterProvider(endpoint, model, apiKey string, opts ...Option) Provider {
 	return &OpenRouterProvider{
 		endpoint: endpoint,
 		model:    model,
 		apiKey:   apiKey,
+		options:  newOptions(opts),
 	}
 }
 
-func (o *OpenRouterProvider) Complete(ctx context.Context, system, userMsg string) (string, error) {
-	var r openaiResponse
-	if err := doJSONRequest(ctx, o.endpoint, openrouterRequest{
-		Model:    o.model,
-		Messages: buildMessages(system, userMsg),
-	}, &r, map[string]string{
-		"Authorization": "Bearer " + o.apiKey,
-	}); err != nil {
-		return "", err
-	}
+func (o *OpenRouterProvider) Complete(ctx context.Context, system, userMsg string) (Response, error) {
+	return o.Chat(ctx, system, userTurn(userMsg))
+}
 
-	if r.Error != nil {
-		return "", fmt.Errorf("%s", r.Error.Message)
-	}
-	if len(r.Choices) > 0 {
-		return r.Choices[0].Message.Content, nil
+func (o *OpenRouterProvider) Chat(ctx context.Context, system string, msgs []Message) (Response, error) {
+	return withContinuations(o.options, msgs, func(msgs []Message) (Response, error) {
+		return completeOpenAI(ctx, o.endpoint, o.request(system, msgs, false), map[string]string{
+			"Authorization": "Bearer " + o.apiKey,
+		}, o.options, o.describe())
+	})
+}
+
+func (o *OpenRouterProvider) Stream(ctx context.Context, system, userMsg string, onDelta func(string) error) (Response, error) {
+	return o.StreamChat(ctx, system, userTurn(userMsg), onDelta)
+}
+
+func (o *OpenRouterProvider) StreamChat(ctx context.Context, system string, msgs []Message, onDelta func(string) error) (Response, error) {
+	return withContinuations(o.options, msgs, func(msgs []Message) (Response, error) {
+		return streamOpenAI(ctx, o.endpoint, o.request(system, msgs, true), map[string]string{
+			"Authorization": "Bearer " + o.apiKey,
+		}, o.options, o.describe(), onDelta)
+	})
+}
+
+func (o *OpenRouterProvider) describe() Usage {
+	return Usage{Provider: "openrouter", Model: o.model}
+}
+
+func (o *OpenRouterProvider) cacheScope() string {
+	return o.options.cacheScope(o.endpoint, o.model)
+}
+
+func (o *OpenRouterProvider) request(system string, msgs []Message, stream bool) openrouterRequest {
+	return openrouterRequest{
+		Model:       o.model,
+		Messages:    buildMessages(system, msgs),
+		MaxTokens:   o.maxTokens,
+		Temperature: o.temperature,
+		Stream:      stream,
+		Usage:       openrouterUsageAccounting{Include: true},
 	}
-	return "", nil
 }
@@ -0,0 +1,132 @@
+package providers
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// defaultMaxTokens is sent to APIs that require an output limit when none
+// is configured.
+const defaultMaxTokens = 4096
+
+// Option configures request parameters shared by all providers.
+type Option func(*options)
+
+type options struct {
+	maxTokens     int
+	temperature   *float64
+	timeout       time.Duration
+	retries       *int
+	maxRetryDelay time.Duration
+	// maxContinuations is how many follow-up requests may be sent to
+	// complete a truncated answer.
+	maxContinuations int
+	// transport sends requests when set, replacing the shared
+	// defaultTransport.
+	transport http.RoundTripper
+	// debug receives a log of every request when set.
+	debug io.Writer
+	// noModel lets a provider be resolved without a model.
+	noModel bool
+}
+
+// WithMaxTokens limits the number of tokens the model may generate.
+func WithMaxTokens(n int) Option {
+	return func(o *options) { o.maxTokens = n }
+}
+
+// WithTemperature sets the sampling temperature.
+func WithTemperature(t float64) Option {
+	return func(o *options) { o.temperature = &t }
+}
+
+// WithTimeout bounds each blocking request, and how long a stream waits
+// for the response or for more data. Zero keeps the default.
+func WithTimeout(d time.Duration) Option {
+	return func(o *options) { o.timeout = d }
+}
+
+// WithRetries sets how many times a failed request is retried. Zero
+// disables retries.
+func WithRetries(n int) Option {
+	return func(o *options) { o.retries = &n }
+}
+
+// WithMaxRetryDelay caps the delay between retries.
+func WithMaxRetryDelay(d time.Duration) Option {
+	return func(o *options) { o.maxRetryDelay = d }
+}
+
+// WithMaxContinuations lets a provider ask up to n times for the rest of
+// an answer cut off at the output token limit. Zero, the default, returns
+// a *TruncatedError instead.
+func WithMaxContinuations(n int) Option {
+	return func(o *options) { o.maxContinuations = n }
+}
+
+// WithTransport sends requests through rt, such as one returned by
+// NewRecordingTransport or NewReplayTransport.
+func WithTransport(rt http.RoundTripper) Option {
+	return func(o *options) { o.transport = rt }
+}
+
+// WithoutModel lets Resolve return a provider that has no model, for
+// requests that send none such as listing models. Completions must not be
+// requested from it.
+func WithoutModel() Option {
+	return func(o *options) { o.noModel = true }
+}
+
+func newOptions(opts []Option) options {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// maxTokensOrDefault returns the configured limit, falling back to
+// defaultMaxTokens for APIs where the field is mandatory.
+func (o options) maxTokensOrDefault() int {
+	if o.maxTokens > 0 {
+		return o.maxTokens
+	}
+	return defaultMaxTokens
+}
+
+func (o options) transportOrDefault() http.RoundTripper {
+	transport := o.transport
+	if transport == nil {
+		transport = defaultTransport
+	}
+	if o.debug != nil {
+		return newDebugTransport(transport, o.debug)
+	}
+	return transport
+}
+
+func (o options) retriesOrDefault() int {
+	if o.retries != nil {
+		return max(*o.retries, 0)
+	}
+	return defaultRetries
+}
+
+func (o options) maxRetryDelayOrDefault() time.Duration {
+	if o.maxRetryDelay > 0 {
+		return o.maxRetryDelay
+	}
+	return defaultMaxRetryDelay
+}
+
+// cacheScope identifies the requests sent to model at endpoint with these
+// options, which determine the response along with the prompt.
+func (o options) cacheScope(endpoint, model string) string {
+	temperature := "default"
+	if o.temperature != nil {
+		temperature = fmt.Sprint(*o.temperature)
+	}
+	return fmt.Sprintf("%s\n%s\n%d\n%s\n%d", endpoint, model, o.maxTokens, temperature, o.maxContinuations)
+}
@@ -1,15 +1,153 @@
 package providers
 
-import "context"
+import (
+	"context"
+	"errors"
+	"fmt"
+)
 
 // Provider defines the strategy interface for LLM chat completions.
 // Each provider implementation encapsulates its own configuration
 // (endpoint, model, API key) and handles the complete request lifecycle.
 type Provider interface {
-	Complete(ctx context.Context, system, userMsg string) (string, error)
+	Complete(ctx context.Context, system, userMsg string) (Response, error)
 }
 
+// Streamer is implemented by providers that can deliver a completion
+// incrementally. onDelta is called with each text fragment as it arrives
+// and the full response is returned once the stream ends.
+type Streamer interface {
+	Stream(ctx context.Context, system, userMsg string, onDelta func(string) error) (Response, error)
+}
+
+// Chatter is implemented by providers that can continue a conversation.
+// msgs alternate between user and assistant turns and end with a user
+// turn; the system prompt is passed separately.
+type Chatter interface {
+	Chat(ctx context.Context, system string, msgs []Message) (Response, error)
+}
+
+// ChatStreamer is implemented by providers that can stream the answer to a
+// conversation, calling onDelta as Streamer does.
+type ChatStreamer interface {
+	StreamChat(ctx context.Context, system string, msgs []Message, onDelta func(string) error) (Response, error)
+}
+
+// Message is one turn of a conversation. Role is "user" or "assistant",
+// or "system" in requests to APIs that take the system prompt as a
+// message.
 type Message struct {
 	Role    string `json:"role"`
 	Content string `json:"content"`
 }
+
+// Response is the result of a completion.
+type Response struct {
+	Text  string
+	Usage Usage
+	// Cached is set when the response was served from a ResponseCache
+	// without calling the provider.
+	Cached bool
+}
+
+// Stream completes the prompt with p, passing fragments to onDelta as they
+// arrive when p implements Streamer. Other providers fall back to Complete
+// and onDelta receives the whole response at once.
+func Stream(ctx context.Context, p Provider, system, userMsg string, onDelta func(string) error) (Response, error) {
+	if s, ok := p.(Streamer); ok {
+		return s.Stream(ctx, system, userMsg, onDelta)
+	}
+
+	resp, err := p.Complete(ctx, system, userMsg)
+	if err != nil && !errors.Is(err, ErrTruncated) {
+		return Response{}, err
+	}
+
+	// A truncated answer is still shown before its error is returned.
+	if resp.Text != "" {
+		if err := onDelta(resp.Text); err != nil {
+			return Response{}, err
+		}
+	}
+
+	return resp, err
+}
+
+// Chat answers the conversation msgs with p. Providers that do not
+// implement Chatter can only answer a single user turn, which is sent with
+// Complete.
+func Chat(ctx context.Context, p Provider, system string, msgs []Message) (Response, error) {
+	if err := checkConversation(msgs); err != nil {
+		return Response{}, err
+	}
+	if c, ok := p.(Chatter); ok {
+		return c.Chat(ctx, system, msgs)
+	}
+
+	userMsg, err := singleTurn(p, msgs)
+	if err != nil {
+		return Response{}, err
+	}
+	return p.Complete(ctx, system, userMsg)
+}
+
+// StreamChat answers the conversation msgs with p, passing fragments to
+// onDelta as they arrive when p implements ChatStreamer. Other providers
+// fall back to Chat, or to Stream for a single user turn.
+func StreamChat(ctx context.Context, p Provider, system string, msgs []Message, onDelta func(string) error) (Response, error) {
+	if err := checkConversation(msgs); err != nil {
+		return Response{}, err
+	}
+	if s, ok := p.(ChatStreamer); ok {
+		return s.StreamChat(ctx, system, msgs, onDelta)
+	}
+
+	if _, ok := p.(Chatter); !ok {
+		userMsg, err := singleTurn(p, msgs)
+		if err != nil {
+			return Response{}, err
+		}
+		return Stream(ctx, p, system, userMsg, onDelta)
+	}
+
+	resp, err := Chat(ctx, p, system, msgs)
+	if err != nil && !errors.Is(err, ErrTruncated) {
+		return Response{}, err
+	}
+	if resp.Text != "" {
+		if err := onDelta(resp.Text); err != nil {
+			return Response{}, err
+		}
+	}
+	return resp, err
+}
+
+// checkConversation rejects conversations that no API accepts.
+func checkConversation(msgs []Message) error {
+	if len(msgs) == 0 {
+		return fmt.Errorf("conversation has no messages")
+	}
+	for _, msg := range msgs {
+		if msg.Role != "user" && msg.Role != "assistant" {
+			return fmt.Errorf("conversation has a message with role %q; pass the system prompt separately", msg.Role)
+		}
+	}
+	if msgs[len(msgs)-1].Role != "user" {
+		return fmt.Errorf("conversation must end with a user message")
+	}
+	return nil
+}
+
+// singleTurn returns the only message of msgs for providers that cannot
+// take a conversation.
+func singleTurn(p Provider, msgs []Message) (string, error) {
+	if len(msgs) != 1 {
+		return "", fmt.Errorf("provider %q cannot continue a conversation", Describe(p).Provider)
+	}
+	return msgs[0].Content, nil
+}
+
+// userTurn is the conversation sent by Complete and Stream.
+func userTurn(userMsg string) []Message {
+	return []Message{{Role: "user", Content: userMsg}}
+}
@@ -0,0 +1,282 @@
+package providers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ErrNotRecorded is returned by a replay transport for a request that has
+// no golden file. It is never retried.
+var ErrNotRecorded = errors.New("no recorded response")
+
+// recordedHeaders are the response headers kept in golden files. Others,
+// such as cookies and request IDs, are dropped.
+var recordedHeaders = []string{"Content-Type", "Retry-After"}
+
+// exchange is a request and its response as stored in a golden file.
+// Request headers are not stored so that API keys never reach the disk.
+type exchange struct {
+	Request struct {
+		Method string          `json:"method"`
+		URL    string          `json:"url"`
+		Body   json.RawMessage `json:"body,omitempty"`
+	} `json:"request"`
+	Response struct {
+		Status int               `json:"status"`
+		Header map[string]string `json:"header,omitempty"`
+		Body   json.RawMessage   `json:"body,omitempty"`
+	} `json:"response"`
+}
+
+// NewRecordingTransport returns a transport that sends requests through
+// next, or the shared transport when next is nil, and saves every
+// exchange as a golden file in dir for NewReplayTransport.
+func NewRecordingTransport(dir string, next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = defaultTransport
+	}
+	return &recorder{dir: dir, next: next}
+}
+
+type recorder struct {
+	dir  string
+	next http.RoundTripper
+	mu   sync.Mutex
+}
+
+func (r *recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	reqBody, err := readBody(req)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := r.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	respBody, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+
+	var x exchange
+	x.Request.Method = req.Method
+	x.Request.URL = req.URL.String()
+	x.Request.Body = rawBody(reqBody)
+	x.Response.Status = resp.StatusCode
+	x.Response.Body = rawBody(respBody)
+	for _, key := range recordedHeaders {
+		if value := resp.Header.Get(key); value != "" {
+			if x.Response.Header == nil {
+				x.Response.Header = make(map[string]string)
+			}
+			x.Response.Header[key] = value
+		}
+	}
+
+	if err := r.save(x); err != nil {
+		return nil, fmt.Errorf("recording %s: %w", req.URL, err)
+	}
+	return resp, nil
+}
+
+// save writes x to a file named after a hash of the request. Repeated
+// requests get increasing suffixes so that they replay in order.
+func (r *recorder) save(x exchange) error {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(x); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := os.MkdirAll(r.dir, 0o755); err != nil {
+		return err
+	}
+
+	sum := sha256.Sum256([]byte(x.Request.Method + "\n" + x.Request.URL + "\n" + string(canonicalBody(x.Request.Body))))
+	prefix := hex.EncodeToString(sum[:6])
+	for n := 1; ; n++ {
+		path := filepath.Join(r.dir, prefix+"-"+strconv.Itoa(n)+".json")
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+		if errors.Is(err, fs.ErrExist) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		if _, err := f.Write(buf.Bytes()); err != nil {
+			_ = f.Close()
+			return err
+		}
+		return f.Close()
+	}
+}
+
+// NewReplayTransport returns a transport that answers requests from the
+// golden files in dir without touching the network. A request matches a
+// file when its method, URL and body are the same; JSON bodies are
+// compared by value, so hand-written files may be formatted freely. Each
+// file answers once, in file name order, and unmatched requests fail with
+// ErrNotRecorded.
+func NewReplayTransport(dir string) (http.RoundTripper, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	slices.Sort(paths)
+
+	r := &replayer{dir: dir}
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		var x exchange
+		if err := json.Unmarshal(data, &x); err != nil {
+			return nil, fmt.Errorf("parsing %s: %w", path, err)
+		}
+		r.exchanges = append(r.exchanges, x)
+	}
+
+	return r, nil
+}
+
+type replayer struct {
+	dir       string
+	mu        sync.Mutex
+	exchanges []exchange
+	used      []bool
+}
+
+func (r *replayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := readBody(req)
+	if err != nil {
+		return nil, err
+	}
+
+	x, ok := r.take(req.Method, req.URL.String(), canonicalBody(rawBody(body)))
+	if !ok {
+		return nil, fmt.Errorf("%w for %s %s in %s", ErrNotRecorded, req.Method, req.URL, r.dir)
+	}
+
+	resp := &http.Response{
+		Status:        fmt.Sprintf("%d %s", x.Response.Status, http.StatusText(x.Response.Status)),
+		StatusCode:    x.Response.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        make(http.Header),
+		Body:          io.NopCloser(bytes.NewReader(bodyBytes(x.Response.Body))),
+		ContentLength: -1,
+		Request:       req,
+	}
+	for key, value := range x.Response.Header {
+		resp.Header.Set(key, value)
+	}
+
+	return resp, nil
+}
+
+// take returns the first unused exchange matching the request and marks
+// it used.
+func (r *replayer) take(method, url string, body []byte) (exchange, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.used == nil {
+		r.used = make([]bool, len(r.exchanges))
+	}
+
+	for i, x := range r.exchanges {
+		if r.used[i] || x.Request.Method != method || x.Request.URL != url {
+			continue
+		}
+		if !bytes.Equal(canonicalBody(x.Request.Body), body) {
+			continue
+		}
+
+		r.used[i] = true
+		return x, true
+	}
+
+	return exchange{}, false
+}
+
+// readBody returns the body of req and replaces it so that the request
+// can still be sent.
+func readBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil {
+		return nil, nil
+	}
+
+	body, err := io.ReadAll(req.Body)
+	_ = req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	return body, nil
+}
+
+// rawBody stores a JSON body as is, so golden files stay readable, and
+// anything else, such as a stream of server-sent events, as a JSON string.
+func rawBody(body []byte) json.RawMessage {
+	if len(body) == 0 {
+		return nil
+	}
+
+	trimmed := bytes.TrimSpace(body)
+	if json.Valid(trimmed) && !bytes.HasPrefix(trimmed, []byte(`"`)) {
+		return trimmed
+	}
+
+	quoted, _ := json.Marshal(string(body))
+	return quoted
+}
+
+// bodyBytes reverses rawBody.
+func bodyBytes(raw json.RawMessage) []byte {
+	var s string
+	if strings.HasPrefix(string(raw), `"`) && json.Unmarshal(raw, &s) == nil {
+		return []byte(s)
+	}
+	return raw
+}
+
+// canonicalBody re-encodes a JSON body so that equal values compare equal
+// regardless of formatting and key order.
+func canonicalBody(raw json.RawMessage) []byte {
+	var v any
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return raw
+	}
+
+	canonical, err := json.Marshal(v)
+	if err != nil {
+		return raw
+	}
+	return canonical
+}
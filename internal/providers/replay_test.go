@@ -0,0 +1,188 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"llm/internal/config"
+)
+
+const (
+	replaySystem = "Write a commit message for the staged diff."
+	replayDiff   = "diff --git a/README.md b/README.md\n+Usage notes"
+)
+
+func TestReplayProviders(t *testing.T) {
+	tests := []struct {
+		name     string
+		dir      string
+		provider func(opts ...Option) Provider
+		stream   bool
+		want     Response
+		wantErr  string
+	}{
+		{
+			name: "openai complete",
+			dir:  "openai",
+			provider: func(opts ...Option) Provider {
+				return NewOpenAIProvider("https://api.openai.com/v1/chat/completions", "gpt-4o-mini", "key", opts...)
+			},
+			want: Response{
+				Text:  "Document usage in the README",
+				Usage: Usage{Provider: "openai", Model: "gpt-4o-mini-2024-07-18", InputTokens: 42, OutputTokens: 7},
+			},
+		},
+		{
+			name: "openai stream",
+			dir:  "openai",
+			provider: func(opts ...Option) Provider {
+				return NewOpenAIProvider("https://api.openai.com/v1/chat/completions", "gpt-4o-mini", "key", opts...)
+			},
+			stream: true,
+			want: Response{
+				Text:  "Document usage in the README",
+				Usage: Usage{Provider: "openai", Model: "gpt-4o-mini", InputTokens: 42, OutputTokens: 7},
+			},
+		},
+		{
+			name: "anthropic complete",
+			dir:  "anthropic",
+			provider: func(opts ...Option) Provider {
+				return NewAnthropicProvider("https://api.anthropic.com/v1/messages", "claude-haiku-4-5", "key", opts...)
+			},
+			want: Response{
+				Text:  "Document usage in the README",
+				Usage: Usage{Provider: "anthropic", Model: "claude-haiku-4-5-20251001", InputTokens: 40, OutputTokens: 8},
+			},
+		},
+		{
+			name: "anthropic unauthorized",
+			dir:  "anthropic-unauthorized",
+			provider: func(opts ...Option) Provider {
+				return NewAnthropicProvider("https://api.anthropic.com/v1/messages", "claude-haiku-4-5", "key", opts...)
+			},
+			wantErr: "401 Unauthorized: invalid x-api-key",
+		},
+		{
+			name: "gemini complete",
+			dir:  "gemini",
+			provider: func(opts ...Option) Provider {
+				return NewGeminiProvider("https://generativelanguage.googleapis.com/v1beta/models", "gemini-2.5-flash", "key", opts...)
+			},
+			want: Response{
+				Text:  "Document usage in the README",
+				Usage: Usage{Provider: "gemini", Model: "gemini-2.5-flash", InputTokens: 38, OutputTokens: 6},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rt, err := NewReplayTransport(filepath.Join("testdata", "replay", tt.dir))
+			if err != nil {
+				t.Fatalf("NewReplayTransport() error = %v, want nil", err)
+			}
+			p := tt.provider(WithTransport(rt))
+
+			var got Response
+			if tt.stream {
+				got, err = Stream(context.Background(), p, replaySystem, replayDiff, func(string) error { return nil })
+			} else {
+				got, err = p.Complete(context.Background(), replaySystem, replayDiff)
+			}
+
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("error = %v, want nil", err)
+			}
+			if got != tt.want {
+				t.Errorf("response = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRecordThenReplay(t *testing.T) {
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Set-Cookie", "session=secret")
+		_, _ = fmt.Fprintf(w, `{"choices":[{"message":{"content":"answer %d"}}]}`, requests)
+	}))
+	t.Cleanup(server.Close)
+
+	dir := t.TempDir()
+	recording := NewOpenAIProvider(server.URL, "gpt-4o-mini", "sk-secret", WithTransport(NewRecordingTransport(dir, nil)))
+	for range 2 {
+		if _, err := recording.Complete(context.Background(), "", "hi"); err != nil {
+			t.Fatalf("recording Complete() error = %v, want nil", err)
+		}
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+	if len(files) != 2 {
+		t.Fatalf("recorded %d golden files, want 2", len(files))
+	}
+	for _, file := range files {
+		data, _ := os.ReadFile(file)
+		if strings.Contains(string(data), "secret") {
+			t.Errorf("%s contains a secret:\n%s", file, data)
+		}
+	}
+
+	server.Close()
+	rt, err := NewReplayTransport(dir)
+	if err != nil {
+		t.Fatalf("NewReplayTransport() error = %v, want nil", err)
+	}
+	replaying := NewOpenAIProvider(server.URL, "gpt-4o-mini", "", WithTransport(rt))
+
+	for i := 1; i <= 2; i++ {
+		resp, err := replaying.Complete(context.Background(), "", "hi")
+		if want := fmt.Sprintf("answer %d", i); err != nil || resp.Text != want {
+			t.Errorf("replay %d = %q, %v, want %q", i, resp.Text, err, want)
+		}
+	}
+
+	_, err = replaying.Complete(context.Background(), "", "hi")
+	if !errors.Is(err, ErrNotRecorded) || IsRetryable(err) {
+		t.Errorf("replay past the recording error = %v, want non-retryable ErrNotRecorded", err)
+	}
+
+	_, err = replaying.Complete(context.Background(), "", "something else")
+	if !errors.Is(err, ErrNotRecorded) {
+		t.Errorf("replay of unrecorded request error = %v, want ErrNotRecorded", err)
+	}
+}
+
+func TestResolveReplay(t *testing.T) {
+	clearAPIKeys(t)
+	t.Setenv("LLM_REPLAY", filepath.Join("testdata", "replay", "anthropic"))
+
+	p, err := Resolve(config.Settings{Provider: "anthropic"}, nil)
+	if err != nil {
+		t.Fatalf("Resolve() error = %v, want nil without an API key", err)
+	}
+
+	resp, err := p.Complete(context.Background(), replaySystem, replayDiff)
+	if err != nil || resp.Text != "Document usage in the README" {
+		t.Errorf("Complete() = %q, %v, want the recorded answer", resp.Text, err)
+	}
+
+	t.Setenv("LLM_RECORD", t.TempDir())
+	if _, err := Resolve(config.Settings{Provider: "anthropic"}, nil); err == nil {
+		t.Error("Resolve() with LLM_RECORD and LLM_REPLAY error = nil, want error")
+	}
+}
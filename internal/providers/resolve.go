@@ -1,45 +1,454 @@
 package providers
 
 import (
+	"cmp"
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"strings"
+
+	"llm/internal/config"
+	"llm/internal/credentials"
 )
 
+// backend describes a provider that can be selected by name or by the
+// presence of its API key in the environment. Backends without a fixed
+// endpoint or model read them from endpointEnv and modelEnv instead.
+type backend struct {
+	name        string
+	envKey      string
+	endpoint    string
+	endpointEnv string
+	model       string
+	modelEnv    string
+	build       func(endpoint, model, apiKey string, opts ...Option) Provider
+}
+
+// backends lists the known providers in order of precedence.
+var backends = []backend{
+	{
+		name:     "openrouter",
+		envKey:   "OPENROUTER_API_KEY",
+		endpoint: "https://openrouter.ai/api/v1/chat/completions",
+		model:    "anthropic/claude-haiku-4.5",
+		build:    NewOpenRouterProvider,
+	},
+	{
+		name:     "opencode-zen",
+		envKey:   "OPENCODE_ZEN_API_KEY",
+		endpoint: "https://opencode.ai/zen/v1/messages",
+		model:    "claude-haiku-4-5",
+		build:    NewOpencodeZenProvider,
+	},
+	{
+		name:     "anthropic",
+		envKey:   "ANTHROPIC_API_KEY",
+		endpoint: "https://api.anthropic.com/v1/messages",
+		model:    "claude-haiku-4-5",
+		build:    NewAnthropicProvider,
+	},
+	{
+		name:     "openai",
+		envKey:   "OPENAI_API_KEY",
+		endpoint: "https://api.openai.com/v1/chat/completions",
+		model:    "gpt-4o-mini",
+		build:    NewOpenAIProvider,
+	},
+	{
+		name:        "azure",
+		envKey:      "AZURE_OPENAI_API_KEY",
+		endpointEnv: "AZURE_OPENAI_ENDPOINT",
+		modelEnv:    "AZURE_OPENAI_DEPLOYMENT",
+		build:       newAzureOpenAIProviderFromEnv,
+	},
+	{
+		name:     "gemini",
+		envKey:   "GEMINI_API_KEY",
+		endpoint: "https://generativelanguage.googleapis.com/v1beta/models",
+		model:    "gemini-2.5-flash",
+		build:    NewGeminiProvider,
+	},
+}
+
+// localName selects a self-hosted OpenAI-compatible server. It is chosen
+// automatically when LLM_BASE_URL is set, ahead of every hosted provider,
+// so that a configured local server is never bypassed for one in the cloud.
+const localName = "local"
+
 // ResolveByAPIKey checks environment variables in order of precedence and returns
 // a fully configured provider ready to make API calls.
-// Priority: OPENROUTER_API_KEY > OPENCODE_ZEN_API_KEY > ANTHROPIC_API_KEY > OPENAI_API_KEY
+// Priority: LLM_BASE_URL > OPENROUTER_API_KEY > OPENCODE_ZEN_API_KEY > ANTHROPIC_API_KEY > OPENAI_API_KEY >
+// AZURE_OPENAI_API_KEY > GEMINI_API_KEY. When none is set, keys stored in
+// the keyring are tried in the same order.
 func ResolveByAPIKey() (Provider, error) {
-	if apiKey := os.Getenv("OPENROUTER_API_KEY"); apiKey != "" {
-		return NewOpenRouterProvider(
-			"https://openrouter.ai/api/v1/chat/completions",
-			"anthropic/claude-haiku-4.5",
-			apiKey,
-		), nil
+	return Resolve(config.Settings{}, io.Discard)
+}
+
+// Resolve returns a provider configured from s and the extra options. When
+// s names a provider, its API key must be set; otherwise the provider is
+// picked as in ResolveByAPIKey. Endpoint and model fall back to the
+// provider defaults.
+// When s lists fallback providers, the result tries them in order after
+// the primary one and reports fallbacks to stderr. Fallbacks that cannot be
+// resolved, such as those without a key, are left out with a warning.
+//
+// LLM_RECORD=dir saves every provider exchange as a golden file in dir, and
+// LLM_REPLAY=dir answers requests from those files without calling the
+// provider, in which case API keys may be left unset.
+func Resolve(s config.Settings, stderr io.Writer, extra ...Option) (Provider, error) {
+	if stderr == nil {
+		stderr = io.Discard
+	}
+
+	opts, err := resolveOptions(s)
+	if err != nil {
+		return nil, err
+	}
+	opts = append(opts, extra...)
+
+	name, primary, err := resolveOne(s, opts)
+	if err != nil || len(s.Fallback) == 0 {
+		return primary, err
+	}
+
+	chain := []Fallback{{Name: name, Provider: primary}}
+	for _, fallback := range s.Fallback {
+		if fallback == name {
+			continue
+		}
+
+		// Model and endpoint are specific to the primary provider, so
+		// fallbacks use their own defaults.
+		fs := s
+		fs.Provider, fs.Model, fs.Endpoint = fallback, "", ""
+
+		fallbackName, p, err := resolveOne(fs, opts)
+		if err != nil {
+			_, _ = fmt.Fprintf(stderr, "llm: skipping fallback %s: %v\n", fallback, err)
+			continue
+		}
+		chain = append(chain, Fallback{Name: fallbackName, Provider: p})
+	}
+
+	if len(chain) == 1 {
+		return primary, nil
+	}
+	return NewFallbackProvider(stderr, chain...), nil
+}
+
+func resolveOne(s config.Settings, opts []Option) (string, Provider, error) {
+	if s.Provider == localName || (s.Provider == "" && os.Getenv("LLM_BASE_URL") != "") {
+		p, err := resolveLocal(s, opts)
+		return localName, p, err
+	}
+
+	b, apiKey, err := selectBackend(s)
+	if err != nil {
+		return "", nil, err
+	}
+
+	endpoint := cmp.Or(s.Endpoint, getenv(b.endpointEnv), b.endpoint)
+	if endpoint == "" {
+		return "", nil, fmt.Errorf("provider %q requires %s or an endpoint to be set", b.name, b.endpointEnv)
+	}
+
+	model := cmp.Or(s.Model, getenv(b.modelEnv), b.model)
+	if model == "" && !newOptions(opts).noModel {
+		return "", nil, fmt.Errorf("provider %q requires %s or a model to be set", b.name, b.modelEnv)
+	}
+
+	return b.name, b.build(endpoint, model, apiKey, opts...), nil
+}
+
+// resolveLocal configures a LocalProvider from LLM_BASE_URL (e.g.
+// http://localhost:11434/v1) or an explicit endpoint. Local servers have no
+// default model, so one must be configured.
+func resolveLocal(s config.Settings, opts []Option) (Provider, error) {
+	endpoint := s.Endpoint
+	if endpoint == "" {
+		baseURL := os.Getenv("LLM_BASE_URL")
+		if baseURL == "" {
+			return nil, fmt.Errorf("provider %q requires LLM_BASE_URL or an endpoint to be set", localName)
+		}
+		endpoint = strings.TrimSuffix(baseURL, "/") + "/chat/completions"
+	}
+
+	if s.Model == "" && !newOptions(opts).noModel {
+		return nil, fmt.Errorf("provider %q requires a model. Set LLM_MODEL, --model or model in the config file", localName)
+	}
+
+	// Local servers usually need no key, so a missing one is not an error.
+	apiKey := os.Getenv("LLM_API_KEY")
+	if apiKey == "" {
+		key, _, err := lookupKey(s, localName)
+		if err != nil && !errors.Is(err, credentials.ErrNotFound) {
+			return nil, err
+		}
+		apiKey = key
+	}
+
+	return NewLocalProvider(endpoint, s.Model, apiKey, opts...), nil
+}
+
+// selectBackend returns the backend named by s.Provider and its API key.
+// Keys come from the backend's environment variable or, failing that, from
+// the credential helper or keyring. With no provider named, the first
+// backend with a key wins, and environment variables are checked for every
+// backend before any stored key. A credential helper or keyring that fails
+// stops the search, rather than letting a later backend win.
+func selectBackend(s config.Settings) (backend, string, error) {
+	name := s.Provider
+	if name == "" {
+		for _, b := range backends {
+			if apiKey := os.Getenv(b.envKey); apiKey != "" {
+				return b, apiKey, nil
+			}
+		}
+
+		for _, b := range backends {
+			apiKey, _, err := lookupKey(s, b.name)
+			if err == nil {
+				return b, apiKey, nil
+			}
+			if !errors.Is(err, credentials.ErrNotFound) {
+				return backend{}, "", err
+			}
+		}
+
+		return backend{}, "", fmt.Errorf("no API key found. Set %s or run llm auth login <provider>", joinEnvKeys())
+	}
+
+	for _, b := range backends {
+		if b.name != name {
+			continue
+		}
+
+		apiKey := os.Getenv(b.envKey)
+		if apiKey != "" {
+			return b, apiKey, nil
+		}
+
+		apiKey, _, err := lookupKey(s, b.name)
+		switch {
+		case err == nil:
+			return b, apiKey, nil
+		case !errors.Is(err, credentials.ErrNotFound):
+			return backend{}, "", err
+		case os.Getenv("LLM_REPLAY") != "":
+			return b, "", nil
+		}
+		return backend{}, "", fmt.Errorf("provider %q requires %s to be set or a key stored with llm auth login %s", name, b.envKey, name)
+	}
+
+	return backend{}, "", fmt.Errorf("unknown provider %q (available: %s)", name, strings.Join(backendNames(), ", "))
+}
+
+// lookupKey returns the stored API key for the named provider from the
+// credential helper and, unless disabled, the keyring.
+func lookupKey(s config.Settings, name string) (key, source string, err error) {
+	var keyring credentials.Keyring
+	if s.Keyring == nil || *s.Keyring {
+		keyring = credentials.DefaultKeyring
+	}
+	return credentials.Lookup(s.CredentialHelper, keyring, name)
+}
+
+// KeyStatus reports where the API key of a provider comes from.
+type KeyStatus struct {
+	Provider string
+	// EnvKey is the environment variable read for the key.
+	EnvKey string
+	// Source is EnvKey, credentials.SourceHelper or
+	// credentials.SourceKeyring, or empty when no key is set.
+	Source string
+	// Err is set when the credential helper or keyring failed.
+	Err error
+}
+
+// String describes where the key comes from without revealing it.
+func (k KeyStatus) String() string {
+	switch {
+	case k.Err != nil:
+		return "error: " + k.Err.Error()
+	case k.Source == k.EnvKey:
+		return "environment (" + k.EnvKey + ")"
+	case k.Source != "":
+		return k.Source
+	}
+	return "not set"
+}
+
+// KeyStatuses reports for every provider where its API key would be read
+// from with the settings s, in order of precedence.
+func KeyStatuses(s config.Settings) []KeyStatus {
+	statuses := make([]KeyStatus, 0, len(backends)+1)
+	for _, b := range backends {
+		statuses = append(statuses, keyStatus(s, b.name, b.envKey))
+	}
+	return append(statuses, keyStatus(s, localName, "LLM_API_KEY"))
+}
+
+func keyStatus(s config.Settings, name, envKey string) KeyStatus {
+	status := KeyStatus{Provider: name, EnvKey: envKey}
+	if os.Getenv(envKey) != "" {
+		status.Source = envKey
+		return status
+	}
+
+	_, source, err := lookupKey(s, name)
+	if err != nil && !errors.Is(err, credentials.ErrNotFound) {
+		status.Err = err
+	}
+	status.Source = source
+	return status
+}
+
+// Selection explains which provider Resolve picks for a set of settings.
+type Selection struct {
+	Provider string
+	Model    string
+	// Reason says why Provider was picked.
+	Reason string
+	// Fallback lists the providers tried after Provider.
+	Fallback []string
+}
+
+// Select reports which provider Resolve would use with the settings s and
+// why, without calling it.
+func Select(s config.Settings) (Selection, error) {
+	p, err := Resolve(s, io.Discard)
+	if err != nil {
+		return Selection{}, err
+	}
+
+	d := Describe(p)
+	sel := Selection{Provider: d.Provider, Model: d.Model}
+	for _, fallback := range s.Fallback {
+		if fallback != sel.Provider {
+			sel.Fallback = append(sel.Fallback, fallback)
+		}
+	}
+
+	switch {
+	case s.Provider != "":
+		sel.Reason = "set by --provider, LLM_PROVIDER or provider in the config file"
+	case sel.Provider == localName:
+		sel.Reason = "LLM_BASE_URL is set"
+	default:
+		for _, b := range backends {
+			if b.name == sel.Provider {
+				sel.Reason = "first provider with a key in the " + keyStatus(s, b.name, b.envKey).String()
+			}
+		}
+	}
+
+	return sel, nil
+}
+
+// Configured returns the names of the providers that can be used without
+// further setup with the settings s: those with an API key, and local
+// when LLM_BASE_URL is set.
+func Configured(s config.Settings) []string {
+	var names []string
+	for _, status := range KeyStatuses(s) {
+		if status.Provider == localName {
+			if os.Getenv("LLM_BASE_URL") != "" {
+				names = append(names, localName)
+			}
+			continue
+		}
+		if status.Source != "" {
+			names = append(names, status.Provider)
+		}
+	}
+	return names
+}
+
+// DefaultModel returns the model used by the named provider when none is
+// configured, or "" when it has none, as for local servers.
+func DefaultModel(name string) string {
+	for _, b := range backends {
+		if b.name == name {
+			return cmp.Or(getenv(b.modelEnv), b.model)
+		}
+	}
+	return ""
+}
+
+// Names returns the names of the known providers.
+func Names() []string {
+	return backendNames()
+}
+
+func getenv(key string) string {
+	if key == "" {
+		return ""
+	}
+	return os.Getenv(key)
+}
+
+// resolveOptions returns the options for s along with a transport for its
+// connection settings, wrapped to record or replay requests when
+// LLM_RECORD or LLM_REPLAY is set. The transport is shared by every
+// provider in a fallback chain.
+func resolveOptions(s config.Settings) ([]Option, error) {
+	transport, err := NewTransport(TransportOptions{Proxy: s.Proxy, NoProxy: s.NoProxy, CABundle: s.CABundle})
+	if err != nil {
+		return nil, err
 	}
 
-	if apiKey := os.Getenv("OPENCODE_ZEN_API_KEY"); apiKey != "" {
-		return NewOpencodeZenProvider(
-			"https://opencode.ai/zen/v1/messages",
-			"claude-haiku-4-5",
-			apiKey,
-		), nil
+	record, replay := os.Getenv("LLM_RECORD"), os.Getenv("LLM_REPLAY")
+	switch {
+	case record != "" && replay != "":
+		return nil, fmt.Errorf("LLM_RECORD and LLM_REPLAY cannot both be set")
+	case record != "":
+		transport = NewRecordingTransport(record, transport)
+	case replay != "":
+		transport, err = NewReplayTransport(replay)
+		if err != nil {
+			return nil, fmt.Errorf("LLM_REPLAY: %w", err)
+		}
 	}
 
-	if apiKey := os.Getenv("ANTHROPIC_API_KEY"); apiKey != "" {
-		return NewAnthropicProvider(
-			"https://api.anthropic.com/v1/messages",
-			"claude-haiku-4-5",
-			apiKey,
-		), nil
+	return append(settingsOptions(s), WithTransport(transport)), nil
+}
+
+func settingsOptions(s config.Settings) []Option {
+	var opts []Option
+	if s.MaxTokens > 0 {
+		opts = append(opts, WithMaxTokens(s.MaxTokens))
+	}
+	if s.Temperature != nil {
+		opts = append(opts, WithTemperature(*s.Temperature))
+	}
+	if s.Timeout > 0 {
+		opts = append(opts, WithTimeout(s.Timeout))
 	}
+	if s.Retries != nil {
+		opts = append(opts, WithRetries(*s.Retries))
+	}
+	if s.MaxRetryDelay > 0 {
+		opts = append(opts, WithMaxRetryDelay(s.MaxRetryDelay))
+	}
+	if s.MaxContinuations > 0 {
+		opts = append(opts, WithMaxContinuations(s.MaxContinuations))
+	}
+	return opts
+}
 
-	if apiKey := os.Getenv("OPENAI_API_KEY"); apiKey != "" {
-		return NewOpenAIProvider(
-			"https://api.openai.com/v1/chat/completions",
-			"gpt-4o-mini",
-			apiKey,
-		), nil
+func backendNames() []string {
+	names := make([]string, 0, len(backends)+1)
+	for _, b := range backends {
+		names = append(names, b.name)
 	}
+	return append(names, localName)
+}
 
-	return nil, fmt.Errorf("no API key found. Set OPENROUTER_API_KEY, OPENCODE_ZEN_API_KEY, ANTHROPIC_API_KEY, or OPENAI_API_KEY")
+func joinEnvKeys() string {
+	keys := make([]string, 0, len(backends))
+	for _, b := range backends {
+		keys = append(keys, b.envKey)
+	}
+	return strings.Join(keys[:len(keys)-1], ", ") + ", or " + keys[len(keys)-1]
 }
@@ -0,0 +1,307 @@
+package providers
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"llm/internal/config"
+	"llm/internal/credentials"
+)
+
+func clearAPIKeys(t *testing.T) {
+	t.Helper()
+
+	for _, b := range backends {
+		t.Setenv(b.envKey, "")
+	}
+	t.Setenv("AZURE_OPENAI_ENDPOINT", "")
+	t.Setenv("AZURE_OPENAI_DEPLOYMENT", "")
+	t.Setenv("AZURE_OPENAI_API_VERSION", "")
+	t.Setenv("LLM_BASE_URL", "")
+	t.Setenv("LLM_API_KEY", "")
+	t.Setenv("LLM_RECORD", "")
+	t.Setenv("LLM_REPLAY", "")
+
+	// Keep keys stored on the machine running the tests out of the results.
+	keyring := credentials.DefaultKeyring
+	credentials.DefaultKeyring = nil
+	t.Cleanup(func() { credentials.DefaultKeyring = keyring })
+}
+
+func TestResolve(t *testing.T) {
+	tests := []struct {
+		name          string
+		env           map[string]string
+		settings      config.Settings
+		wantEndpoint  string
+		wantModel     string
+		wantErrSubstr string
+	}{
+		{
+			name:         "picks first key in precedence order",
+			env:          map[string]string{"ANTHROPIC_API_KEY": "a", "OPENAI_API_KEY": "o"},
+			wantEndpoint: "https://api.anthropic.com/v1/messages",
+			wantModel:    "claude-haiku-4-5",
+		},
+		{
+			name:         "named provider wins over precedence",
+			env:          map[string]string{"OPENROUTER_API_KEY": "r", "OPENAI_API_KEY": "o"},
+			settings:     config.Settings{Provider: "openai"},
+			wantEndpoint: "https://api.openai.com/v1/chat/completions",
+			wantModel:    "gpt-4o-mini",
+		},
+		{
+			name: "configures azure from environment",
+			env: map[string]string{
+				"AZURE_OPENAI_API_KEY":    "z",
+				"AZURE_OPENAI_ENDPOINT":   "https://acme.openai.azure.com",
+				"AZURE_OPENAI_DEPLOYMENT": "gpt-4o-prod",
+			},
+			wantEndpoint: "https://acme.openai.azure.com",
+			wantModel:    "gpt-4o-prod",
+		},
+		{
+			name:          "azure requires an endpoint",
+			env:           map[string]string{"AZURE_OPENAI_API_KEY": "z", "AZURE_OPENAI_DEPLOYMENT": "gpt-4o-prod"},
+			wantErrSubstr: `provider "azure" requires AZURE_OPENAI_ENDPOINT`,
+		},
+		{
+			name:          "azure requires a deployment",
+			env:           map[string]string{"AZURE_OPENAI_API_KEY": "z", "AZURE_OPENAI_ENDPOINT": "https://acme.openai.azure.com"},
+			wantErrSubstr: `provider "azure" requires AZURE_OPENAI_DEPLOYMENT`,
+		},
+		{
+			name:         "falls back to gemini",
+			env:          map[string]string{"GEMINI_API_KEY": "g"},
+			wantEndpoint: "https://generativelanguage.googleapis.com/v1beta/models",
+			wantModel:    "gemini-2.5-flash",
+		},
+		{
+			name:         "overrides model and endpoint",
+			env:          map[string]string{"OPENROUTER_API_KEY": "r"},
+			settings:     config.Settings{Model: "anthropic/claude-sonnet-4.5", Endpoint: "https://proxy.example/v1/chat/completions"},
+			wantEndpoint: "https://proxy.example/v1/chat/completions",
+			wantModel:    "anthropic/claude-sonnet-4.5",
+		},
+		{
+			name:         "local server takes precedence over hosted providers",
+			env:          map[string]string{"LLM_BASE_URL": "http://localhost:11434/v1/", "OPENROUTER_API_KEY": "r"},
+			settings:     config.Settings{Model: "llama3.2"},
+			wantEndpoint: "http://localhost:11434/v1/chat/completions",
+			wantModel:    "llama3.2",
+		},
+		{
+			name:         "named local provider uses configured endpoint",
+			settings:     config.Settings{Provider: "local", Endpoint: "http://gpu-box:8000/v1/chat/completions", Model: "qwen2.5-coder"},
+			wantEndpoint: "http://gpu-box:8000/v1/chat/completions",
+			wantModel:    "qwen2.5-coder",
+		},
+		{
+			name:          "local provider requires a model",
+			env:           map[string]string{"LLM_BASE_URL": "http://localhost:11434/v1"},
+			wantErrSubstr: `provider "local" requires a model`,
+		},
+		{
+			name:          "named local provider requires a base URL",
+			settings:      config.Settings{Provider: "local", Model: "llama3.2"},
+			wantErrSubstr: "requires LLM_BASE_URL",
+		},
+		{
+			name:          "fails without any key",
+			wantErrSubstr: "no API key found",
+		},
+		{
+			name:          "fails when named provider has no key",
+			env:           map[string]string{"OPENAI_API_KEY": "o"},
+			settings:      config.Settings{Provider: "anthropic"},
+			wantErrSubstr: "requires ANTHROPIC_API_KEY",
+		},
+		{
+			name:          "fails on unknown provider",
+			settings:      config.Settings{Provider: "acme"},
+			wantErrSubstr: `unknown provider "acme"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearAPIKeys(t)
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+
+			p, err := Resolve(tt.settings, io.Discard)
+			if tt.wantErrSubstr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErrSubstr) {
+					t.Fatalf("Resolve() error = %v, want substring %q", err, tt.wantErrSubstr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Resolve() error = %v, want nil", err)
+			}
+
+			endpoint, model := describe(p)
+			if endpoint != tt.wantEndpoint || model != tt.wantModel {
+				t.Errorf("Resolve() = (%q, %q), want (%q, %q)", endpoint, model, tt.wantEndpoint, tt.wantModel)
+			}
+		})
+	}
+}
+
+func TestResolveAppliesOptions(t *testing.T) {
+	clearAPIKeys(t)
+	t.Setenv("ANTHROPIC_API_KEY", "a")
+
+	temperature := 0.3
+	p, err := Resolve(config.Settings{MaxTokens: 1024, Temperature: &temperature}, io.Discard)
+	if err != nil {
+		t.Fatalf("Resolve() error = %v, want nil", err)
+	}
+
+	a := p.(*AnthropicProvider)
+	if a.maxTokensOrDefault() != 1024 || a.temperature == nil || *a.temperature != 0.3 {
+		t.Errorf("Resolve() options = %+v, want max tokens 1024 and temperature 0.3", a.options)
+	}
+}
+
+func describe(p Provider) (string, string) {
+	switch p := p.(type) {
+	case *OpenRouterProvider:
+		return p.endpoint, p.model
+	case *OpencodeZenProvider:
+		return p.endpoint, p.model
+	case *AnthropicProvider:
+		return p.endpoint, p.model
+	case *OpenAIProvider:
+		return p.endpoint, p.model
+	case *LocalProvider:
+		return p.endpoint, p.model
+	case *GeminiProvider:
+		return p.endpoint, p.model
+	case *AzureOpenAIProvider:
+		return p.endpoint, p.deployment
+	}
+	return "", ""
+}
+
+func TestResolveStoredKeys(t *testing.T) {
+	clearAPIKeys(t)
+
+	helper := filepath.Join(t.TempDir(), "helper")
+	script := "#!/bin/sh\n[ \"$1\" = gemini ] && echo gm-from-helper\nexit 0\n"
+	if err := os.WriteFile(helper, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	s := config.Settings{CredentialHelper: helper + " {provider}"}
+
+	p, err := Resolve(s, io.Discard)
+	if err != nil {
+		t.Fatalf("Resolve() error = %v, want a provider picked by its stored key", err)
+	}
+	if g, ok := p.(*GeminiProvider); !ok || g.apiKey != "gm-from-helper" {
+		t.Errorf("Resolve() = %T, want *GeminiProvider with the helper's key", p)
+	}
+
+	t.Setenv("ANTHROPIC_API_KEY", "a")
+	if p, _ := Resolve(s, io.Discard); p == nil || p.(*AnthropicProvider).apiKey != "a" {
+		t.Errorf("Resolve() = %T, want environment keys ahead of stored ones", p)
+	}
+
+	s.Provider = "openai"
+	if _, err := Resolve(s, io.Discard); err == nil || !strings.Contains(err.Error(), "llm auth login openai") {
+		t.Errorf("Resolve() error = %v, want missing key error mentioning llm auth login", err)
+	}
+
+	statuses := KeyStatuses(s)
+	got := make(map[string]string)
+	for _, status := range statuses {
+		got[status.Provider] = status.Source
+	}
+	if got["anthropic"] != "ANTHROPIC_API_KEY" || got["gemini"] != credentials.SourceHelper || got["openai"] != "" || len(statuses) != len(backends)+1 {
+		t.Errorf("KeyStatuses() = %+v, want anthropic from the environment and gemini from the helper", statuses)
+	}
+
+	dir := t.TempDir()
+	failing := filepath.Join(dir, "helper")
+	script = "#!/bin/sh\necho \"$1\" >>" + filepath.Join(dir, "calls") + "\necho 'vault sealed' >&2\nexit 1\n"
+	if err := os.WriteFile(failing, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("ANTHROPIC_API_KEY", "")
+	s = config.Settings{CredentialHelper: failing + " {provider}"}
+	if _, err := Resolve(s, io.Discard); err == nil || !strings.Contains(err.Error(), "vault sealed") {
+		t.Errorf("Resolve() with a failing helper error = %v, want the helper's error", err)
+	}
+	if calls, _ := os.ReadFile(filepath.Join(dir, "calls")); string(calls) != "openrouter\n" {
+		t.Errorf("helper called for %q, want it to stop after the first failure", calls)
+	}
+}
+
+func TestSelect(t *testing.T) {
+	tests := []struct {
+		name       string
+		env        map[string]string
+		settings   config.Settings
+		want       Selection
+		wantConfig []string
+	}{
+		{
+			name: "first key in the environment",
+			env:  map[string]string{"OPENAI_API_KEY": "sk-openai", "GEMINI_API_KEY": "gemini"},
+			want: Selection{
+				Provider: "openai",
+				Model:    "gpt-4o-mini",
+				Reason:   "first provider with a key in the environment (OPENAI_API_KEY)",
+			},
+			wantConfig: []string{"openai", "gemini"},
+		},
+		{
+			name:     "named provider with fallbacks",
+			env:      map[string]string{"OPENAI_API_KEY": "sk-openai", "GEMINI_API_KEY": "gemini"},
+			settings: config.Settings{Provider: "gemini", Fallback: []string{"gemini", "openai"}},
+			want: Selection{
+				Provider: "gemini",
+				Model:    "gemini-2.5-flash",
+				Reason:   "set by --provider, LLM_PROVIDER or provider in the config file",
+				Fallback: []string{"openai"},
+			},
+			wantConfig: []string{"openai", "gemini"},
+		},
+		{
+			name:     "local server",
+			env:      map[string]string{"LLM_BASE_URL": "http://localhost:11434/v1"},
+			settings: config.Settings{Model: "llama3.2"},
+			want: Selection{
+				Provider: "local",
+				Model:    "llama3.2",
+				Reason:   "LLM_BASE_URL is set",
+			},
+			wantConfig: []string{"local"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearAPIKeys(t)
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+
+			got, err := Select(tt.settings)
+			if err != nil {
+				t.Fatalf("Select() error = %v, want nil", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Select() = %+v, want %+v", got, tt.want)
+			}
+			if got := Configured(tt.settings); !reflect.DeepEqual(got, tt.wantConfig) {
+				t.Errorf("Configured() = %v, want %v", got, tt.wantConfig)
+			}
+		})
+	}
+}
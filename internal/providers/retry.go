@@ -0,0 +1,184 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultRetries       = 2
+	defaultMaxRetryDelay = 20 * time.Second
+	baseRetryDelay       = 500 * time.Millisecond
+)
+
+// sleep waits for d or until ctx is done. Tests replace it to avoid real delays.
+var sleep = func(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// StatusError is returned when a provider answers with a status other than
+// 200 OK. Message is taken from the JSON error body when there is one.
+type StatusError struct {
+	StatusCode int
+	Message    string
+	RetryAfter time.Duration
+}
+
+func (e *StatusError) Error() string {
+	status := strconv.Itoa(e.StatusCode)
+	if text := http.StatusText(e.StatusCode); text != "" {
+		status += " " + text
+	}
+
+	if e.Message == "" {
+		return status
+	}
+	return status + ": " + e.Message
+}
+
+// IsRetryable reports whether err is likely to be transient: rate limiting,
+// server errors (including Anthropic's 529 "overloaded") and network
+// failures. Cancellation, timeouts and requests missing from a replay
+// are not retried.
+func IsRetryable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) || errors.Is(err, ErrNotRecorded) {
+		return false
+	}
+
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode == http.StatusTooManyRequests || statusErr.StatusCode >= 500
+	}
+
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return !urlErr.Timeout()
+	}
+
+	return false
+}
+
+// send executes the request built by newReq, retrying retryable failures
+// with jittered exponential backoff. A Retry-After header replaces the
+// computed delay, capped at the maximum delay. The returned response
+// always has status 200.
+func send(ctx context.Context, client *http.Client, newReq func() (*http.Request, error), o options) (*http.Response, error) {
+	retries := o.retriesOrDefault()
+	maxDelay := o.maxRetryDelayOrDefault()
+
+	for attempt := 0; ; attempt++ {
+		req, err := newReq()
+		if err != nil {
+			return nil, err
+		}
+
+		resp, err := client.Do(req)
+		if err == nil && resp.StatusCode == http.StatusOK {
+			return resp, nil
+		}
+		if err == nil {
+			err = newStatusError(resp)
+		}
+
+		if attempt >= retries || !IsRetryable(err) || ctx.Err() != nil {
+			return nil, err
+		}
+
+		delay := backoff(attempt, maxDelay)
+		var statusErr *StatusError
+		if errors.As(err, &statusErr) && statusErr.RetryAfter > 0 {
+			delay = min(statusErr.RetryAfter, maxDelay)
+		}
+
+		if err := sleep(ctx, delay); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// backoff returns a delay between half and all of baseRetryDelay * 2^attempt,
+// capped at maxDelay.
+func backoff(attempt int, maxDelay time.Duration) time.Duration {
+	d := baseRetryDelay << attempt
+	if d <= 0 || d > maxDelay {
+		d = maxDelay
+	}
+	return d/2 + rand.N(d/2+1)
+}
+
+// newStatusError reads and closes resp.Body and describes the failure.
+func newStatusError(resp *http.Response) *StatusError {
+	defer func() { _ = resp.Body.Close() }()
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	return &StatusError{
+		StatusCode: resp.StatusCode,
+		Message:    errorMessage(body),
+		RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+	}
+}
+
+// errorMessage extracts the message from the error bodies used by the
+// supported APIs, falling back to the trimmed body itself.
+func errorMessage(body []byte) string {
+	var parsed struct {
+		Error   json.RawMessage `json:"error"`
+		Message string          `json:"message"`
+	}
+	if err := json.Unmarshal(body, &parsed); err == nil {
+		var nested struct {
+			Message string `json:"message"`
+		}
+		var plain string
+		switch {
+		case json.Unmarshal(parsed.Error, &nested) == nil && nested.Message != "":
+			return nested.Message
+		case json.Unmarshal(parsed.Error, &plain) == nil && plain != "":
+			return plain
+		case parsed.Message != "":
+			return parsed.Message
+		}
+	}
+
+	msg := strings.TrimSpace(string(body))
+	if len(msg) > 200 {
+		msg = msg[:200] + "..."
+	}
+	return msg
+}
+
+// parseRetryAfter accepts both forms of the Retry-After header: a number
+// of seconds or an HTTP date.
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+
+	if at, err := http.ParseTime(value); err == nil {
+		if d := time.Until(at); d > 0 {
+			return d
+		}
+	}
+
+	return 0
+}
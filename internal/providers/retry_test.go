@@ -0,0 +1,248 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func stubSleep(t *testing.T) *[]time.Duration {
+	t.Helper()
+
+	original := sleep
+	t.Cleanup(func() { sleep = original })
+
+	var delays []time.Duration
+	sleep = func(ctx context.Context, d time.Duration) error {
+		delays = append(delays, d)
+		return ctx.Err()
+	}
+	return &delays
+}
+
+type response struct {
+	status     int
+	body       string
+	retryAfter string
+}
+
+func newSequenceServer(t *testing.T, responses ...response) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		i := int(calls.Add(1)) - 1
+		resp := responses[min(i, len(responses)-1)]
+		if resp.retryAfter != "" {
+			w.Header().Set("Retry-After", resp.retryAfter)
+		}
+		w.WriteHeader(resp.status)
+		_, _ = w.Write([]byte(resp.body))
+	}))
+	t.Cleanup(server.Close)
+
+	return server, &calls
+}
+
+const okBody = `{"choices":[{"message":{"content":"ok"}}]}`
+
+func TestCompleteRetries(t *testing.T) {
+	tests := []struct {
+		name          string
+		responses     []response
+		opts          []Option
+		wantCalls     int32
+		wantDelays    []time.Duration
+		wantErrSubstr string
+	}{
+		{
+			name: "retries rate limit then succeeds",
+			responses: []response{
+				{status: 429, body: `{"error":{"message":"Rate limit exceeded"}}`},
+				{status: 200, body: okBody},
+			},
+			wantCalls: 2,
+		},
+		{
+			name: "retries anthropic overloaded",
+			responses: []response{
+				{status: 529, body: `{"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}`},
+				{status: 200, body: okBody},
+			},
+			wantCalls: 2,
+		},
+		{
+			name: "gives up after configured retries",
+			responses: []response{
+				{status: 503, body: "upstream unavailable"},
+			},
+			opts:          []Option{WithRetries(3)},
+			wantCalls:     4,
+			wantErrSubstr: "503 Service Unavailable: upstream unavailable",
+		},
+		{
+			name: "does not retry client errors",
+			responses: []response{
+				{status: 401, body: `{"error":{"message":"Invalid API key"}}`},
+			},
+			wantCalls:     1,
+			wantErrSubstr: "401 Unauthorized: Invalid API key",
+		},
+		{
+			name: "zero retries disables retrying",
+			responses: []response{
+				{status: 500, body: `{"error":"boom"}`},
+			},
+			opts:          []Option{WithRetries(0)},
+			wantCalls:     1,
+			wantErrSubstr: "500 Internal Server Error: boom",
+		},
+		{
+			name: "waits for Retry-After",
+			responses: []response{
+				{status: 429, retryAfter: "3"},
+				{status: 200, body: okBody},
+			},
+			wantCalls:  2,
+			wantDelays: []time.Duration{3 * time.Second},
+		},
+		{
+			name: "does not wait longer than the max delay",
+			responses: []response{
+				{status: 429, retryAfter: "120"},
+				{status: 200, body: okBody},
+			},
+			opts:       []Option{WithMaxRetryDelay(10 * time.Second)},
+			wantCalls:  2,
+			wantDelays: []time.Duration{10 * time.Second},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			delays := stubSleep(t)
+			server, calls := newSequenceServer(t, tt.responses...)
+
+			p := NewOpenAIProvider(server.URL, "gpt-4o-mini", "key", tt.opts...)
+			resp, err := p.Complete(context.Background(), "", "hi")
+
+			if tt.wantErrSubstr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErrSubstr) {
+					t.Errorf("Complete() error = %v, want substring %q", err, tt.wantErrSubstr)
+				}
+			} else if err != nil || resp.Text != "ok" {
+				t.Errorf("Complete() = %q, %v, want %q", resp.Text, err, "ok")
+			}
+
+			if calls.Load() != tt.wantCalls {
+				t.Errorf("server calls = %d, want %d", calls.Load(), tt.wantCalls)
+			}
+
+			if tt.wantDelays != nil && !slices.Equal(*delays, tt.wantDelays) {
+				t.Errorf("delays = %v, want %v", *delays, tt.wantDelays)
+			}
+		})
+	}
+}
+
+func TestCompleteStopsRetryingWhenCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	original := sleep
+	t.Cleanup(func() { sleep = original })
+	sleep = func(ctx context.Context, d time.Duration) error {
+		cancel()
+		return ctx.Err()
+	}
+
+	server, calls := newSequenceServer(t, response{status: 503})
+
+	p := NewOpenAIProvider(server.URL, "gpt-4o-mini", "key")
+	_, err := p.Complete(ctx, "", "hi")
+
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Complete() error = %v, want context.Canceled", err)
+	}
+
+	if calls.Load() != 1 {
+		t.Errorf("server calls = %d, want 1", calls.Load())
+	}
+}
+
+func TestStreamRetriesBeforeFirstEvent(t *testing.T) {
+	stubSleep(t)
+
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		_, _ = w.Write([]byte("data: {\"choices\":[{\"delta\":{\"content\":\"ok\"}}]}\n\ndata: [DONE]\n\n"))
+	}))
+	t.Cleanup(server.Close)
+
+	p := NewOpenAIProvider(server.URL, "gpt-4o-mini", "key")
+	resp, err := Stream(context.Background(), p, "", "hi", func(string) error { return nil })
+	if err != nil || resp.Text != "ok" {
+		t.Fatalf("Stream() = %q, %v, want %q", resp.Text, err, "ok")
+	}
+
+	if calls.Load() != 2 {
+		t.Errorf("server calls = %d, want 2", calls.Load())
+	}
+}
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "nil", err: nil, want: false},
+		{name: "rate limited", err: &StatusError{StatusCode: 429}, want: true},
+		{name: "server error", err: &StatusError{StatusCode: 502}, want: true},
+		{name: "bad request", err: &StatusError{StatusCode: 400}, want: false},
+		{name: "cancelled", err: context.Canceled, want: false},
+		{name: "plain error", err: errors.New("invalid response"), want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsRetryable(tt.err); got != tt.want {
+				t.Errorf("IsRetryable(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	for attempt := range 10 {
+		d := backoff(attempt, 5*time.Second)
+		limit := min(baseRetryDelay<<attempt, 5*time.Second)
+		if d < limit/2 || d > limit {
+			t.Errorf("backoff(%d) = %v, want between %v and %v", attempt, d, limit/2, limit)
+		}
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	if got := parseRetryAfter("7"); got != 7*time.Second {
+		t.Errorf("parseRetryAfter(seconds) = %v, want 7s", got)
+	}
+
+	date := time.Now().Add(time.Minute).UTC().Format(http.TimeFormat)
+	if got := parseRetryAfter(date); got <= 0 || got > time.Minute {
+		t.Errorf("parseRetryAfter(date) = %v, want up to 1m", got)
+	}
+
+	if got := parseRetryAfter("soon"); got != 0 {
+		t.Errorf("parseRetryAfter(invalid) = %v, want 0", got)
+	}
+}
@@ -0,0 +1,70 @@
+package providers
+
+import (
+	"context"
+	"sync"
+)
+
+// ModelSwitcher reports and changes the provider and model used by a
+// command while it runs.
+type ModelSwitcher interface {
+	Current() Usage
+	SetModel(model string) error
+}
+
+// Switch forwards every call to a provider that can be replaced between
+// calls, so that an interactive command can change the model while the
+// wrappers around it, such as the cache and the usage ledger, stay in
+// place. It is safe for concurrent use.
+type Switch struct {
+	mu       sync.Mutex
+	provider Provider
+}
+
+// NewSwitch returns a Switch that forwards to p until Set is called.
+func NewSwitch(p Provider) *Switch {
+	return &Switch{provider: p}
+}
+
+// Set makes p answer every later call.
+func (s *Switch) Set(p Provider) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.provider = p
+}
+
+// Provider returns the provider that currently answers.
+func (s *Switch) Provider() Provider {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.provider
+}
+
+func (s *Switch) Complete(ctx context.Context, system, userMsg string) (Response, error) {
+	return s.Provider().Complete(ctx, system, userMsg)
+}
+
+func (s *Switch) Chat(ctx context.Context, system string, msgs []Message) (Response, error) {
+	return Chat(ctx, s.Provider(), system, msgs)
+}
+
+func (s *Switch) Stream(ctx context.Context, system, userMsg string, onDelta func(string) error) (Response, error) {
+	return Stream(ctx, s.Provider(), system, userMsg, onDelta)
+}
+
+func (s *Switch) StreamChat(ctx context.Context, system string, msgs []Message, onDelta func(string) error) (Response, error) {
+	return StreamChat(ctx, s.Provider(), system, msgs, onDelta)
+}
+
+func (s *Switch) describe() Usage {
+	return Describe(s.Provider())
+}
+
+// cacheScope is the scope of the current provider, or "" when it cannot be
+// cached.
+func (s *Switch) cacheScope() string {
+	if c, ok := s.Provider().(cacheable); ok {
+		return c.cacheScope()
+	}
+	return ""
+}
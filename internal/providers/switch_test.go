@@ -0,0 +1,40 @@
+package providers
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestSwitchChangesProviderUnderCache(t *testing.T) {
+	server, requests := newCountingServer(t)
+	sw := NewSwitch(NewOpenAIProvider(server.URL, "gpt-4o-mini", "key"))
+	p := NewResponseCache(t.TempDir(), time.Hour, 0).Wrap(sw)
+
+	complete := func() Response {
+		t.Helper()
+		resp, err := p.Complete(context.Background(), "", "hi")
+		if err != nil {
+			t.Fatalf("Complete() error = %v, want nil", err)
+		}
+		return resp
+	}
+
+	complete()
+	sw.Set(NewOpenAIProvider(server.URL, "gpt-4o", "key"))
+	if resp := complete(); resp.Cached {
+		t.Errorf("Complete() after Set() = %+v, want a fresh answer from the new model", resp)
+	}
+	if d := Describe(p); d.Model != "gpt-4o" {
+		t.Errorf("Describe() = %+v, want the new model", d)
+	}
+
+	sw.Set(&completeOnlyProvider{resp: "uncacheable"})
+	if resp := complete(); resp.Cached || resp.Text != "uncacheable" {
+		t.Errorf("Complete() = %+v, want the uncacheable provider called directly", resp)
+	}
+
+	if *requests != 2 {
+		t.Errorf("server saw %d requests, want 2", *requests)
+	}
+}
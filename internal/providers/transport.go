@@ -0,0 +1,169 @@
+package providers
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// defaultTransport is shared by every provider without its own transport
+// so that connections are kept alive across requests and providers.
+var defaultTransport http.RoundTripper = newBaseTransport()
+
+// TransportOptions configures how providers connect to their APIs.
+type TransportOptions struct {
+	// Proxy is the URL of a proxy used for every request. When empty,
+	// HTTPS_PROXY, HTTP_PROXY and NO_PROXY are read from the environment.
+	Proxy string
+	// NoProxy is a comma-separated list of hosts, domains and CIDR ranges
+	// that are reached directly, in the format of NO_PROXY.
+	NoProxy string
+	// CABundle is a PEM file of certificates to trust in addition to the
+	// system roots, for proxies that intercept TLS.
+	CABundle string
+}
+
+func (t TransportOptions) isZero() bool {
+	return t == TransportOptions{}
+}
+
+// NewTransport returns a transport configured by t. With no options set
+// it returns the transport shared by all providers.
+func NewTransport(t TransportOptions) (http.RoundTripper, error) {
+	if t.isZero() {
+		return defaultTransport, nil
+	}
+
+	transport := newBaseTransport()
+
+	proxy, err := proxyFunc(t.Proxy, t.NoProxy)
+	if err != nil {
+		return nil, err
+	}
+	transport.Proxy = proxy
+
+	if t.CABundle != "" {
+		pool, err := loadCABundle(t.CABundle)
+		if err != nil {
+			return nil, err
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}
+	}
+
+	return transport, nil
+}
+
+func newBaseTransport() *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConnsPerHost = 4
+	return transport
+}
+
+// proxyFunc returns a proxy selector that sends requests through proxy,
+// or the proxy from the environment when proxy is empty, except for hosts
+// matched by noProxy.
+func proxyFunc(proxy, noProxy string) (func(*http.Request) (*url.URL, error), error) {
+	if proxy == "" && noProxy == "" {
+		return http.ProxyFromEnvironment, nil
+	}
+
+	selectProxy := http.ProxyFromEnvironment
+	if proxy != "" {
+		if !strings.Contains(proxy, "://") {
+			proxy = "http://" + proxy
+		}
+
+		proxyURL, err := url.Parse(proxy)
+		if err != nil || proxyURL.Host == "" {
+			return nil, fmt.Errorf("invalid proxy %q", proxy)
+		}
+		switch proxyURL.Scheme {
+		case "http", "https", "socks5", "socks5h":
+		default:
+			return nil, fmt.Errorf("invalid proxy %q: unsupported scheme %q", proxy, proxyURL.Scheme)
+		}
+		selectProxy = http.ProxyURL(proxyURL)
+	}
+
+	return func(req *http.Request) (*url.URL, error) {
+		if bypassProxy(req.URL, noProxy) {
+			return nil, nil
+		}
+		return selectProxy(req)
+	}, nil
+}
+
+// bypassProxy reports whether u is matched by an entry of noProxy. An
+// entry matches its host and any subdomain, ".example.com" only matches
+// subdomains, an IP address or CIDR range matches those addresses and "*"
+// matches everything. An entry with a port only matches that port.
+func bypassProxy(u *url.URL, noProxy string) bool {
+	host, port := strings.ToLower(u.Hostname()), u.Port()
+	if port == "" {
+		port = map[string]string{"http": "80", "https": "443"}[u.Scheme]
+	}
+	ip := net.ParseIP(host)
+
+	for _, entry := range strings.FieldsFunc(noProxy, func(r rune) bool { return r == ',' || r == ' ' }) {
+		entry = strings.ToLower(entry)
+		if entry == "*" {
+			return true
+		}
+
+		if _, cidr, err := net.ParseCIDR(entry); err == nil {
+			if ip != nil && cidr.Contains(ip) {
+				return true
+			}
+			continue
+		}
+
+		entryHost, entryPort := entry, ""
+		if h, p, err := net.SplitHostPort(entry); err == nil {
+			entryHost, entryPort = h, p
+		}
+		if entryPort != "" && entryPort != port {
+			continue
+		}
+
+		if entryIP := net.ParseIP(entryHost); entryIP != nil {
+			if ip != nil && entryIP.Equal(ip) {
+				return true
+			}
+			continue
+		}
+
+		if subdomains, ok := strings.CutPrefix(entryHost, "."); ok {
+			if strings.HasSuffix(host, "."+subdomains) {
+				return true
+			}
+			continue
+		}
+		if host == entryHost || strings.HasSuffix(host, "."+entryHost) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func loadCABundle(path string) (*x509.CertPool, error) {
+	pem, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading CA bundle: %w", err)
+	}
+
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("CA bundle %s contains no PEM certificates", path)
+	}
+
+	return pool, nil
+}
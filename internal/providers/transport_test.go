@@ -0,0 +1,138 @@
+package providers
+
+import (
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBypassProxy(t *testing.T) {
+	tests := []struct {
+		url     string
+		noProxy string
+		want    bool
+	}{
+		{"https://api.openai.com/v1", "", false},
+		{"https://api.openai.com/v1", "*", true},
+		{"https://api.openai.com/v1", "openai.com", true},
+		{"https://openai.com/v1", "openai.com", true},
+		{"https://notopenai.com/v1", "openai.com", false},
+		{"https://openai.com/v1", ".openai.com", false},
+		{"https://api.openai.com/v1", "localhost, .openai.com", true},
+		{"https://API.OpenAI.com/v1", "api.openai.com", true},
+		{"https://api.openai.com/v1", "api.openai.com:443", true},
+		{"https://api.openai.com/v1", "api.openai.com:8443", false},
+		{"http://10.1.2.3:11434/v1", "10.0.0.0/8", true},
+		{"http://192.168.1.2:11434/v1", "10.0.0.0/8", false},
+		{"http://127.0.0.1:11434/v1", "127.0.0.1", true},
+		{"http://[::1]:11434/v1", "::1", true},
+	}
+
+	for _, tt := range tests {
+		u, err := url.Parse(tt.url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := bypassProxy(u, tt.noProxy); got != tt.want {
+			t.Errorf("bypassProxy(%s, %q) = %v, want %v", tt.url, tt.noProxy, got, tt.want)
+		}
+	}
+}
+
+func TestNewTransportProxy(t *testing.T) {
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprintf(w, "proxied %s", r.URL)
+	}))
+	t.Cleanup(proxy.Close)
+
+	direct := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("direct"))
+	}))
+	t.Cleanup(direct.Close)
+
+	get := func(t *testing.T, rt http.RoundTripper, target string) string {
+		t.Helper()
+		resp, err := (&http.Client{Transport: rt}).Get(target)
+		if err != nil {
+			t.Fatalf("GET %s error = %v, want nil", target, err)
+		}
+		defer func() { _ = resp.Body.Close() }()
+		body, _ := io.ReadAll(resp.Body)
+		return string(body)
+	}
+
+	rt, err := NewTransport(TransportOptions{Proxy: strings.TrimPrefix(proxy.URL, "http://"), NoProxy: "127.0.0.2, localhost"})
+	if err != nil {
+		t.Fatalf("NewTransport() error = %v, want nil", err)
+	}
+	if got := get(t, rt, "http://api.example.test/v1/chat"); got != "proxied http://api.example.test/v1/chat" {
+		t.Errorf("response = %q, want the request to go through the proxy", got)
+	}
+
+	rt, err = NewTransport(TransportOptions{Proxy: proxy.URL, NoProxy: "127.0.0.1"})
+	if err != nil {
+		t.Fatalf("NewTransport() error = %v, want nil", err)
+	}
+	if got := get(t, rt, direct.URL); got != "direct" {
+		t.Errorf("response = %q, want no_proxy host reached directly", got)
+	}
+}
+
+func TestNewTransportCABundle(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	}))
+	t.Cleanup(server.Close)
+
+	if _, err := (&http.Client{Transport: defaultTransport}).Get(server.URL); err == nil {
+		t.Fatal("GET with the default transport error = nil, want an unknown authority error")
+	}
+
+	bundle := filepath.Join(t.TempDir(), "ca.pem")
+	cert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err := os.WriteFile(bundle, cert, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	rt, err := NewTransport(TransportOptions{CABundle: bundle})
+	if err != nil {
+		t.Fatalf("NewTransport() error = %v, want nil", err)
+	}
+	resp, err := (&http.Client{Transport: rt}).Get(server.URL)
+	if err != nil {
+		t.Fatalf("GET with the CA bundle error = %v, want nil", err)
+	}
+	_ = resp.Body.Close()
+}
+
+func TestNewTransportErrors(t *testing.T) {
+	empty := filepath.Join(t.TempDir(), "empty.pem")
+	if err := os.WriteFile(empty, []byte("not a certificate"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		options TransportOptions
+		want    string
+	}{
+		{"unsupported proxy scheme", TransportOptions{Proxy: "ftp://proxy.example"}, `unsupported scheme "ftp"`},
+		{"missing CA bundle", TransportOptions{CABundle: filepath.Join(t.TempDir(), "missing.pem")}, "reading CA bundle"},
+		{"CA bundle without certificates", TransportOptions{CABundle: empty}, "contains no PEM certificates"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewTransport(tt.options); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("NewTransport() error = %v, want error containing %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package providers
+
+import (
+	"errors"
+	"slices"
+)
+
+// ErrTruncated is matched by every TruncatedError.
+var ErrTruncated = errors.New("response truncated")
+
+// TruncatedError reports that the model stopped because it reached the
+// output token limit. Response holds the partial answer and the usage of
+// every request made for it.
+type TruncatedError struct {
+	Response Response
+}
+
+func (e *TruncatedError) Error() string {
+	return "response cut off at the output token limit; raise max_tokens or set max_continuations"
+}
+
+func (e *TruncatedError) Is(target error) bool {
+	return target == ErrTruncated
+}
+
+// continuePrompt asks the model to carry on after a truncated answer.
+const continuePrompt = "Your previous answer was cut off. Continue exactly where it stopped, without repeating anything."
+
+// withContinuations calls send with the conversation msgs and, while the
+// answer is truncated and o allows more continuations, sends it again with
+// the answer so far and a request to continue. The answers are joined and their usage
+// added up. If the final answer is still truncated, the joined response is
+// returned along with a *TruncatedError.
+func withContinuations(o options, conversation []Message, send func(msgs []Message) (Response, error)) (Response, error) {
+	msgs := conversation
+
+	var total Response
+	for i := 0; ; i++ {
+		resp, err := send(msgs)
+		total.Text += resp.Text
+		total.Usage = total.Usage.Add(resp.Usage)
+
+		var truncated *TruncatedError
+		if !errors.As(err, &truncated) {
+			if err != nil {
+				return total, err
+			}
+			return total, nil
+		}
+
+		if i >= o.maxContinuations {
+			return total, &TruncatedError{Response: total}
+		}
+
+		msgs = append(slices.Clip(conversation),
+			Message{Role: "assistant", Content: total.Text},
+			Message{Role: "user", Content: continuePrompt},
+		)
+	}
+}
@@ -0,0 +1,204 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestCompleteDetectsTruncation(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		provider func(endpoint string) Provider
+		wantText string
+	}{
+		{
+			name: "openai finish_reason length",
+			body: `{"choices":[{"message":{"content":"<title>Add"},"finish_reason":"length"}]}`,
+			provider: func(endpoint string) Provider {
+				return NewOpenAIProvider(endpoint, "gpt-4o-mini", "key")
+			},
+			wantText: "<title>Add",
+		},
+		{
+			name: "anthropic stop_reason max_tokens",
+			body: `{"content":[{"type":"text","text":"<title>Add"}],"stop_reason":"max_tokens"}`,
+			provider: func(endpoint string) Provider {
+				return NewAnthropicProvider(endpoint, "claude-haiku-4-5", "key")
+			},
+			wantText: "<title>Add",
+		},
+		{
+			name: "gemini finishReason MAX_TOKENS",
+			body: `{"candidates":[{"content":{"parts":[{"text":"<title>Add"}]},"finishReason":"MAX_TOKENS"}]}`,
+			provider: func(endpoint string) Provider {
+				return NewGeminiProvider(endpoint, "gemini-2.5-flash", "key")
+			},
+			wantText: "<title>Add",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_, _ = w.Write([]byte(tt.body))
+			}))
+			t.Cleanup(server.Close)
+
+			resp, err := tt.provider(server.URL).Complete(context.Background(), "", "hi")
+
+			var truncated *TruncatedError
+			if !errors.As(err, &truncated) || !errors.Is(err, ErrTruncated) {
+				t.Fatalf("Complete() error = %v, want *TruncatedError", err)
+			}
+			if resp.Text != tt.wantText || truncated.Response.Text != tt.wantText {
+				t.Errorf("Complete() text = %q, error text = %q, want %q", resp.Text, truncated.Response.Text, tt.wantText)
+			}
+		})
+	}
+}
+
+func TestStreamDetectsTruncation(t *testing.T) {
+	tests := []struct {
+		name     string
+		events   []string
+		provider func(endpoint string) Provider
+	}{
+		{
+			name: "openai",
+			events: []string{
+				`{"choices":[{"delta":{"content":"partial"}}]}`,
+				`{"choices":[{"delta":{},"finish_reason":"length"}]}`,
+				`[DONE]`,
+			},
+			provider: func(endpoint string) Provider {
+				return NewOpenAIProvider(endpoint, "gpt-4o-mini", "key")
+			},
+		},
+		{
+			name: "anthropic",
+			events: []string{
+				`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"partial"}}`,
+				`{"type":"message_delta","delta":{"stop_reason":"max_tokens"},"usage":{"output_tokens":16}}`,
+				`{"type":"message_stop"}`,
+			},
+			provider: func(endpoint string) Provider {
+				return NewAnthropicProvider(endpoint, "claude-haiku-4-5", "key")
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newStreamServer(t, tt.events)
+
+			var deltas string
+			resp, err := Stream(context.Background(), tt.provider(server.URL), "", "hi", func(delta string) error {
+				deltas += delta
+				return nil
+			})
+
+			if !errors.Is(err, ErrTruncated) {
+				t.Fatalf("Stream() error = %v, want ErrTruncated", err)
+			}
+			if resp.Text != "partial" || deltas != "partial" {
+				t.Errorf("Stream() text = %q, deltas = %q, want %q", resp.Text, deltas, "partial")
+			}
+		})
+	}
+}
+
+func TestGeminiStreamDetectsTruncation(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = w.Write([]byte("data: {\"candidates\":[{\"content\":{\"parts\":[{\"text\":\"partial\"}]},\"finishReason\":\"MAX_TOKENS\"}]}\n\n"))
+	}))
+	t.Cleanup(server.Close)
+
+	p := NewGeminiProvider(server.URL, "gemini-2.5-flash", "key")
+	resp, err := Stream(context.Background(), p, "", "hi", func(string) error { return nil })
+
+	if !errors.Is(err, ErrTruncated) || resp.Text != "partial" {
+		t.Errorf("Stream() = %q, %v, want partial text and ErrTruncated", resp.Text, err)
+	}
+}
+
+func TestAnthropicJoinsTextBlocks(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"content":[{"type":"text","text":"<title>Fix</title>"},{"type":"thinking","thinking":"hmm"},{"type":"text","text":"\n<body>Done</body>"}],"stop_reason":"end_turn"}`))
+	}))
+	t.Cleanup(server.Close)
+
+	resp, err := NewAnthropicProvider(server.URL, "claude-haiku-4-5", "key").Complete(context.Background(), "", "hi")
+	if err != nil {
+		t.Fatalf("Complete() error = %v, want nil", err)
+	}
+
+	if want := "<title>Fix</title>\n<body>Done</body>"; resp.Text != want {
+		t.Errorf("Complete() = %q, want %q", resp.Text, want)
+	}
+}
+
+func TestContinuesTruncatedResponses(t *testing.T) {
+	var requests []openaiRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req openaiRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("decoding request body: %v", err)
+		}
+		requests = append(requests, req)
+
+		if len(requests) < 3 {
+			_, _ = w.Write([]byte(`{"choices":[{"message":{"content":"part "},"finish_reason":"length"}],"usage":{"prompt_tokens":10,"completion_tokens":4}}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"choices":[{"message":{"content":"end"},"finish_reason":"stop"}],"usage":{"prompt_tokens":20,"completion_tokens":1}}`))
+	}))
+	t.Cleanup(server.Close)
+
+	t.Run("until complete", func(t *testing.T) {
+		requests = nil
+		p := NewOpenAIProvider(server.URL, "gpt-4o-mini", "key", WithMaxContinuations(2))
+
+		resp, err := p.Complete(context.Background(), "system", "question")
+		if err != nil {
+			t.Fatalf("Complete() error = %v, want nil", err)
+		}
+
+		if resp.Text != "part part end" {
+			t.Errorf("Complete() = %q, want %q", resp.Text, "part part end")
+		}
+		if resp.Usage.InputTokens != 40 || resp.Usage.OutputTokens != 9 {
+			t.Errorf("Complete() usage = %+v, want the sum of all three requests", resp.Usage)
+		}
+
+		wantLast := []Message{
+			{Role: "system", Content: "system"},
+			{Role: "user", Content: "question"},
+			{Role: "assistant", Content: "part part "},
+			{Role: "user", Content: continuePrompt},
+		}
+		if len(requests) != 3 || !reflect.DeepEqual(requests[2].Messages, wantLast) {
+			t.Errorf("last request messages = %#v, want %#v", requests[len(requests)-1].Messages, wantLast)
+		}
+	})
+
+	t.Run("gives up after the limit", func(t *testing.T) {
+		requests = nil
+		p := NewOpenAIProvider(server.URL, "gpt-4o-mini", "key", WithMaxContinuations(1))
+
+		resp, err := p.Complete(context.Background(), "", "question")
+		var truncated *TruncatedError
+		if !errors.As(err, &truncated) {
+			t.Fatalf("Complete() error = %v, want *TruncatedError", err)
+		}
+		if resp.Text != "part part " || truncated.Response.Text != "part part " || len(requests) != 2 {
+			t.Errorf("Complete() = %q after %d requests, want both parts after 2", resp.Text, len(requests))
+		}
+	})
+}
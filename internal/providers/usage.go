@@ -0,0 +1,142 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Usage describes the resources used by one or more completions. Fields a
+// provider does not report are left zero.
+type Usage struct {
+	Provider     string
+	Model        string
+	InputTokens  int
+	OutputTokens int
+	// Cost is the price in USD as reported by the provider. Only
+	// OpenRouter reports it.
+	Cost float64
+}
+
+// ReportsCost reports whether the named provider returns the cost of its
+// completions in Usage.Cost.
+func ReportsCost(provider string) bool {
+	return provider == "openrouter"
+}
+
+// Add returns the sum of u and other. Provider and model are taken from
+// other when it has them, so the result names the most recent one.
+func (u Usage) Add(other Usage) Usage {
+	if other.Provider != "" {
+		u.Provider = other.Provider
+	}
+	if other.Model != "" {
+		u.Model = other.Model
+	}
+	u.InputTokens += other.InputTokens
+	u.OutputTokens += other.OutputTokens
+	u.Cost += other.Cost
+	return u
+}
+
+func (u Usage) String() string {
+	parts := []string{fmt.Sprintf("%d input + %d output tokens", u.InputTokens, u.OutputTokens)}
+
+	switch {
+	case u.Model != "" && u.Provider != "":
+		parts = append(parts, fmt.Sprintf("%s via %s", u.Model, u.Provider))
+	case u.Model != "":
+		parts = append(parts, u.Model)
+	case u.Provider != "":
+		parts = append(parts, u.Provider)
+	}
+
+	if u.Cost > 0 {
+		parts = append(parts, fmt.Sprintf("$%.6f", u.Cost))
+	}
+
+	return strings.Join(parts, ", ")
+}
+
+// describer is implemented by providers that know which provider and
+// model they will use before a request is made.
+type describer interface {
+	describe() Usage
+}
+
+// Describe returns the provider name and configured model of p, with no
+// token counts. Fields are empty for providers that cannot tell, and for a
+// fallback chain they describe its first provider.
+func Describe(p Provider) Usage {
+	if d, ok := p.(describer); ok {
+		return d.describe()
+	}
+	return Usage{}
+}
+
+// Meter wraps a provider and adds up the usage of every completion made
+// through it. It is safe for concurrent use.
+type Meter struct {
+	provider Provider
+
+	mu    sync.Mutex
+	calls int
+	usage Usage
+}
+
+// NewMeter returns a Meter that forwards completions to p.
+func NewMeter(p Provider) *Meter {
+	return &Meter{provider: p}
+}
+
+func (m *Meter) Complete(ctx context.Context, system, userMsg string) (Response, error) {
+	return m.Chat(ctx, system, userTurn(userMsg))
+}
+
+func (m *Meter) Chat(ctx context.Context, system string, msgs []Message) (Response, error) {
+	resp, err := Chat(ctx, m.provider, system, msgs)
+	m.record(resp, err)
+	return resp, err
+}
+
+func (m *Meter) Stream(ctx context.Context, system, userMsg string, onDelta func(string) error) (Response, error) {
+	return m.StreamChat(ctx, system, userTurn(userMsg), onDelta)
+}
+
+func (m *Meter) StreamChat(ctx context.Context, system string, msgs []Message, onDelta func(string) error) (Response, error) {
+	resp, err := StreamChat(ctx, m.provider, system, msgs, onDelta)
+	m.record(resp, err)
+	return resp, err
+}
+
+func (m *Meter) describe() Usage {
+	return Describe(m.provider)
+}
+
+// Calls returns the number of successful or truncated completions.
+func (m *Meter) Calls() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.calls
+}
+
+// Usage returns the total usage of all successful or truncated completions.
+func (m *Meter) Usage() Usage {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.usage
+}
+
+func (m *Meter) record(resp Response, err error) {
+	// Truncated answers were paid for even though they failed.
+	if err != nil && !errors.Is(err, ErrTruncated) {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.calls++
+	m.usage = m.usage.Add(resp.Usage)
+}
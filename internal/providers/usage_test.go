@@ -0,0 +1,204 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCompleteReportsUsage(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		provider func(endpoint string) Provider
+		want     Usage
+	}{
+		{
+			name: "openrouter reports cost",
+			body: `{"model":"anthropic/claude-4.5-haiku-20251001","choices":[{"message":{"content":"ok"}}],"usage":{"prompt_tokens":1200,"completion_tokens":40,"cost":0.00142}}`,
+			provider: func(endpoint string) Provider {
+				return NewOpenRouterProvider(endpoint, "anthropic/claude-haiku-4.5", "key")
+			},
+			want: Usage{Provider: "openrouter", Model: "anthropic/claude-4.5-haiku-20251001", InputTokens: 1200, OutputTokens: 40, Cost: 0.00142},
+		},
+		{
+			name: "openai without usage keeps configured model",
+			body: `{"choices":[{"message":{"content":"ok"}}]}`,
+			provider: func(endpoint string) Provider {
+				return NewOpenAIProvider(endpoint, "gpt-4o-mini", "key")
+			},
+			want: Usage{Provider: "openai", Model: "gpt-4o-mini"},
+		},
+		{
+			name: "anthropic",
+			body: `{"model":"claude-haiku-4-5-20251001","content":[{"type":"text","text":"ok"}],"usage":{"input_tokens":900,"output_tokens":25}}`,
+			provider: func(endpoint string) Provider {
+				return NewAnthropicProvider(endpoint, "claude-haiku-4-5", "key")
+			},
+			want: Usage{Provider: "anthropic", Model: "claude-haiku-4-5-20251001", InputTokens: 900, OutputTokens: 25},
+		},
+		{
+			name: "gemini",
+			body: `{"candidates":[{"content":{"parts":[{"text":"ok"}]}}],"modelVersion":"gemini-2.5-flash-001","usageMetadata":{"promptTokenCount":700,"candidatesTokenCount":12}}`,
+			provider: func(endpoint string) Provider {
+				return NewGeminiProvider(endpoint, "gemini-2.5-flash", "key")
+			},
+			want: Usage{Provider: "gemini", Model: "gemini-2.5-flash-001", InputTokens: 700, OutputTokens: 12},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_, _ = w.Write([]byte(tt.body))
+			}))
+			t.Cleanup(server.Close)
+
+			resp, err := tt.provider(server.URL).Complete(context.Background(), "", "hi")
+			if err != nil {
+				t.Fatalf("Complete() error = %v, want nil", err)
+			}
+
+			if resp.Usage != tt.want {
+				t.Errorf("Complete() usage = %+v, want %+v", resp.Usage, tt.want)
+			}
+		})
+	}
+}
+
+func TestStreamReportsUsage(t *testing.T) {
+	tests := []struct {
+		name     string
+		events   []string
+		provider func(endpoint string) Provider
+		want     Usage
+	}{
+		{
+			name: "openai usage chunk",
+			events: []string{
+				`{"model":"gpt-4o-mini-2024-07-18","choices":[{"delta":{"content":"ok"}}]}`,
+				`{"model":"gpt-4o-mini-2024-07-18","choices":[],"usage":{"prompt_tokens":50,"completion_tokens":2}}`,
+				`[DONE]`,
+			},
+			provider: func(endpoint string) Provider {
+				return NewOpenAIProvider(endpoint, "gpt-4o-mini", "key")
+			},
+			want: Usage{Provider: "openai", Model: "gpt-4o-mini-2024-07-18", InputTokens: 50, OutputTokens: 2},
+		},
+		{
+			name: "anthropic message start and delta",
+			events: []string{
+				`{"type":"message_start","message":{"model":"claude-haiku-4-5-20251001","usage":{"input_tokens":310,"output_tokens":1}}}`,
+				`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"ok"}}`,
+				`{"type":"message_delta","delta":{"stop_reason":"end_turn"},"usage":{"output_tokens":18}}`,
+				`{"type":"message_stop"}`,
+			},
+			provider: func(endpoint string) Provider {
+				return NewAnthropicProvider(endpoint, "claude-haiku-4-5", "key")
+			},
+			want: Usage{Provider: "anthropic", Model: "claude-haiku-4-5-20251001", InputTokens: 310, OutputTokens: 18},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newStreamServer(t, tt.events)
+
+			resp, err := Stream(context.Background(), tt.provider(server.URL), "", "hi", func(string) error { return nil })
+			if err != nil {
+				t.Fatalf("Stream() error = %v, want nil", err)
+			}
+
+			if resp.Usage != tt.want {
+				t.Errorf("Stream() usage = %+v, want %+v", resp.Usage, tt.want)
+			}
+		})
+	}
+}
+
+type usageStubProvider struct {
+	usage Usage
+	err   error
+}
+
+func (p *usageStubProvider) Complete(ctx context.Context, system, userMsg string) (Response, error) {
+	return Response{Text: "ok", Usage: p.usage}, p.err
+}
+
+func TestMeter(t *testing.T) {
+	p := &usageStubProvider{usage: Usage{Provider: "openrouter", Model: "gpt-4o-mini", InputTokens: 100, OutputTokens: 10, Cost: 0.001}}
+	m := NewMeter(p)
+
+	for range 2 {
+		if _, err := m.Complete(context.Background(), "", "hi"); err != nil {
+			t.Fatalf("Complete() error = %v, want nil", err)
+		}
+	}
+
+	p.err = errors.New("boom")
+	if _, err := m.Stream(context.Background(), "", "hi", func(string) error { return nil }); err == nil {
+		t.Fatal("Stream() error = nil, want error")
+	}
+
+	if got := m.Calls(); got != 2 {
+		t.Errorf("Calls() = %d, want 2", got)
+	}
+
+	want := Usage{Provider: "openrouter", Model: "gpt-4o-mini", InputTokens: 200, OutputTokens: 20, Cost: 0.002}
+	if got := m.Usage(); got != want {
+		t.Errorf("Usage() = %+v, want %+v", got, want)
+	}
+}
+
+func TestDescribe(t *testing.T) {
+	anthropic := NewAnthropicProvider("https://api.anthropic.com/v1/messages", "claude-haiku-4-5", "key")
+	azure := NewAzureOpenAIProvider("https://example.openai.azure.com", "gpt-4o-prod", "2024-10-21", "key")
+
+	tests := []struct {
+		name     string
+		provider Provider
+		want     Usage
+	}{
+		{name: "provider", provider: anthropic, want: Usage{Provider: "anthropic", Model: "claude-haiku-4-5"}},
+		{name: "azure deployment", provider: azure, want: Usage{Provider: "azure", Model: "gpt-4o-prod"}},
+		{name: "meter", provider: NewMeter(anthropic), want: Usage{Provider: "anthropic", Model: "claude-haiku-4-5"}},
+		{
+			name:     "fallback chain",
+			provider: NewFallbackProvider(nil, Fallback{Name: "azure", Provider: azure}, Fallback{Name: "anthropic", Provider: anthropic}),
+			want:     Usage{Provider: "azure", Model: "gpt-4o-prod"},
+		},
+		{name: "unknown", provider: &usageStubProvider{}, want: Usage{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Describe(tt.provider); got != tt.want {
+				t.Errorf("Describe() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUsageString(t *testing.T) {
+	tests := []struct {
+		usage Usage
+		want  string
+	}{
+		{
+			usage: Usage{Provider: "openrouter", Model: "anthropic/claude-haiku-4.5", InputTokens: 1200, OutputTokens: 40, Cost: 0.00142},
+			want:  "1200 input + 40 output tokens, anthropic/claude-haiku-4.5 via openrouter, $0.001420",
+		},
+		{
+			usage: Usage{Provider: "local"},
+			want:  "0 input + 0 output tokens, local",
+		},
+	}
+
+	for _, tt := range tests {
+		if got := tt.usage.String(); got != tt.want {
+			t.Errorf("String() = %q, want %q", got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package session
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+)
+
+// List writes a table of the sessions in store and marks the last used
+// one with a star.
+func List(store *Store, stdout io.Writer, args []string) error {
+	if len(args) > 0 {
+		return fmt.Errorf("usage: llm sessions list")
+	}
+
+	sessions, err := store.List()
+	if err != nil {
+		return err
+	}
+	if len(sessions) == 0 {
+		_, err := fmt.Fprintln(stdout, "No sessions saved.")
+		return err
+	}
+
+	last, _ := store.Last()
+	w := tabwriter.NewWriter(stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "  NAME\tTURNS\tUPDATED\tMODEL")
+	for _, sess := range sessions {
+		mark := " "
+		if sess.Name == last {
+			mark = "*"
+		}
+		_, _ = fmt.Fprintf(w, "%s %s\t%d\t%s\t%s\n", mark, sess.Name, sess.Turns(), sess.Updated.Local().Format(time.DateTime), describe(sess))
+	}
+	return w.Flush()
+}
+
+// Show writes the conversation of the named session, or of the last used
+// one, as Markdown.
+func Show(store *Store, stdout io.Writer, args []string) error {
+	var name string
+	switch len(args) {
+	case 0:
+		last, err := store.Last()
+		if err != nil {
+			return err
+		}
+		name = last
+	case 1:
+		name = args[0]
+	default:
+		return fmt.Errorf("usage: llm sessions show [name]")
+	}
+
+	sess, err := store.Load(name)
+	if err != nil {
+		return err
+	}
+
+	_, _ = fmt.Fprintf(stdout, "# %s\n\n%s, updated %s\n\n", sess.Name, describe(sess), sess.Updated.Local().Format(time.DateTime))
+	return WriteTranscript(stdout, "", sess.Messages)
+}
+
+// Remove deletes the sessions named in args.
+func Remove(store *Store, stdout io.Writer, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: llm sessions rm <name>...")
+	}
+
+	for _, name := range args {
+		if err := store.Remove(name); err != nil {
+			return err
+		}
+		_, _ = fmt.Fprintf(stdout, "Removed session %s\n", name)
+	}
+	return nil
+}
+
+func describe(sess *Session) string {
+	switch {
+	case sess.Model == "":
+		return "unknown model"
+	case sess.Provider == "":
+		return sess.Model
+	}
+	return sess.Model + " via " + sess.Provider
+}
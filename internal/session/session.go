@@ -0,0 +1,228 @@
+// Package session stores conversations on disk so that a later command
+// can continue them.
+package session
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"llm/internal/providers"
+	"llm/internal/xdg"
+)
+
+// ErrNotFound is returned for a session that does not exist.
+var ErrNotFound = errors.New("session not found")
+
+// Default is the session that a question asked without naming one starts
+// afresh, replacing the previous unnamed conversation.
+const Default = "default"
+
+// lastFile records the name of the session used most recently.
+const lastFile = "last"
+
+var validName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// Session is a conversation and the provider and model it was held with.
+type Session struct {
+	Name     string              `json:"name"`
+	Provider string              `json:"provider,omitempty"`
+	Model    string              `json:"model,omitempty"`
+	Messages []providers.Message `json:"messages"`
+	Created  time.Time           `json:"created"`
+	Updated  time.Time           `json:"updated"`
+}
+
+// Turns returns the number of questions asked in s.
+func (s *Session) Turns() int {
+	n := 0
+	for _, msg := range s.Messages {
+		if msg.Role == "user" {
+			n++
+		}
+	}
+	return n
+}
+
+// Store keeps sessions as JSON files in a directory, one per session.
+type Store struct {
+	dir string
+	now func() time.Time
+}
+
+// NewStore returns a store in dir. The directory is created on the first
+// Save.
+func NewStore(dir string) *Store {
+	return &Store{dir: dir, now: time.Now}
+}
+
+// DefaultDir returns $XDG_STATE_HOME/llm/sessions.
+func DefaultDir() (string, error) {
+	dir, err := xdg.StateHome()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "llm", "sessions"), nil
+}
+
+// CheckName rejects names that cannot be used as file names.
+func CheckName(name string) error {
+	if !validName.MatchString(name) || name == lastFile {
+		return fmt.Errorf("invalid session name %q: use letters, digits, '.', '_' and '-'", name)
+	}
+	return nil
+}
+
+// Load returns the named session.
+func (s *Store) Load(name string) (*Session, error) {
+	if err := CheckName(name); err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(s.path(name))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var sess Session
+	if err := json.Unmarshal(data, &sess); err != nil {
+		return nil, fmt.Errorf("parsing session %s: %w", name, err)
+	}
+	return &sess, nil
+}
+
+// Save writes sess and makes it the last used session.
+func (s *Store) Save(sess *Session) error {
+	if err := CheckName(sess.Name); err != nil {
+		return err
+	}
+
+	now := s.now()
+	if sess.Created.IsZero() {
+		sess.Created = now
+	}
+	sess.Updated = now
+
+	data, err := json.MarshalIndent(sess, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(s.dir, 0o700); err != nil {
+		return err
+	}
+	if err := writeFile(s.path(sess.Name), append(data, '\n')); err != nil {
+		return err
+	}
+	return writeFile(filepath.Join(s.dir, lastFile), []byte(sess.Name+"\n"))
+}
+
+// Last returns the name of the session used most recently.
+func (s *Store) Last() (string, error) {
+	data, err := os.ReadFile(filepath.Join(s.dir, lastFile))
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", fmt.Errorf("%w: no conversation to continue", ErrNotFound)
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// List returns every session, most recently updated first.
+func (s *Store) List() ([]*Session, error) {
+	paths, err := filepath.Glob(filepath.Join(s.dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	sessions := make([]*Session, 0, len(paths))
+	for _, path := range paths {
+		sess, err := s.Load(strings.TrimSuffix(filepath.Base(path), ".json"))
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, sess)
+	}
+
+	slices.SortFunc(sessions, func(a, b *Session) int {
+		return cmp.Or(b.Updated.Compare(a.Updated), strings.Compare(a.Name, b.Name))
+	})
+	return sessions, nil
+}
+
+// Remove deletes the named session.
+func (s *Store) Remove(name string) error {
+	if err := CheckName(name); err != nil {
+		return err
+	}
+
+	err := os.Remove(s.path(name))
+	if errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+	if err != nil {
+		return err
+	}
+
+	if last, err := s.Last(); err == nil && last == name {
+		if err := os.Remove(filepath.Join(s.dir, lastFile)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Store) path(name string) string {
+	return filepath.Join(s.dir, name+".json")
+}
+
+// writeFile replaces path with data through a temporary file, so that an
+// interrupted write never leaves a session half written.
+func writeFile(path string, data []byte) error {
+	f, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(f.Name()) }()
+
+	if _, err := f.Write(data); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// WriteTranscript writes a conversation as Markdown, with a heading for
+// each turn.
+func WriteTranscript(w io.Writer, system string, msgs []providers.Message) error {
+	var b strings.Builder
+	if system != "" {
+		fmt.Fprintf(&b, "## System\n\n%s\n\n", strings.TrimSpace(system))
+	}
+	for _, msg := range msgs {
+		heading := "You"
+		if msg.Role == "assistant" {
+			heading = "Assistant"
+		}
+		fmt.Fprintf(&b, "## %s\n\n%s\n\n", heading, strings.TrimSpace(msg.Content))
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,181 @@
+package session
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"llm/internal/providers"
+)
+
+func newTestStore(t *testing.T) *Store {
+	t.Helper()
+
+	store := NewStore(filepath.Join(t.TempDir(), "sessions"))
+	now := time.Date(2026, 1, 2, 15, 4, 5, 0, time.UTC)
+	store.now = func() time.Time {
+		now = now.Add(time.Minute)
+		return now
+	}
+	return store
+}
+
+func conversation(turns ...string) []providers.Message {
+	msgs := make([]providers.Message, len(turns))
+	for i, content := range turns {
+		role := "user"
+		if i%2 == 1 {
+			role = "assistant"
+		}
+		msgs[i] = providers.Message{Role: role, Content: content}
+	}
+	return msgs
+}
+
+func TestStoreSaveAndLoad(t *testing.T) {
+	store := newTestStore(t)
+
+	if _, err := store.Last(); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Last() on an empty store error = %v, want ErrNotFound", err)
+	}
+
+	sess := &Session{Name: "work", Provider: "openai", Model: "gpt-4o", Messages: conversation("hi", "hello")}
+	if err := store.Save(sess); err != nil {
+		t.Fatalf("Save() error = %v, want nil", err)
+	}
+	created := sess.Created
+
+	sess.Messages = append(sess.Messages, conversation("more")...)
+	if err := store.Save(sess); err != nil {
+		t.Fatalf("Save() error = %v, want nil", err)
+	}
+
+	got, err := store.Load("work")
+	if err != nil {
+		t.Fatalf("Load() error = %v, want nil", err)
+	}
+	if !reflect.DeepEqual(got.Messages, sess.Messages) || got.Provider != "openai" || got.Model != "gpt-4o" {
+		t.Errorf("Load() = %+v, want %+v", got, sess)
+	}
+	if !got.Created.Equal(created) || !got.Updated.After(created) {
+		t.Errorf("Load() created = %v, updated = %v, want the first save and a later one", got.Created, got.Updated)
+	}
+	if got.Turns() != 2 {
+		t.Errorf("Turns() = %d, want 2", got.Turns())
+	}
+
+	if last, err := store.Last(); err != nil || last != "work" {
+		t.Errorf("Last() = %q, %v, want work", last, err)
+	}
+
+	info, err := os.Stat(store.dir)
+	if err != nil {
+		t.Fatalf("Stat() error = %v", err)
+	}
+	if perm := info.Mode().Perm(); perm != 0o700 {
+		t.Errorf("session directory mode = %v, want 0700", perm)
+	}
+
+	if _, err := store.Load("other"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Load(other) error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestStoreRejectsInvalidNames(t *testing.T) {
+	store := newTestStore(t)
+
+	for _, name := range []string{"", "../escape", "a/b", ".hidden", "last"} {
+		if err := store.Save(&Session{Name: name}); err == nil {
+			t.Errorf("Save(%q) error = nil, want invalid name", name)
+		}
+		if _, err := store.Load(name); err == nil || errors.Is(err, ErrNotFound) {
+			t.Errorf("Load(%q) error = %v, want invalid name", name, err)
+		}
+	}
+}
+
+func TestStoreListAndRemove(t *testing.T) {
+	store := newTestStore(t)
+
+	for _, name := range []string{"first", "second", "third"} {
+		if err := store.Save(&Session{Name: name}); err != nil {
+			t.Fatalf("Save(%s) error = %v", name, err)
+		}
+	}
+
+	sessions, err := store.List()
+	if err != nil {
+		t.Fatalf("List() error = %v, want nil", err)
+	}
+	var names []string
+	for _, sess := range sessions {
+		names = append(names, sess.Name)
+	}
+	if want := []string{"third", "second", "first"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("List() names = %v, want %v", names, want)
+	}
+
+	if err := store.Remove("third"); err != nil {
+		t.Fatalf("Remove() error = %v, want nil", err)
+	}
+	if _, err := store.Last(); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Last() after removing it error = %v, want ErrNotFound", err)
+	}
+	if err := store.Remove("third"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Remove() twice error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestCommands(t *testing.T) {
+	store := newTestStore(t)
+
+	var stdout bytes.Buffer
+	if err := List(store, &stdout, nil); err != nil || stdout.String() != "No sessions saved.\n" {
+		t.Fatalf("List() on an empty store = %q, %v", stdout.String(), err)
+	}
+
+	for _, sess := range []*Session{
+		{Name: "old", Messages: conversation("a", "b")},
+		{Name: "work", Provider: "anthropic", Model: "claude-haiku-4-5", Messages: conversation("What is Go?", "A language.", "Who made it?", "Google.")},
+	} {
+		if err := store.Save(sess); err != nil {
+			t.Fatalf("Save(%s) error = %v", sess.Name, err)
+		}
+	}
+
+	stdout.Reset()
+	if err := List(store, &stdout, nil); err != nil {
+		t.Fatalf("List() error = %v, want nil", err)
+	}
+	lines := strings.Split(strings.TrimSuffix(stdout.String(), "\n"), "\n")
+	if len(lines) != 3 || !strings.HasPrefix(lines[1], "* work  ") || !strings.HasSuffix(lines[1], "claude-haiku-4-5 via anthropic") || !strings.HasPrefix(lines[2], "  old ") {
+		t.Errorf("List() output =\n%s", stdout.String())
+	}
+
+	stdout.Reset()
+	if err := Show(store, &stdout, nil); err != nil {
+		t.Fatalf("Show() error = %v, want nil", err)
+	}
+	out := stdout.String()
+	for _, want := range []string{"# work\n\nclaude-haiku-4-5 via anthropic, updated ", "## You\n\nWhat is Go?\n\n## Assistant\n\nA language.\n\n"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Show() output missing %q:\n%s", want, out)
+		}
+	}
+
+	stdout.Reset()
+	if err := Remove(store, &stdout, []string{"old", "work"}); err != nil {
+		t.Fatalf("Remove() error = %v, want nil", err)
+	}
+	if stdout.String() != "Removed session old\nRemoved session work\n" {
+		t.Errorf("Remove() output = %q", stdout.String())
+	}
+	if err := Show(store, &stdout, nil); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Show() after removing every session error = %v, want ErrNotFound", err)
+	}
+}
@@ -0,0 +1,42 @@
+package sh
+
+import "regexp"
+
+// risks are patterns of commands that destroy data, rewrite history or
+// reach beyond the current user, each with a warning for the user.
+var risks = []struct {
+	pattern *regexp.Regexp
+	warning string
+}{
+	{regexp.MustCompile(`\brm\b`), "rm deletes files"},
+	{regexp.MustCompile(`\bfind\b.*\s-delete\b`), "find -delete deletes files"},
+	{regexp.MustCompile(`\bshred\b`), "shred destroys files"},
+	{regexp.MustCompile(`\btruncate\b`), "truncate cuts files short"},
+	{regexp.MustCompile(`\bdd\b.*\bof=`), "dd overwrites its output file or device"},
+	{regexp.MustCompile(`\bmkfs\b`), "mkfs formats a file system"},
+	{regexp.MustCompile(`>\s*/dev/(sd|nvme|disk|hd|mmcblk)`), "writes to a disk device"},
+	{regexp.MustCompile(`\bgit\s+push\b.*(\s--force\b|\s--force-with-lease\b|\s-f\b|\s\+\S)`), "git push --force rewrites remote history"},
+	{regexp.MustCompile(`\bgit\s+reset\b.*\s--hard\b`), "git reset --hard discards uncommitted changes"},
+	{regexp.MustCompile(`\bgit\s+clean\b.*\s-[a-zA-Z]*f`), "git clean deletes untracked files"},
+	{regexp.MustCompile(`\bgit\s+(checkout|restore)\b.*\s(--\s+)?\.(\s|$)`), "git checkout discards uncommitted changes"},
+	{regexp.MustCompile(`\bgit\s+branch\b.*\s-D\b`), "git branch -D deletes unmerged branches"},
+	{regexp.MustCompile(`\b(chmod|chown|chgrp)\b.*\s-[a-zA-Z]*R`), "changes ownership or permissions recursively"},
+	{regexp.MustCompile(`\bsudo\b`), "sudo runs the command as root"},
+	{regexp.MustCompile(`\b(kill|pkill|killall)\b`), "stops running processes"},
+	{regexp.MustCompile(`\b(curl|wget)\b[^|]*\|\s*(sudo\s+)?(ba|z)?sh\b`), "runs a script straight from the network"},
+	{regexp.MustCompile(`\b(docker|podman)\b.*\bprune\b`), "prune deletes containers, images or volumes"},
+	{regexp.MustCompile(`\bkubectl\s+delete\b`), "kubectl delete removes cluster resources"},
+	{regexp.MustCompile(`(?i)\b(drop\s+(table|database|schema)|truncate\s+table)\b`), "drops database data"},
+}
+
+// Risks returns a warning for each destructive operation in command, or
+// nil when none is recognised.
+func Risks(command string) []string {
+	var warnings []string
+	for _, r := range risks {
+		if r.pattern.MatchString(command) {
+			warnings = append(warnings, r.warning)
+		}
+	}
+	return warnings
+}
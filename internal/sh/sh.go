@@ -0,0 +1,308 @@
+// Package sh implements llm sh, which turns a request written in plain
+// language into a shell command and offers to run, edit or copy it.
+package sh
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	_ "embed"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+
+	"llm/internal/loading"
+	"llm/internal/providers"
+)
+
+//go:embed prompt.md
+var systemPrompt string
+
+var suggestionPattern = regexp.MustCompile(`(?s)<command>\s*(.*?)\s*</command>\s*<explanation>\s*(.*?)\s*</explanation>`)
+
+var (
+	runShell      = runShellExec
+	editCommand   = editCommandExec
+	copyCommand   = copyCommandExec
+	lookPath      = exec.LookPath
+	osReleaseFile = "/etc/os-release"
+)
+
+// Env is what the model is told about where the command will run.
+type Env struct {
+	OS    string
+	Shell string
+	Dir   string
+}
+
+// CurrentEnv describes the operating system, the user's shell from $SHELL
+// and the working directory.
+func CurrentEnv() Env {
+	env := Env{OS: runtime.GOOS, Shell: os.Getenv("SHELL")}
+	if name := distribution(); name != "" {
+		env.OS += " (" + name + ")"
+	}
+	if env.Shell == "" {
+		env.Shell = "/bin/sh"
+	}
+	env.Dir, _ = os.Getwd()
+	return env
+}
+
+// distribution returns the name of the Linux distribution, if any.
+func distribution() string {
+	data, err := os.ReadFile(osReleaseFile)
+	if err != nil {
+		return ""
+	}
+	for line := range strings.Lines(string(data)) {
+		if value, ok := strings.CutPrefix(strings.TrimSpace(line), "PRETTY_NAME="); ok {
+			return strings.Trim(value, `"'`)
+		}
+	}
+	return ""
+}
+
+func BuildPrompt(request string, env Env) string {
+	return fmt.Sprintf("OS: %s\nShell: %s\nWorking directory: %s\n\nRequest: %s", env.OS, env.Shell, env.Dir, request)
+}
+
+// Suggestion is a command proposed for a request.
+type Suggestion struct {
+	Command     string
+	Explanation string
+}
+
+func GenerateSuggestion(ctx context.Context, provider providers.Provider, prompt string, stderr io.Writer) (*Suggestion, error) {
+	ind := loading.Start(stderr)
+	resp, err := provider.Complete(ctx, systemPrompt, prompt)
+	ind.Stop()
+
+	if errors.Is(err, providers.ErrTruncated) {
+		return nil, fmt.Errorf("suggested command was cut off: %w", err)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	s, err := parseSuggestion(resp.Text)
+	if err != nil {
+		return nil, fmt.Errorf("parsing suggested command: %w", err)
+	}
+	return s, nil
+}
+
+// Run asks for a command that does what args describe, writes it to
+// stdout with an explanation and any risks on stderr, and then asks on
+// stdin whether to run, edit, copy or discard it. The command runs in the
+// user's shell and fails when the command does.
+func Run(ctx context.Context, provider providers.Provider, stdin io.Reader, stdout, stderr io.Writer, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: llm sh <request>")
+	}
+
+	env := CurrentEnv()
+	s, err := GenerateSuggestion(ctx, provider, BuildPrompt(strings.Join(args, " "), env), stderr)
+	if err != nil {
+		return err
+	}
+	if s.Command == "" {
+		return fmt.Errorf("no command suggested: %s", s.Explanation)
+	}
+
+	_, _ = fmt.Fprintf(stderr, "%s\n\n", s.Explanation)
+
+	answers := bufio.NewReader(stdin)
+	for {
+		_, _ = fmt.Fprintf(stdout, "%s\n", s.Command)
+		warnings := Risks(s.Command)
+		for _, w := range warnings {
+			_, _ = fmt.Fprintf(stderr, "Warning: %s\n", w)
+		}
+
+		choice, err := ask(ctx, answers, stderr, "[r]un, [e]dit, [c]opy or [q]uit? ")
+		if err != nil {
+			return err
+		}
+
+		switch choice {
+		case "r", "run":
+			if len(warnings) > 0 {
+				confirm, err := ask(ctx, answers, stderr, "This command may be destructive. Type yes to run it: ")
+				if err != nil {
+					return err
+				}
+				if confirm != "yes" {
+					_, _ = fmt.Fprintln(stderr, "Not run.")
+					return nil
+				}
+			}
+			return runShell(ctx, env.Shell, s.Command, rest(answers, stdin), stdout, stderr)
+		case "e", "edit":
+			edited, err := editCommand(s.Command, rest(answers, stdin), stdout, stderr)
+			if err != nil {
+				return err
+			}
+			if edited == "" {
+				_, _ = fmt.Fprintln(stderr, "Empty command, nothing to run.")
+				return nil
+			}
+			s.Command = edited
+		case "c", "copy":
+			if err := copyCommand(s.Command); err != nil {
+				return err
+			}
+			_, _ = fmt.Fprintln(stderr, "Copied to the clipboard.")
+			return nil
+		case "", "q", "quit", "n", "no":
+			return nil
+		default:
+			_, _ = fmt.Fprintf(stderr, "Unknown choice %q.\n", choice)
+		}
+	}
+}
+
+// ask writes prompt and returns the answer read from answers, lower case
+// and trimmed. The end of the input counts as an empty answer. The line is
+// read in the background so that waiting for it ends when ctx is done.
+func ask(ctx context.Context, answers *bufio.Reader, stderr io.Writer, prompt string) (string, error) {
+	_, _ = io.WriteString(stderr, prompt)
+
+	type answer struct {
+		line string
+		err  error
+	}
+	read := make(chan answer, 1)
+	go func() {
+		line, err := answers.ReadString('\n')
+		read <- answer{line, err}
+	}()
+
+	var a answer
+	select {
+	case <-ctx.Done():
+		_, _ = fmt.Fprintln(stderr)
+		return "", ctx.Err()
+	case a = <-read:
+	}
+
+	line, err := a.line, a.err
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", fmt.Errorf("reading answer: %w", err)
+	}
+	if errors.Is(err, io.EOF) {
+		_, _ = fmt.Fprintln(stderr)
+	}
+	return strings.ToLower(strings.TrimSpace(line)), nil
+}
+
+// rest returns what is left of stdin once the answers have been read from
+// it. That is stdin itself unless answers has read ahead, which is left as
+// it is so that a terminal is handed over directly.
+func rest(answers *bufio.Reader, stdin io.Reader) io.Reader {
+	if answers.Buffered() > 0 {
+		return answers
+	}
+	return stdin
+}
+
+func parseSuggestion(raw string) (*Suggestion, error) {
+	m := suggestionPattern.FindStringSubmatch(raw)
+	if len(m) != 3 {
+		return nil, fmt.Errorf("expected <command> and <explanation> tags in provider response")
+	}
+
+	command := strings.TrimSpace(m[1])
+	command = strings.TrimSpace(strings.TrimSuffix(strings.TrimPrefix(command, "`"), "`"))
+
+	return &Suggestion{Command: command, Explanation: strings.TrimSpace(m[2])}, nil
+}
+
+func runShellExec(ctx context.Context, shell, command string, stdin io.Reader, stdout, stderr io.Writer) error {
+	cmd := exec.CommandContext(ctx, shell, "-c", command)
+	cmd.Stdin = stdin
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return fmt.Errorf("command exited with status %d", exitErr.ExitCode())
+	}
+	return err
+}
+
+// editCommandExec opens command in $VISUAL or $EDITOR with the given
+// streams and returns what was saved.
+func editCommandExec(command string, stdin io.Reader, stdout, stderr io.Writer) (string, error) {
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		editor = "vi"
+	}
+
+	f, err := os.CreateTemp("", "llm-sh-*.sh")
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = os.Remove(f.Name()) }()
+
+	if _, err := f.WriteString(command + "\n"); err != nil {
+		_ = f.Close()
+		return "", err
+	}
+	if err := f.Close(); err != nil {
+		return "", err
+	}
+
+	// The editor may carry arguments, as in "code --wait".
+	cmd := exec.Command("/bin/sh", "-c", editor+` "$1"`, "sh", f.Name())
+	cmd.Stdin = stdin
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("running %s: %w", filepath.Base(editor), err)
+	}
+
+	data, err := os.ReadFile(f.Name())
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// clipboards are the commands tried in turn to copy to the clipboard.
+var clipboards = [][]string{
+	{"pbcopy"},
+	{"wl-copy"},
+	{"xclip", "-selection", "clipboard"},
+	{"xsel", "--clipboard", "--input"},
+	{"clip.exe"},
+}
+
+func copyCommandExec(command string) error {
+	for _, args := range clipboards {
+		path, err := lookPath(args[0])
+		if err != nil {
+			continue
+		}
+
+		cmd := exec.Command(path, args[1:]...)
+		cmd.Stdin = strings.NewReader(command)
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("%s: %s", args[0], strings.TrimSpace(stderr.String()))
+		}
+		return nil
+	}
+	return fmt.Errorf("no clipboard tool found; install wl-copy, xclip or xsel")
+}
@@ -0,0 +1,228 @@
+package sh
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"llm/internal/providers"
+)
+
+type stubProvider struct {
+	resp   string
+	prompt string
+}
+
+func (s *stubProvider) Complete(ctx context.Context, system, userMsg string) (providers.Response, error) {
+	s.prompt = userMsg
+	return providers.Response{Text: s.resp}, nil
+}
+
+func suggest(command string) string {
+	return "<command>" + command + "</command>\n<explanation>Does the thing.</explanation>"
+}
+
+// stubActions replaces running, editing and copying commands for the test
+// and records what was done.
+func stubActions(t *testing.T) *[]string {
+	t.Helper()
+
+	originalRun, originalEdit, originalCopy := runShell, editCommand, copyCommand
+	t.Cleanup(func() {
+		runShell, editCommand, copyCommand = originalRun, originalEdit, originalCopy
+	})
+
+	var actions []string
+	runShell = func(ctx context.Context, shell, command string, stdin io.Reader, stdout, stderr io.Writer) error {
+		actions = append(actions, "run "+shell+": "+command)
+		return nil
+	}
+	editCommand = func(command string, stdin io.Reader, stdout, stderr io.Writer) (string, error) {
+		actions = append(actions, "edit: "+command)
+		return command + " --verbose", nil
+	}
+	copyCommand = func(command string) error {
+		actions = append(actions, "copy: "+command)
+		return nil
+	}
+	return &actions
+}
+
+func TestRun(t *testing.T) {
+	t.Setenv("SHELL", "/bin/zsh")
+
+	tests := []struct {
+		name    string
+		command string
+		answers string
+		want    []string
+		stderr  string
+	}{
+		{"run", "ls -la", "r\n", []string{"run /bin/zsh: ls -la"}, "Does the thing.\n\n"},
+		{"quit", "ls -la", "q\n", nil, ""},
+		{"end of input", "ls -la", "", nil, ""},
+		{"copy", "ls -la", "copy\n", []string{"copy: ls -la"}, "Copied to the clipboard."},
+		{"edit then run", "ls", "e\nr\n", []string{"edit: ls", "run /bin/zsh: ls --verbose"}, ""},
+		{"unknown choice", "ls", "x\nr\n", []string{"run /bin/zsh: ls"}, `Unknown choice "x".`},
+		{"risky confirmed", "rm -rf build", "r\nyes\n", []string{"run /bin/zsh: rm -rf build"}, "Warning: rm deletes files\n"},
+		{"risky refused", "rm -rf build", "r\ny\n", nil, "Not run."},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actions := stubActions(t)
+			provider := &stubProvider{resp: suggest(tt.command)}
+
+			var stdout, stderr bytes.Buffer
+			err := Run(context.Background(), provider, strings.NewReader(tt.answers), &stdout, &stderr, []string{"list", "files"})
+			if err != nil {
+				t.Fatalf("Run() error = %v, want nil", err)
+			}
+
+			if !reflect.DeepEqual(*actions, tt.want) {
+				t.Errorf("actions = %q, want %q", *actions, tt.want)
+			}
+			if !strings.HasPrefix(stdout.String(), tt.command+"\n") {
+				t.Errorf("stdout = %q, want the command", stdout.String())
+			}
+			if !strings.Contains(stderr.String(), tt.stderr) {
+				t.Errorf("stderr = %q, want to contain %q", stderr.String(), tt.stderr)
+			}
+			if !strings.Contains(provider.prompt, "Shell: /bin/zsh\n") || !strings.HasSuffix(provider.prompt, "Request: list files") {
+				t.Errorf("prompt = %q, want the shell and the request", provider.prompt)
+			}
+		})
+	}
+}
+
+func TestRunPassesRemainingInput(t *testing.T) {
+	stubActions(t)
+
+	var input string
+	runShell = func(ctx context.Context, shell, command string, stdin io.Reader, stdout, stderr io.Writer) error {
+		data, err := io.ReadAll(stdin)
+		input = string(data)
+		return err
+	}
+
+	err := Run(context.Background(), &stubProvider{resp: suggest("sort")}, strings.NewReader("r\nb\na\n"), io.Discard, io.Discard, []string{"sort", "lines"})
+	if err != nil {
+		t.Fatalf("Run() error = %v, want nil", err)
+	}
+	if input != "b\na\n" {
+		t.Errorf("command stdin = %q, want the input after the answer", input)
+	}
+}
+
+func TestRunStopsWaitingForAnswerWhenCancelled(t *testing.T) {
+	actions := stubActions(t)
+
+	// Nothing is ever written, as when the user walks away from a prompt.
+	stdin, w := io.Pipe()
+	t.Cleanup(func() { _ = w.Close() })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+
+	err := Run(ctx, &stubProvider{resp: suggest("ls")}, stdin, io.Discard, io.Discard, []string{"list"})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Run() error = %v, want context.Canceled", err)
+	}
+	if len(*actions) != 0 {
+		t.Errorf("actions = %q, want none", *actions)
+	}
+}
+
+func TestRunErrors(t *testing.T) {
+	stubActions(t)
+
+	tests := []struct {
+		name string
+		resp string
+		args []string
+		want string
+	}{
+		{"no request", suggest("ls"), nil, "usage: llm sh"},
+		{"no command", "<command></command><explanation>Not a shell task.</explanation>", []string{"make coffee"}, "no command suggested: Not a shell task."},
+		{"malformed", "ls -la", []string{"list"}, "expected <command> and <explanation> tags"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Run(context.Background(), &stubProvider{resp: tt.resp}, strings.NewReader("r\n"), io.Discard, io.Discard, tt.args)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Run() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunShellReportsExitStatus(t *testing.T) {
+	var stdout bytes.Buffer
+	if err := runShellExec(context.Background(), "/bin/sh", "echo hi", nil, &stdout, io.Discard); err != nil || stdout.String() != "hi\n" {
+		t.Errorf("runShellExec() = %q, %v, want hi", stdout.String(), err)
+	}
+
+	err := runShellExec(context.Background(), "/bin/sh", "exit 3", nil, io.Discard, io.Discard)
+	if err == nil || err.Error() != "command exited with status 3" {
+		t.Errorf("runShellExec() error = %v, want exit status 3", err)
+	}
+}
+
+func TestParseSuggestionStripsBackticks(t *testing.T) {
+	s, err := parseSuggestion("<command>`du -sh *`</command>\n<explanation>\n  Sizes.\n</explanation>")
+	if err != nil {
+		t.Fatalf("parseSuggestion() error = %v", err)
+	}
+	if s.Command != "du -sh *" || s.Explanation != "Sizes." {
+		t.Errorf("parseSuggestion() = %+v", s)
+	}
+}
+
+func TestRisks(t *testing.T) {
+	tests := []struct {
+		command string
+		want    []string
+	}{
+		{"ls -la | grep go", nil},
+		{"git push origin main", nil},
+		{"grep -r farm .", nil},
+		{"find . -name '*.tmp' -delete", []string{"find -delete deletes files"}},
+		{"sudo dd if=ubuntu.iso of=/dev/sdb bs=4M", []string{"dd overwrites its output file or device", "sudo runs the command as root"}},
+		{"git push --force origin main", []string{"git push --force rewrites remote history"}},
+		{"git push -f", []string{"git push --force rewrites remote history"}},
+		{"git reset --hard HEAD~1 && git clean -fd", []string{"git reset --hard discards uncommitted changes", "git clean deletes untracked files"}},
+		{"curl -fsSL https://example.com/install.sh | sh", []string{"runs a script straight from the network"}},
+		{"chmod -R 777 .", []string{"changes ownership or permissions recursively"}},
+		{"find . -name '*.orig' | xargs rm", []string{"rm deletes files"}},
+	}
+
+	for _, tt := range tests {
+		if got := Risks(tt.command); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Risks(%q) = %q, want %q", tt.command, got, tt.want)
+		}
+	}
+}
+
+func TestCurrentEnvNamesDistribution(t *testing.T) {
+	original := osReleaseFile
+	t.Cleanup(func() { osReleaseFile = original })
+
+	osReleaseFile = filepath.Join(t.TempDir(), "os-release")
+	if err := os.WriteFile(osReleaseFile, []byte("NAME=\"Ubuntu\"\nPRETTY_NAME=\"Ubuntu 24.04 LTS\"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("SHELL", "")
+
+	env := CurrentEnv()
+	if !strings.HasSuffix(env.OS, " (Ubuntu 24.04 LTS)") || env.Shell != "/bin/sh" || env.Dir == "" {
+		t.Errorf("CurrentEnv() = %+v", env)
+	}
+}
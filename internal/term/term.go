@@ -0,0 +1,15 @@
+// Package term reports whether the streams llm reads and writes are
+// attached to a terminal.
+package term
+
+import "os"
+
+// IsTerminal reports whether stream, a reader or writer, is a terminal.
+func IsTerminal(stream any) bool {
+	f, ok := stream.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
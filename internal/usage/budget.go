@@ -0,0 +1,143 @@
+package usage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"llm/internal/config"
+	"llm/internal/providers"
+)
+
+// ErrBudgetExceeded is matched by every BudgetExceededError.
+var ErrBudgetExceeded = errors.New("budget exceeded")
+
+// BudgetExceededError reports that the usage recorded for the current day
+// or month has reached a configured limit.
+type BudgetExceededError struct {
+	// Period is "daily" or "monthly".
+	Period string
+	// Unit is "tokens" or "USD".
+	Unit  string
+	Limit float64
+	Used  float64
+}
+
+func (e *BudgetExceededError) Error() string {
+	if e.Unit == "USD" {
+		return fmt.Sprintf("%s budget of $%.2f exceeded ($%.2f used); pass --over-budget to run anyway", e.Period, e.Limit, e.Used)
+	}
+	return fmt.Sprintf("%s budget of %.0f tokens exceeded (%.0f used); pass --over-budget to run anyway", e.Period, e.Limit, e.Used)
+}
+
+func (e *BudgetExceededError) Is(target error) bool {
+	return target == ErrBudgetExceeded
+}
+
+// CheckBudget returns a *BudgetExceededError if the records for the day or
+// month containing now reach any limit in b. Tokens count both input and
+// output; costs only include what providers report.
+func CheckBudget(records []Record, b config.Budget, now time.Time) error {
+	y, m, d := now.Date()
+	dayStart := time.Date(y, m, d, 0, 0, 0, 0, now.Location())
+	monthStart := time.Date(y, m, 1, 0, 0, 0, 0, now.Location())
+
+	var day, month Row
+	for _, r := range records {
+		if r.Time.Before(monthStart) {
+			continue
+		}
+		month = month.add(r)
+		if !r.Time.Before(dayStart) {
+			day = day.add(r)
+		}
+	}
+
+	for _, limit := range []struct {
+		period string
+		unit   string
+		limit  float64
+		used   float64
+	}{
+		{"daily", "tokens", float64(b.DailyTokens), float64(day.InputTokens + day.OutputTokens)},
+		{"daily", "USD", b.DailyUSD, day.Cost},
+		{"monthly", "tokens", float64(b.MonthlyTokens), float64(month.InputTokens + month.OutputTokens)},
+		{"monthly", "USD", b.MonthlyUSD, month.Cost},
+	} {
+		if limit.limit > 0 && limit.used >= limit.limit {
+			return &BudgetExceededError{Period: limit.period, Unit: limit.unit, Limit: limit.limit, Used: limit.used}
+		}
+	}
+
+	return nil
+}
+
+// CostWarning returns a warning when b limits spending in USD but the named
+// provider reports no cost, so its calls never count towards the limit. It
+// returns "" otherwise.
+func CostWarning(b config.Budget, provider string) string {
+	if b.DailyUSD <= 0 && b.MonthlyUSD <= 0 || provider == "" || providers.ReportsCost(provider) {
+		return ""
+	}
+	return fmt.Sprintf("%s does not report costs, so daily_usd and monthly_usd are not enforced; set daily_tokens or monthly_tokens instead", provider)
+}
+
+// Guard wraps a provider and refuses every call once the usage recorded in
+// a ledger has reached the budget.
+type Guard struct {
+	provider providers.Provider
+	ledger   *Ledger
+	budget   config.Budget
+	now      func() time.Time
+}
+
+// NewGuard returns a provider that checks budget against ledger before
+// forwarding each call to p.
+func NewGuard(p providers.Provider, ledger *Ledger, budget config.Budget) *Guard {
+	return &Guard{
+		provider: p,
+		ledger:   ledger,
+		budget:   budget,
+		now:      time.Now,
+	}
+}
+
+func (g *Guard) Complete(ctx context.Context, system, userMsg string) (providers.Response, error) {
+	return g.Chat(ctx, system, []providers.Message{{Role: "user", Content: userMsg}})
+}
+
+func (g *Guard) Chat(ctx context.Context, system string, msgs []providers.Message) (providers.Response, error) {
+	if err := g.check(); err != nil {
+		return providers.Response{}, err
+	}
+	return providers.Chat(ctx, g.provider, system, msgs)
+}
+
+func (g *Guard) Stream(ctx context.Context, system, userMsg string, onDelta func(string) error) (providers.Response, error) {
+	return g.StreamChat(ctx, system, []providers.Message{{Role: "user", Content: userMsg}}, onDelta)
+}
+
+func (g *Guard) StreamChat(ctx context.Context, system string, msgs []providers.Message, onDelta func(string) error) (providers.Response, error) {
+	if err := g.check(); err != nil {
+		return providers.Response{}, err
+	}
+	return providers.StreamChat(ctx, g.provider, system, msgs, onDelta)
+}
+
+// Models lists the models of the wrapped provider unless the budget has
+// been reached.
+func (g *Guard) Models(ctx context.Context) ([]string, error) {
+	if err := g.check(); err != nil {
+		return nil, err
+	}
+	return providers.ListModels(ctx, g.provider)
+}
+
+func (g *Guard) check() error {
+	records, err := g.ledger.Read()
+	if err != nil {
+		return fmt.Errorf("checking budget: %w", err)
+	}
+	return CheckBudget(records, g.budget, g.now())
+}
@@ -0,0 +1,134 @@
+package usage
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"llm/internal/config"
+	"llm/internal/providers"
+)
+
+func TestCheckBudget(t *testing.T) {
+	now := time.Date(2026, 3, 15, 12, 0, 0, 0, time.UTC)
+	records := []Record{
+		{Time: now.AddDate(0, -1, 0), InputTokens: 1_000_000, Cost: 50},
+		{Time: now.AddDate(0, 0, -3), InputTokens: 6000, OutputTokens: 1000, Cost: 4},
+		{Time: now.Add(-time.Hour), InputTokens: 2500, OutputTokens: 500, Cost: 1.5},
+	}
+
+	tests := []struct {
+		name   string
+		budget config.Budget
+		want   *BudgetExceededError
+	}{
+		{name: "no limits", budget: config.Budget{}},
+		{name: "under every limit", budget: config.Budget{DailyTokens: 5000, MonthlyTokens: 20000, DailyUSD: 2, MonthlyUSD: 10}},
+		{
+			name:   "daily tokens",
+			budget: config.Budget{DailyTokens: 3000},
+			want:   &BudgetExceededError{Period: "daily", Unit: "tokens", Limit: 3000, Used: 3000},
+		},
+		{
+			name:   "monthly tokens ignore last month",
+			budget: config.Budget{MonthlyTokens: 10000},
+			want:   &BudgetExceededError{Period: "monthly", Unit: "tokens", Limit: 10000, Used: 10000},
+		},
+		{
+			name:   "daily cost",
+			budget: config.Budget{DailyUSD: 1},
+			want:   &BudgetExceededError{Period: "daily", Unit: "USD", Limit: 1, Used: 1.5},
+		},
+		{
+			name:   "monthly cost",
+			budget: config.Budget{MonthlyUSD: 5},
+			want:   &BudgetExceededError{Period: "monthly", Unit: "USD", Limit: 5, Used: 5.5},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckBudget(records, tt.budget, now)
+			if tt.want == nil {
+				if err != nil {
+					t.Errorf("CheckBudget() error = %v, want nil", err)
+				}
+				return
+			}
+
+			var got *BudgetExceededError
+			if !errors.As(err, &got) || *got != *tt.want {
+				t.Errorf("CheckBudget() error = %#v, want %#v", err, tt.want)
+			}
+			if !errors.Is(err, ErrBudgetExceeded) {
+				t.Errorf("errors.Is(%v, ErrBudgetExceeded) = false, want true", err)
+			}
+		})
+	}
+}
+
+func TestBudgetExceededErrorMessage(t *testing.T) {
+	tests := []struct {
+		err  *BudgetExceededError
+		want string
+	}{
+		{
+			err:  &BudgetExceededError{Period: "monthly", Unit: "USD", Limit: 20, Used: 20.134},
+			want: "monthly budget of $20.00 exceeded ($20.13 used); pass --over-budget to run anyway",
+		},
+		{
+			err:  &BudgetExceededError{Period: "daily", Unit: "tokens", Limit: 100000, Used: 104230},
+			want: "daily budget of 100000 tokens exceeded (104230 used); pass --over-budget to run anyway",
+		},
+	}
+
+	for _, tt := range tests {
+		if got := tt.err.Error(); got != tt.want {
+			t.Errorf("Error() = %q, want %q", got, tt.want)
+		}
+	}
+}
+
+func TestCostWarning(t *testing.T) {
+	usd := config.Budget{MonthlyUSD: 20}
+
+	if got := CostWarning(usd, "anthropic"); !strings.Contains(got, "anthropic does not report costs") {
+		t.Errorf("CostWarning(anthropic) = %q, want a warning", got)
+	}
+	if got := CostWarning(usd, "openrouter"); got != "" {
+		t.Errorf("CostWarning(openrouter) = %q, want none", got)
+	}
+	if got := CostWarning(config.Budget{DailyTokens: 1000}, "anthropic"); got != "" {
+		t.Errorf("CostWarning() for a token budget = %q, want none", got)
+	}
+}
+
+func TestGuard(t *testing.T) {
+	ledger := NewLedger(filepath.Join(t.TempDir(), "usage.jsonl"))
+	p := &stubProvider{resp: providers.Response{Text: "ok"}}
+	g := NewGuard(p, ledger, config.Budget{DailyTokens: 100})
+
+	if _, err := g.Complete(context.Background(), "", "hi"); err != nil {
+		t.Fatalf("Complete() under budget error = %v, want nil", err)
+	}
+
+	if err := ledger.Append(Record{Time: time.Now(), InputTokens: 90, OutputTokens: 10, OK: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := g.Complete(context.Background(), "", "hi"); !errors.Is(err, ErrBudgetExceeded) {
+		t.Errorf("Complete() over budget error = %v, want ErrBudgetExceeded", err)
+	}
+
+	called := false
+	_, err := g.Stream(context.Background(), "", "hi", func(string) error {
+		called = true
+		return nil
+	})
+	if !errors.Is(err, ErrBudgetExceeded) || called {
+		t.Errorf("Stream() over budget error = %v, called = %v, want ErrBudgetExceeded and no output", err, called)
+	}
+}
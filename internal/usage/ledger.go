@@ -0,0 +1,112 @@
+package usage
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"llm/internal/xdg"
+)
+
+// Record is a single provider call in the ledger.
+type Record struct {
+	Time         time.Time `json:"time"`
+	Command      string    `json:"command"`
+	Provider     string    `json:"provider,omitempty"`
+	Model        string    `json:"model,omitempty"`
+	InputTokens  int       `json:"input_tokens"`
+	OutputTokens int       `json:"output_tokens"`
+	Cost         float64   `json:"cost,omitempty"`
+	LatencyMS    int64     `json:"latency_ms"`
+	Cached       bool      `json:"cached,omitempty"`
+	OK           bool      `json:"ok"`
+	Error        string    `json:"error,omitempty"`
+}
+
+// Ledger is an append-only log of provider calls stored as one JSON
+// record per line.
+type Ledger struct {
+	path string
+}
+
+// NewLedger returns a ledger stored at path. The file and its directory
+// are created on the first Append.
+func NewLedger(path string) *Ledger {
+	return &Ledger{path: path}
+}
+
+// DefaultPath returns $XDG_STATE_HOME/llm/usage.jsonl.
+func DefaultPath() (string, error) {
+	dir, err := xdg.StateHome()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "llm", "usage.jsonl"), nil
+}
+
+func (l *Ledger) Path() string {
+	return l.path
+}
+
+// Append adds r to the end of the ledger. Each record is written with a
+// single call so that concurrent invocations do not interleave lines.
+func (l *Ledger) Append(r Record) error {
+	line, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(l.path), 0o700); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(l.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Read returns every record in the ledger in the order they were written.
+// A missing ledger has no records. Lines that cannot be parsed, such as one
+// cut short by a crash, are skipped.
+func (l *Ledger) Read() ([]Record, error) {
+	f, err := os.Open(l.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var records []Record
+	reader := bufio.NewReader(f)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 {
+			var r Record
+			if json.Unmarshal(line, &r) == nil {
+				records = append(records, r)
+			}
+		}
+
+		if errors.Is(err, io.EOF) {
+			return records, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %w", l.path, err)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package usage
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestLedgerAppendAndRead(t *testing.T) {
+	l := NewLedger(filepath.Join(t.TempDir(), "llm", "usage.jsonl"))
+
+	records, err := l.Read()
+	if err != nil || records != nil {
+		t.Fatalf("Read() on missing ledger = %v, %v, want nil, nil", records, err)
+	}
+
+	want := []Record{
+		{
+			Time:         time.Date(2026, 3, 1, 9, 30, 0, 0, time.UTC),
+			Command:      "commit",
+			Provider:     "openrouter",
+			Model:        "anthropic/claude-haiku-4.5",
+			InputTokens:  1800,
+			OutputTokens: 20,
+			Cost:         0.0019,
+			LatencyMS:    1450,
+			OK:           true,
+		},
+		{
+			Time:      time.Date(2026, 3, 1, 9, 31, 0, 0, time.UTC),
+			Command:   "gh pr",
+			Provider:  "anthropic",
+			Model:     "claude-haiku-4-5",
+			LatencyMS: 30000,
+			Error:     "context deadline exceeded",
+		},
+	}
+	for _, r := range want {
+		if err := l.Append(r); err != nil {
+			t.Fatalf("Append() error = %v, want nil", err)
+		}
+	}
+
+	got, err := l.Read()
+	if err != nil {
+		t.Fatalf("Read() error = %v, want nil", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Read() = %+v, want %+v", got, want)
+	}
+}
+
+func TestLedgerReadSkipsMalformedLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "usage.jsonl")
+	data := `{"time":"2026-03-01T09:30:00Z","command":"ask","ok":true}
+not json
+{"time":"2026-03-01T09:31:00Z","command":"commit","ok":true}
+{"time":"2026-03-01T09:32:00Z","comm`
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := NewLedger(path).Read()
+	if err != nil {
+		t.Fatalf("Read() error = %v, want nil", err)
+	}
+
+	var commands []string
+	for _, r := range got {
+		commands = append(commands, r.Command)
+	}
+	if want := []string{"ask", "commit"}; !reflect.DeepEqual(commands, want) {
+		t.Errorf("Read() commands = %q, want %q", commands, want)
+	}
+}
@@ -0,0 +1,99 @@
+package usage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"llm/internal/providers"
+)
+
+// Recorder wraps a provider and appends a record to a ledger for every
+// call made through it, whether it succeeds or not.
+type Recorder struct {
+	provider providers.Provider
+	ledger   *Ledger
+	command  string
+	stderr   io.Writer
+	now      func() time.Time
+}
+
+// NewRecorder returns a provider that forwards calls to p and records them
+// in ledger under command. Failures to write the ledger are reported to
+// stderr and do not fail the call.
+func NewRecorder(p providers.Provider, ledger *Ledger, command string, stderr io.Writer) *Recorder {
+	if stderr == nil {
+		stderr = io.Discard
+	}
+
+	return &Recorder{
+		provider: p,
+		ledger:   ledger,
+		command:  command,
+		stderr:   stderr,
+		now:      time.Now,
+	}
+}
+
+func (r *Recorder) Complete(ctx context.Context, system, userMsg string) (providers.Response, error) {
+	return r.Chat(ctx, system, []providers.Message{{Role: "user", Content: userMsg}})
+}
+
+func (r *Recorder) Chat(ctx context.Context, system string, msgs []providers.Message) (providers.Response, error) {
+	start := r.now()
+	resp, err := providers.Chat(ctx, r.provider, system, msgs)
+	r.record(start, resp, err)
+	return resp, err
+}
+
+func (r *Recorder) Stream(ctx context.Context, system, userMsg string, onDelta func(string) error) (providers.Response, error) {
+	return r.StreamChat(ctx, system, []providers.Message{{Role: "user", Content: userMsg}}, onDelta)
+}
+
+func (r *Recorder) StreamChat(ctx context.Context, system string, msgs []providers.Message, onDelta func(string) error) (providers.Response, error) {
+	start := r.now()
+	resp, err := providers.StreamChat(ctx, r.provider, system, msgs, onDelta)
+	r.record(start, resp, err)
+	return resp, err
+}
+
+// Models lists the models of the wrapped provider, recording the call.
+// Providers that cannot list their models make no call, so nothing is
+// recorded for them.
+func (r *Recorder) Models(ctx context.Context) ([]string, error) {
+	if _, ok := r.provider.(providers.ModelLister); !ok {
+		return providers.ListModels(ctx, r.provider)
+	}
+
+	start := r.now()
+	models, err := providers.ListModels(ctx, r.provider)
+	r.record(start, providers.Response{}, err)
+	return models, err
+}
+
+func (r *Recorder) record(start time.Time, resp providers.Response, err error) {
+	// Failed calls report no usage, so fall back to the configured
+	// provider and model.
+	u := providers.Describe(r.provider).Add(resp.Usage)
+
+	rec := Record{
+		Time:         start,
+		Command:      r.command,
+		Provider:     u.Provider,
+		Model:        u.Model,
+		InputTokens:  u.InputTokens,
+		OutputTokens: u.OutputTokens,
+		Cost:         u.Cost,
+		LatencyMS:    r.now().Sub(start).Milliseconds(),
+		Cached:       resp.Cached,
+		OK:           err == nil,
+	}
+	if err != nil {
+		rec.Error = err.Error()
+	}
+
+	if err := r.ledger.Append(rec); err != nil {
+		_, _ = fmt.Fprintf(r.stderr, "llm: recording usage: %v\n", err)
+	}
+}
@@ -0,0 +1,135 @@
+package usage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"llm/internal/providers"
+)
+
+type stubProvider struct {
+	resp providers.Response
+	err  error
+}
+
+func (s *stubProvider) Complete(ctx context.Context, system, userMsg string) (providers.Response, error) {
+	return s.resp, s.err
+}
+
+func TestRecorder(t *testing.T) {
+	ledger := NewLedger(filepath.Join(t.TempDir(), "usage.jsonl"))
+	p := &stubProvider{resp: providers.Response{
+		Text:  "ok",
+		Usage: providers.Usage{Provider: "openai", Model: "gpt-4o-mini", InputTokens: 300, OutputTokens: 12},
+	}}
+
+	start := time.Date(2026, 3, 1, 9, 30, 0, 0, time.UTC)
+	clock := []time.Time{start, start.Add(1200 * time.Millisecond), start.Add(time.Minute), start.Add(time.Minute + 50*time.Millisecond)}
+	r := NewRecorder(p, ledger, "commit", nil)
+	r.now = func() time.Time {
+		now := clock[0]
+		clock = clock[1:]
+		return now
+	}
+
+	if _, err := r.Complete(context.Background(), "", "diff"); err != nil {
+		t.Fatalf("Complete() error = %v, want nil", err)
+	}
+
+	p.err = errors.New("503 Service Unavailable: overloaded")
+	if _, err := r.Stream(context.Background(), "", "diff", func(string) error { return nil }); err == nil {
+		t.Fatal("Stream() error = nil, want error")
+	}
+
+	got, err := ledger.Read()
+	if err != nil {
+		t.Fatalf("Read() error = %v, want nil", err)
+	}
+
+	want := []Record{
+		{
+			Time:         start,
+			Command:      "commit",
+			Provider:     "openai",
+			Model:        "gpt-4o-mini",
+			InputTokens:  300,
+			OutputTokens: 12,
+			LatencyMS:    1200,
+			OK:           true,
+		},
+		{
+			Time:      start.Add(time.Minute),
+			Command:   "commit",
+			LatencyMS: 50,
+			Error:     "503 Service Unavailable: overloaded",
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ledger = %+v, want %+v", got, want)
+	}
+}
+
+func TestRecorderDescribesFailedCalls(t *testing.T) {
+	ledger := NewLedger(filepath.Join(t.TempDir(), "usage.jsonl"))
+	p := providers.NewAnthropicProvider("http://127.0.0.1:0", "claude-haiku-4-5", "key", providers.WithRetries(0))
+
+	if _, err := NewRecorder(p, ledger, "ask", nil).Complete(context.Background(), "", "hi"); err == nil {
+		t.Fatal("Complete() error = nil, want error")
+	}
+
+	got, err := ledger.Read()
+	if err != nil || len(got) != 1 {
+		t.Fatalf("Read() = %+v, %v, want one record", got, err)
+	}
+	if got[0].OK || got[0].Provider != "anthropic" || got[0].Model != "claude-haiku-4-5" || got[0].Error == "" {
+		t.Errorf("record = %+v, want failed anthropic claude-haiku-4-5 call", got[0])
+	}
+}
+
+func TestRecorderReportsLedgerErrors(t *testing.T) {
+	var stderr bytes.Buffer
+
+	// A directory where the ledger file should be makes every write fail.
+	r := NewRecorder(&stubProvider{}, NewLedger(t.TempDir()), "ask", &stderr)
+	if _, err := r.Complete(context.Background(), "", "hi"); err != nil {
+		t.Fatalf("Complete() error = %v, want nil", err)
+	}
+
+	if !bytes.Contains(stderr.Bytes(), []byte("llm: recording usage:")) {
+		t.Errorf("stderr = %q, want ledger error", stderr.String())
+	}
+}
+
+type listerProvider struct {
+	stubProvider
+	models []string
+}
+
+func (l *listerProvider) Models(ctx context.Context) ([]string, error) {
+	return l.models, nil
+}
+
+func TestRecorderRecordsModelListings(t *testing.T) {
+	ledger := NewLedger(filepath.Join(t.TempDir(), "usage.jsonl"))
+
+	models, err := providers.ListModels(context.Background(), NewRecorder(&listerProvider{models: []string{"b", "a"}}, ledger, "models", nil))
+	if err != nil || !reflect.DeepEqual(models, []string{"a", "b"}) {
+		t.Fatalf("ListModels() = %v, %v, want [a b]", models, err)
+	}
+	if _, err := providers.ListModels(context.Background(), NewRecorder(&stubProvider{}, ledger, "models", nil)); err == nil {
+		t.Fatal("ListModels() error = nil, want error for a provider that cannot list models")
+	}
+
+	got, err := ledger.Read()
+	if err != nil {
+		t.Fatalf("Read() error = %v, want nil", err)
+	}
+	if len(got) != 1 || got[0].Command != "models" || !got[0].OK {
+		t.Errorf("ledger = %+v, want one successful models call", got)
+	}
+}
@@ -0,0 +1,157 @@
+package usage
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+const defaultDays = 30
+
+// Row is the combined usage of one command and model on one day.
+type Row struct {
+	Day          string
+	Command      string
+	Model        string
+	Calls        int
+	Failures     int
+	InputTokens  int
+	OutputTokens int
+	Cost         float64
+	Latency      time.Duration
+}
+
+// AvgLatency returns the mean latency of the calls in r.
+func (r Row) AvgLatency() time.Duration {
+	if r.Calls == 0 {
+		return 0
+	}
+	return r.Latency / time.Duration(r.Calls)
+}
+
+func (r Row) add(rec Record) Row {
+	r.Calls++
+	if !rec.OK {
+		r.Failures++
+	}
+	r.InputTokens += rec.InputTokens
+	r.OutputTokens += rec.OutputTokens
+	r.Cost += rec.Cost
+	r.Latency += time.Duration(rec.LatencyMS) * time.Millisecond
+	return r
+}
+
+// Summarize groups records by local day, command and model. Rows are
+// sorted by day, then command, then model.
+func Summarize(records []Record) []Row {
+	type key struct{ day, command, model string }
+
+	index := make(map[key]int)
+	var rows []Row
+	for _, rec := range records {
+		k := key{rec.Time.Local().Format(time.DateOnly), rec.Command, rec.Model}
+		i, ok := index[k]
+		if !ok {
+			i = len(rows)
+			index[k] = i
+			rows = append(rows, Row{Day: k.day, Command: k.command, Model: k.model})
+		}
+		rows[i] = rows[i].add(rec)
+	}
+
+	slices.SortFunc(rows, func(a, b Row) int {
+		return cmp.Or(
+			strings.Compare(a.Day, b.Day),
+			strings.Compare(a.Command, b.Command),
+			strings.Compare(a.Model, b.Model),
+		)
+	})
+	return rows
+}
+
+// WriteReport writes rows as a table followed by a total.
+func WriteReport(w io.Writer, rows []Row) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "DAY\tCOMMAND\tMODEL\tCALLS\tFAILED\tINPUT\tOUTPUT\tCOST\tAVG LATENCY")
+
+	var total Row
+	for _, r := range rows {
+		writeRow(tw, r.Day, r.Command, cmp.Or(r.Model, "-"), r)
+
+		total.Calls += r.Calls
+		total.Failures += r.Failures
+		total.InputTokens += r.InputTokens
+		total.OutputTokens += r.OutputTokens
+		total.Cost += r.Cost
+		total.Latency += r.Latency
+	}
+	writeRow(tw, "total", "", "", total)
+
+	return tw.Flush()
+}
+
+func writeRow(w io.Writer, day, command, model string, r Row) {
+	_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%d\t%d\t%d\t$%.4f\t%s\n",
+		day, command, model, r.Calls, r.Failures, r.InputTokens, r.OutputTokens, r.Cost,
+		r.AvgLatency().Round(time.Millisecond))
+}
+
+// Run prints the usage recorded in ledger over the last few days, 30 by
+// default or as many as given with --days.
+func Run(ledger *Ledger, stdout io.Writer, args []string) error {
+	days, err := parseDays(args)
+	if err != nil {
+		return err
+	}
+
+	records, err := ledger.Read()
+	if err != nil {
+		return err
+	}
+
+	y, m, d := time.Now().Date()
+	since := time.Date(y, m, d-days+1, 0, 0, 0, 0, time.Local)
+	records = slices.DeleteFunc(records, func(r Record) bool {
+		return r.Time.Before(since)
+	})
+
+	if len(records) == 0 {
+		_, err := fmt.Fprintf(stdout, "No usage recorded in the last %d days.\n", days)
+		return err
+	}
+
+	return WriteReport(stdout, Summarize(records))
+}
+
+func parseDays(args []string) (int, error) {
+	days := defaultDays
+
+	for i := 0; i < len(args); i++ {
+		var value string
+		switch {
+		case args[i] == "-d" || args[i] == "--days":
+			if i+1 == len(args) {
+				return 0, fmt.Errorf("%s requires a number of days", args[i])
+			}
+			i++
+			value = args[i]
+		case strings.HasPrefix(args[i], "--days="):
+			value = strings.TrimPrefix(args[i], "--days=")
+		default:
+			return 0, fmt.Errorf("usage: llm usage [--days N]")
+		}
+
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 {
+			return 0, fmt.Errorf("invalid number of days %q", value)
+		}
+		days = n
+	}
+
+	return days, nil
+}
@@ -0,0 +1,110 @@
+package usage
+
+import (
+	"bytes"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSummarize(t *testing.T) {
+	day1 := time.Date(2026, 3, 1, 9, 0, 0, 0, time.Local)
+	day2 := day1.AddDate(0, 0, 1)
+
+	records := []Record{
+		{Time: day2, Command: "commit", Model: "gpt-4o-mini", InputTokens: 100, OutputTokens: 10, LatencyMS: 800, OK: true},
+		{Time: day1, Command: "commit", Model: "gpt-4o-mini", InputTokens: 200, OutputTokens: 20, Cost: 0.01, LatencyMS: 1000, OK: true},
+		{Time: day1.Add(time.Hour), Command: "commit", Model: "gpt-4o-mini", InputTokens: 300, OutputTokens: 30, Cost: 0.02, LatencyMS: 3000, OK: true},
+		{Time: day1, Command: "commit", Model: "gpt-4o-mini", LatencyMS: 500, Error: "timeout"},
+		{Time: day1, Command: "ask", Model: "claude-haiku-4-5", InputTokens: 50, OutputTokens: 5, LatencyMS: 400, OK: true},
+	}
+
+	want := []Row{
+		{Day: "2026-03-01", Command: "ask", Model: "claude-haiku-4-5", Calls: 1, InputTokens: 50, OutputTokens: 5, Latency: 400 * time.Millisecond},
+		{Day: "2026-03-01", Command: "commit", Model: "gpt-4o-mini", Calls: 3, Failures: 1, InputTokens: 500, OutputTokens: 50, Cost: 0.03, Latency: 4500 * time.Millisecond},
+		{Day: "2026-03-02", Command: "commit", Model: "gpt-4o-mini", Calls: 1, InputTokens: 100, OutputTokens: 10, Latency: 800 * time.Millisecond},
+	}
+
+	got := Summarize(records)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Summarize() = %+v, want %+v", got, want)
+	}
+
+	if avg := got[1].AvgLatency(); avg != 1500*time.Millisecond {
+		t.Errorf("AvgLatency() = %v, want 1.5s", avg)
+	}
+}
+
+func TestWriteReport(t *testing.T) {
+	var out bytes.Buffer
+	err := WriteReport(&out, []Row{
+		{Day: "2026-03-01", Command: "commit", Model: "gpt-4o-mini", Calls: 2, InputTokens: 500, OutputTokens: 50, Cost: 0.03, Latency: 3 * time.Second},
+		{Day: "2026-03-01", Command: "gh pr", Calls: 1, Failures: 1, Latency: time.Second},
+	})
+	if err != nil {
+		t.Fatalf("WriteReport() error = %v, want nil", err)
+	}
+
+	want := `DAY         COMMAND  MODEL        CALLS  FAILED  INPUT  OUTPUT  COST     AVG LATENCY
+2026-03-01  commit   gpt-4o-mini  2      0       500    50      $0.0300  1.5s
+2026-03-01  gh pr    -            1      1       0      0       $0.0000  1s
+total                             3      1       500    50      $0.0300  1.333s
+`
+	if got := out.String(); got != want {
+		t.Errorf("WriteReport() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestRun(t *testing.T) {
+	ledger := NewLedger(filepath.Join(t.TempDir(), "usage.jsonl"))
+
+	var out bytes.Buffer
+	if err := Run(ledger, &out, nil); err != nil {
+		t.Fatalf("Run() error = %v, want nil", err)
+	}
+	if got := out.String(); got != "No usage recorded in the last 30 days.\n" {
+		t.Errorf("Run() on empty ledger = %q", got)
+	}
+
+	now := time.Now()
+	for _, r := range []Record{
+		{Time: now, Command: "ask", Model: "recent-model", OK: true},
+		{Time: now.AddDate(0, 0, -10), Command: "ask", Model: "older-model", OK: true},
+	} {
+		if err := ledger.Append(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	out.Reset()
+	if err := Run(ledger, &out, []string{"--days", "7"}); err != nil {
+		t.Fatalf("Run() error = %v, want nil", err)
+	}
+	if got := out.String(); !strings.Contains(got, "recent-model") || strings.Contains(got, "older-model") {
+		t.Errorf("Run(--days 7) =\n%s\nwant only the last 7 days", got)
+	}
+}
+
+func TestParseDays(t *testing.T) {
+	tests := []struct {
+		args    []string
+		want    int
+		wantErr bool
+	}{
+		{args: nil, want: 30},
+		{args: []string{"-d", "7"}, want: 7},
+		{args: []string{"--days=1"}, want: 1},
+		{args: []string{"--days"}, wantErr: true},
+		{args: []string{"--days", "0"}, wantErr: true},
+		{args: []string{"commit"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseDays(tt.args)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("parseDays(%q) = %d, %v, want %d, error %v", tt.args, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
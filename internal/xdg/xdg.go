@@ -0,0 +1,35 @@
+package xdg
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// ConfigHome returns $XDG_CONFIG_HOME, falling back to ~/.config.
+func ConfigHome() (string, error) {
+	return dir("XDG_CONFIG_HOME", ".config")
+}
+
+// StateHome returns $XDG_STATE_HOME, falling back to ~/.local/state.
+func StateHome() (string, error) {
+	return dir("XDG_STATE_HOME", filepath.Join(".local", "state"))
+}
+
+// CacheHome returns $XDG_CACHE_HOME, falling back to ~/.cache.
+func CacheHome() (string, error) {
+	return dir("XDG_CACHE_HOME", ".cache")
+}
+
+func dir(env, fallback string) (string, error) {
+	if path := os.Getenv(env); path != "" && filepath.IsAbs(path) {
+		return path, nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("resolving %s: %w", env, err)
+	}
+
+	return filepath.Join(home, fallback), nil
+}
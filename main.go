@@ -11,17 +11,36 @@ import (
 	"syscall"
 
 	"llm/internal/cmd"
-	"llm/internal/providers"
+	"llm/internal/config"
+	"llm/internal/explain"
+	"llm/internal/interrupt"
 )
 
 var version string
 
+// newFlagSet returns the global options, which are parsed into flags and
+// printVersion.
+func newFlagSet(flags *cmd.Flags, printVersion *bool) *flag.FlagSet {
+	fs := flag.NewFlagSet("llm", flag.ExitOnError)
+	fs.BoolVar(printVersion, "v", false, "print version")
+	fs.BoolVar(printVersion, "version", false, "print version")
+	fs.StringVar(&flags.Provider, "provider", "", "provider to use (openrouter, opencode-zen, anthropic, openai, azure, gemini, local)")
+	fs.StringVar(&flags.Model, "model", "", "model to use instead of the provider default")
+	fs.BoolVar(&flags.Usage, "usage", false, "print token usage and cost to stderr")
+	fs.BoolVar(&flags.NoCache, "no-cache", false, "do not reuse or store cached responses")
+	fs.BoolVar(&flags.Debug, "debug", false, "log provider requests and responses to stderr, with credentials redacted")
+	fs.BoolVar(&flags.OverBudget, "over-budget", false, "call the provider even if the configured budget is exceeded")
+	fs.Usage = func() { usageTo(os.Stderr, fs) }
+	return fs
+}
+
 func main() {
-	var printVersion bool
-	flag.BoolVar(&printVersion, "v", false, "print version")
-	flag.BoolVar(&printVersion, "version", false, "print version")
-	flag.Usage = usage
-	flag.Parse()
+	var (
+		flags        cmd.Flags
+		printVersion bool
+	)
+	fs := newFlagSet(&flags, &printVersion)
+	_ = fs.Parse(os.Args[1:])
 
 	if printVersion {
 		if version == "" {
@@ -31,50 +50,58 @@ func main() {
 		os.Exit(0)
 	}
 
-	args := flag.Args()
+	args := fs.Args()
 	if len(args) == 0 {
-		usage()
+		fs.Usage()
 		os.Exit(1)
 	}
 
-	// Create a context that can be cancelled via signals (Ctrl+C, SIGTERM)
+	// Create a context that can be cancelled via signals (Ctrl+C, SIGTERM).
+	// Ctrl+C only cancels the current step of an interactive command that
+	// has opened an interrupt scope.
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
 	go func() {
-		<-sigChan
-		cancel()
+		for sig := range sigChan {
+			if sig == os.Interrupt && interrupt.Interrupt() {
+				continue
+			}
+			cancel()
+		}
 	}()
 
-	provider, err := providers.ResolveByAPIKey()
+	cfg, err := config.Load()
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
 
-	err = cmd.Run(ctx, provider, os.Stdout, os.Stderr, args)
+	err = cmd.Run(ctx, cfg, flags, os.Stdout, os.Stderr, args)
 	if errors.Is(err, cmd.ErrUnknownCommand) {
-		usage()
+		fs.Usage()
 		os.Exit(1)
 	}
 
+	// llm explain has already reported the failure of the command it ran.
+	var exitErr *explain.ExitError
+	if errors.As(err, &exitErr) {
+		os.Exit(exitErr.Code)
+	}
+
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
 }
 
-func usage() {
-	usageTo(os.Stderr)
-}
-
-func usageTo(w io.Writer) {
+func usageTo(w io.Writer, fs *flag.FlagSet) {
 	cmd.UsageTo(w)
 	_, _ = fmt.Fprintf(w, "\nOptions:\n")
-	oldOutput := flag.CommandLine.Output()
-	flag.CommandLine.SetOutput(w)
-	defer flag.CommandLine.SetOutput(oldOutput)
-	flag.PrintDefaults()
+	oldOutput := fs.Output()
+	fs.SetOutput(w)
+	defer fs.SetOutput(oldOutput)
+	fs.PrintDefaults()
 }
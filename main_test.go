@@ -4,11 +4,13 @@ import (
 	"bytes"
 	"strings"
 	"testing"
+
+	"llm/internal/cmd"
 )
 
 func TestUsageIncludesGHPR(t *testing.T) {
 	var out bytes.Buffer
-	usageTo(&out)
+	usageTo(&out, newFlagSet(&cmd.Flags{}, new(bool)))
 
 	if !strings.Contains(out.String(), "gh <subcommand>") {
 		t.Fatalf("usage output = %q, want to include %q", out.String(), "gh <subcommand>")
@@ -18,3 +20,30 @@ func TestUsageIncludesGHPR(t *testing.T) {
 		t.Fatalf("usage output = %q, want to include gh pr subcommand help", out.String())
 	}
 }
+
+func TestUsageIncludesGlobalFlags(t *testing.T) {
+	var out bytes.Buffer
+	usageTo(&out, newFlagSet(&cmd.Flags{}, new(bool)))
+
+	for _, want := range []string{"-provider", "-model"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("usage output = %q, want to include %q", out.String(), want)
+		}
+	}
+}
+
+func TestNewFlagSetParsesIntoFlags(t *testing.T) {
+	var flags cmd.Flags
+	var printVersion bool
+	fs := newFlagSet(&flags, &printVersion)
+
+	if err := fs.Parse([]string{"-model", "gpt-4o", "-no-cache", "ask", "hi"}); err != nil {
+		t.Fatalf("Parse() error = %v, want nil", err)
+	}
+	if flags.Model != "gpt-4o" || !flags.NoCache || printVersion {
+		t.Errorf("flags = %+v, version = %v, want the model and no-cache set", flags, printVersion)
+	}
+	if args := fs.Args(); strings.Join(args, " ") != "ask hi" {
+		t.Errorf("Args() = %q, want the command", args)
+	}
+}